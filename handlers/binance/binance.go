@@ -1,23 +1,163 @@
 package binance
 
 import (
-	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
-	"os"
-	"os/signal"
-	"strings"
+	"net/http"
+	"strconv"
 	"time"
 
+	"github.com/Antkky/go_crypto_scraper/handlers/exchange"
 	"github.com/Antkky/go_crypto_scraper/utils"
-	"github.com/Antkky/go_crypto_scraper/utils/buffer"
 	"github.com/gorilla/websocket"
 )
 
+func init() {
+	exchange.Register("binance", New)
+}
+
+// Adapter implements exchange.Adapter for the Binance websocket streams.
+// Diff depth events are synced against REST snapshots fetched with Client.
+type Adapter struct {
+	exchange.Base
+	Client *http.Client
+	depth  *DepthSync
+	// streams maps the buffer code of every configured stream to its source
+	streams map[string]string
+	// lastTradeID is the last trade id seen per symbol; Parse is only called
+	// from the consumer, so it needs no lock
+	lastTradeID map[string]int64
+}
+
+// New builds a Binance adapter for the given config.
+func New(config utils.ExchangeConfig, logger *log.Logger) exchange.Adapter {
+	adapter := &Adapter{
+		Base:        exchange.Base{Config: config, Logger: logger},
+		Client:      &http.Client{Timeout: 10 * time.Second},
+		streams:     make(map[string]string),
+		lastTradeID: make(map[string]int64),
+	}
+	for _, stream := range config.Streams {
+		source := stream.Source
+		if stream.Type == "ticker" && source == "" {
+			source = defaultTickerSource
+		}
+		if stream.Type == "ticker" && !tickerSources[source] {
+			logger.Printf("⚠️ %s %s: unknown ticker source %q, using %s", config.Name, stream.Symbol, source, defaultTickerSource)
+			source = defaultTickerSource
+		}
+		adapter.streams[exchange.BufferCode(stream.Symbol, stream.Type, config.Name)] = source
+	}
+	adapter.depth = NewDepthSync(adapter.FetchSnapshot)
+	adapter.depth.OnGap = func(symbol string, expected int64, got int64) {
+		logger.Printf("⚠️ %s %s depth gap: expected update %d, got %d, resyncing", config.Name, symbol, expected, got)
+	}
+	adapter.depth.OnError = func(symbol string, err error) {
+		logger.Printf("❌ %s %s depth snapshot failed, retrying: %v", config.Name, symbol, err)
+	}
+	return adapter
+}
+
+// Parse decodes the frame and runs depth updates through the snapshot sync, so
+// only records from a consistent book reach the buffers. Types in
+// sharedEventTypes are dropped unless a stream was configured for them, and
+// tickers are only kept from the stream's configured source. Trade ids go up
+// by one per symbol, so any skipped ids are reported as a gap. A depth event
+// that cannot be synced is logged and left out without dropping the rest.
+func (a *Adapter) Parse(message []byte) ([]utils.ParsedMessage, error) {
+	parsed, err := ProcessMessage(message)
+	if err != nil {
+		return nil, err
+	}
+
+	// a trade gap adds a message, so synced can outgrow parsed and must not share its array
+	synced := make([]utils.ParsedMessage, 0, len(parsed))
+	for _, msg := range parsed {
+		if sharedEventTypes[msg.DataType] {
+			source, configured := a.streams[exchange.BufferCode(msg.Symbol, msg.DataType, a.Config.Name)]
+			if !configured || (msg.DataType == "ticker" && tickerSource(msg) != source) {
+				continue
+			}
+		}
+		if trades, ok := msg.Data.([]utils.TradeDataStruct); ok {
+			synced = append(synced, a.tradeGaps(trades)...)
+		}
+		update, ok := msg.Data.(DepthUpdateData)
+		if !ok {
+			synced = append(synced, msg)
+			continue
+		}
+		records, err := a.depth.Apply(update)
+		if err != nil {
+			// only this symbol's book is resynced; the rest of the frame is kept
+			a.Logger.Printf("❌ %s %s depth: %v", a.Config.Name, update.Symbol, err)
+			continue
+		}
+		if len(records) > 0 {
+			msg.Data = records
+			synced = append(synced, msg)
+		}
+	}
+	return synced, nil
+}
+
+// tradeGaps records the trade ids in trades and returns a gap message for every skipped range
+func (a *Adapter) tradeGaps(trades []utils.TradeDataStruct) []utils.ParsedMessage {
+	var gaps []utils.ParsedMessage
+	for _, trade := range trades {
+		id, err := strconv.ParseInt(trade.TradeID, 10, 64)
+		if err != nil {
+			continue
+		}
+		if last, seen := a.lastTradeID[trade.Symbol]; seen {
+			if id <= last {
+				continue
+			}
+			if gap, missed := utils.TradeIDGap(trade.Symbol, last, id); missed {
+				gaps = append(gaps, utils.ParsedMessage{DataType: "gap", Symbol: trade.Symbol, Data: []utils.GapDataStruct{gap}})
+			}
+		}
+		a.lastTradeID[trade.Symbol] = id
+	}
+	return gaps
+}
+
+// Subscribe replays the configured subscriptions; update ids do not carry over
+// a reconnect, so every depth book is resynced from a fresh snapshot.
+func (a *Adapter) Subscribe(conn *websocket.Conn) error {
+	a.depth.Reset()
+	return a.Base.Subscribe(conn)
+}
+
+// sharedEventTypes are data types produced from an event that also feeds
+// another type, e.g. 24hrTicker feeds both ticker and stats24h. They are only
+// kept when the config has a stream for them.
+var sharedEventTypes = map[string]bool{
+	"ticker":   true,
+	"stats24h": true,
+}
+
+// tickerSources are the events a ticker stream can be recorded from: the
+// 24hrTicker pushed about once a second, or the real time bookTicker.
+var tickerSources = map[string]bool{
+	"ticker":     true,
+	"bookTicker": true,
+}
+
+const defaultTickerSource = "ticker"
+
 // ________Small Helper Functions________
 
+// tickerSource reports which event a ticker message came from; only bookTicker carries an update id
+func tickerSource(msg utils.ParsedMessage) string {
+	if tickers, ok := msg.Data.([]utils.TickerDataStruct); ok && len(tickers) > 0 && tickers[0].UpdateID != 0 {
+		return "bookTicker"
+	}
+	return "ticker"
+}
+
 func WrappedCheck(message []byte) (bool, error) {
 	var pMessage GlobalMessageStruct
 
@@ -25,10 +165,11 @@ func WrappedCheck(message []byte) (bool, error) {
 		return false, err
 	}
 
-	if pMessage.Data.EventType != "" {
+	// spot bookTicker events have no "e", only an update id
+	if pMessage.Data.EventType != "" || pMessage.Data.UpdateID != 0 {
 		return true, nil
 	}
-	if pMessage.EventType != "" {
+	if pMessage.EventType != "" || pMessage.UpdateID != 0 {
 		return false, nil
 	}
 	return false, errors.New("unknown message type")
@@ -38,7 +179,13 @@ func extractEventType(msg GlobalMessageStruct) string {
 	if msg.Data.EventType != "" {
 		return msg.Data.EventType
 	}
-	return msg.EventType
+	if msg.EventType != "" {
+		return msg.EventType
+	}
+	if msg.Data.UpdateID != 0 || msg.UpdateID != 0 {
+		return "bookTicker"
+	}
+	return ""
 }
 
 func processWrapped(wrapped bool, message []byte, bmessage *[]byte) error {
@@ -56,282 +203,224 @@ func processWrapped(wrapped bool, message []byte, bmessage *[]byte) error {
 	return nil
 }
 
-// ________Main Functions________
-
-// InitializeStreams()
-//
-// Inputs:
-//
-//	conn        : *websocket.Conn
-//	exchange    : utils.ExchangeConfig
-//	dataBuffers : []*utils.DataBuffer
-//
-// Outputs:
-//
-//	No Outputs
-//
-// Description:
-//
-//	Initializes the streams by subscribing to the streams and creating the data buffers
-func InitializeStreams(conn *websocket.Conn, exchange utils.ExchangeConfig, dataBuffers *map[string]*buffer.DataBuffer, logger *log.Logger) error {
-	*dataBuffers = make(map[string]*buffer.DataBuffer)
-
-	for _, stream := range exchange.Streams {
-		bMessage, err := json.Marshal(stream.Message)
-		filename := fmt.Sprintf("%s_%s_%s.csv", strings.ReplaceAll(exchange.Name, " ", ""), stream.Symbol, stream.Type)
-		bufferCode := fmt.Sprintf("%s:%s@%s", stream.Symbol, stream.Type, strings.ReplaceAll(exchange.Name, " ", ""))
-		filePath := fmt.Sprintf("data/%s/%s", strings.ReplaceAll(exchange.Name, " ", ""), stream.Symbol)
-		(*dataBuffers)[bufferCode] = buffer.NewDataBuffer(stream.Type, stream.Market, bufferCode, 50, filename, filePath)
-
-		if err != nil {
-			logger.Printf("❌ Error marshalling subscribe message %v: %s", stream, err)
-			return err
-		}
-
-		if err := conn.WriteMessage(websocket.TextMessage, bMessage); err != nil {
-			logger.Printf("❌ Error subscribing to stream %v: %s", stream, err)
-			return err
-		}
-		time.Sleep(500 * time.Millisecond)
+// isSubscribeAck reports whether message is the {"result":null,"id":n} reply to a SUBSCRIBE request
+func isSubscribeAck(message []byte) bool {
+	var ack struct {
+		Result json.RawMessage `json:"result"`
+		ID     *int            `json:"id"`
 	}
-	return nil
-}
-
-// HandleConnection()
-//
-// Inputs:
-//
-//	conn     : *websocket.Conn
-//	exchange : utils.ExchangeConfig
-//
-// Outputs:
-//
-//	No Outputs
-//
-// Description:
-//
-//	goroutine that subscribes and launches 2 goroutines to listen for messages and handle them
-func HandleConnection(conn *websocket.Conn, exchange utils.ExchangeConfig, logger *log.Logger) {
-	if conn == nil {
-		logger.Println("❌ Connection is nil, exiting HandleConnection.")
-		return
-	}
-
-	// isEmpty checks if the given data is empty
-
-	interrupt := make(chan os.Signal, 1)
-	signal.Notify(interrupt, os.Interrupt)
-	defer signal.Stop(interrupt)
-
-	messageQueue := make(chan []byte, 500)
-	done := make(chan struct{})
-
-	dataBuffers := make(map[string]*buffer.DataBuffer)
-	if err := InitializeStreams(conn, exchange, &dataBuffers, logger); err != nil {
-		return
+	if err := json.Unmarshal(message, &ack); err != nil {
+		return false
 	}
-
-	go ConsumeMessages(messageQueue, exchange, done, dataBuffers, logger)
-	go ReceiveMessages(conn, messageQueue, done, exchange, logger)
-
-	<-interrupt
-	logger.Println("Interrupt received, closing connection...")
-
-	CloseConnection(conn, exchange.Name, logger)
+	return ack.ID != nil && string(ack.Result) == "null"
 }
 
-// ProcessMessageType()
+// ________Main Functions________
+
+// ProcessMessage()
 //
 // Inputs:
 //
-//	message    : []byte
-//	tickerData : *utils.TickerDataStruct
-//	tradeData  : *utils.TradeDataStruct
+//	message : []byte
 //
 // Outputs:
 //
+//	[]utils.ParsedMessage
 //	error
 //
 // Description:
 //
 //	basically routes the data to the correct processing function
-func ProcessMessage(message []byte, tickerDataP *[]utils.TickerDataStruct, tradeData *[]utils.TradeDataStruct) (int, error) {
-	if bytes.Equal(message, []byte(`{"result":null,"id":5}`)) {
-		return 5, nil
+func ProcessMessage(message []byte) ([]utils.ParsedMessage, error) {
+	if isSubscribeAck(message) {
+		return []utils.ParsedMessage{{Event: "subscribed"}}, nil
 	}
 
 	var pMessage GlobalMessageStruct
 	wrapped, err := WrappedCheck(message)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
 	var bmessage []byte
 	if err := processWrapped(wrapped, message, &bmessage); err != nil {
-		return 0, err
+		return nil, err
 	}
 
 	if err := json.Unmarshal(bmessage, &pMessage); err != nil {
-		return 0, err
+		return nil, err
 	}
 
-	switch {
-	case extractEventType(pMessage) == "24hrTicker":
+	switch extractEventType(pMessage) {
+	case "24hrTicker":
 		var tickerMsg TickerData
 		if err := json.Unmarshal(bmessage, &tickerMsg); err != nil {
-			return 1, err
+			return nil, err
 		}
-		*tickerDataP = []utils.TickerDataStruct{{
-			TimeStamp: uint64(tickerMsg.EventTime),
-			Symbol:    tickerMsg.Symbol,
-			BidPrice:  string(tickerMsg.BidPrice),
-			BidSize:   string(tickerMsg.BidSize),
-			AskPrice:  string(tickerMsg.AskPrice),
-			AskSize:   string(tickerMsg.AskSize),
-		}}
-		return 1, nil
-
-	case extractEventType(pMessage) == "trade":
+		return []utils.ParsedMessage{{
+			DataType: "ticker",
+			Symbol:   tickerMsg.Symbol,
+			Data: []utils.TickerDataStruct{{
+				TimeStamp: uint64(tickerMsg.EventTime),
+				Symbol:    tickerMsg.Symbol,
+				BidPrice:  string(tickerMsg.BidPrice),
+				BidSize:   string(tickerMsg.BidSize),
+				AskPrice:  string(tickerMsg.AskPrice),
+				AskSize:   string(tickerMsg.AskSize),
+			}},
+		}, {
+			DataType: "stats24h",
+			Symbol:   tickerMsg.Symbol,
+			Data: []utils.Stats24hDataStruct{{
+				TimeStamp:   uint64(tickerMsg.EventTime),
+				Symbol:      tickerMsg.Symbol,
+				OpenTime:    uint64(tickerMsg.OpenTime),
+				CloseTime:   uint64(tickerMsg.CloseTime),
+				OpenPrice:   string(tickerMsg.OpenPrice),
+				HighPrice:   string(tickerMsg.HighPrice),
+				LowPrice:    string(tickerMsg.LowPrice),
+				ClosePrice:  string(tickerMsg.ClosePrice),
+				BaseVolume:  string(tickerMsg.BaseVolume),
+				QuoteVolume: string(tickerMsg.QuoteVolume),
+			}},
+		}}, nil
+
+	case "bookTicker":
+		var bookMsg BookTickerData
+		if err := json.Unmarshal(bmessage, &bookMsg); err != nil {
+			return nil, err
+		}
+		timestamp := bookMsg.EventTime
+		if timestamp == 0 {
+			timestamp = now().UnixMilli()
+		}
+		return []utils.ParsedMessage{{
+			DataType: "ticker",
+			Symbol:   bookMsg.Symbol,
+			Data: []utils.TickerDataStruct{{
+				TimeStamp: uint64(timestamp),
+				Symbol:    bookMsg.Symbol,
+				BidPrice:  bookMsg.BidPrice,
+				BidSize:   bookMsg.BidSize,
+				AskPrice:  bookMsg.AskPrice,
+				AskSize:   bookMsg.AskSize,
+				UpdateID:  uint64(bookMsg.UpdateID),
+			}},
+		}}, nil
+
+	case "trade":
 		var tradeMsg TradeData
 		if err := json.Unmarshal(bmessage, &tradeMsg); err != nil {
-			return 2, err
+			return nil, err
 		}
-		*tradeData = []utils.TradeDataStruct{{
-			TimeStamp: uint64(tradeMsg.EventTime),
-			Symbol:    tradeMsg.Symbol,
-			Price:     tradeMsg.Price,
-			Quantity:  tradeMsg.Quantity,
-			Bid_MM:    tradeMsg.IsMaker,
-		}}
-		return 2, nil
-
-	default:
-		return 0, fmt.Errorf("unknown message type: %s", message)
-	}
-}
-
-// ConsumeMessages()
-//
-// Inputs:
-//
-//	messageQueue  : chan []byte
-//	done          : chan struct{}
-//	exchange      : utils.ExchangeConfig
-//
-// Outputs:
-//
-//	No Outputs
-//
-// Description:
-//
-//	Processes incoming messages and adds them to the appropriate data buffer.
-//	This function performs constant time lookups for the buffer associated with each message.
-func ConsumeMessages(messageQueue chan []byte, exchange utils.ExchangeConfig, done chan struct{}, buffers map[string]*buffer.DataBuffer, logger *log.Logger) {
-	defer close(done)
-	normalizedExchangeName := strings.ReplaceAll(exchange.Name, " ", "")
-
-	for message := range messageQueue {
-		bufferCode := ""
-		tickerData := []utils.TickerDataStruct{}
-		tradeData := []utils.TradeDataStruct{}
-
-		dataType, err := ProcessMessage(message, &tickerData, &tradeData)
-		if err != nil {
-			logger.Printf("❌ Error processing message: %v", err)
-			continue
+		return []utils.ParsedMessage{{
+			DataType: "trade",
+			Symbol:   tradeMsg.Symbol,
+			Data: []utils.TradeDataStruct{{
+				TimeStamp: uint64(tradeMsg.EventTime),
+				Symbol:    tradeMsg.Symbol,
+				Price:     tradeMsg.Price,
+				Quantity:  tradeMsg.Quantity,
+				Bid_MM:    tradeMsg.IsMaker,
+				TradeID:   strconv.Itoa(tradeMsg.TradeID),
+			}},
+		}}, nil
+
+	case "aggTrade":
+		var aggMsg AggTradeData
+		if err := json.Unmarshal(bmessage, &aggMsg); err != nil {
+			return nil, err
 		}
-
-		switch dataType {
-		case 0:
-			logger.Println("❌ Unknown message type, skipping message")
-			continue
-		case 1:
-			if tickerData[0].Symbol != "" {
-				bufferCode = fmt.Sprintf("%s:ticker@%s", tickerData[0].Symbol, normalizedExchangeName)
-			}
-		case 2:
-			if tradeData[0].Symbol != "" {
-				bufferCode = fmt.Sprintf("%s:trade@%s", tradeData[0].Symbol, normalizedExchangeName)
-			}
-		case 5:
-			logger.Println("✅ Subscribe Success")
+		return []utils.ParsedMessage{{
+			DataType: "aggTrade",
+			Symbol:   aggMsg.Symbol,
+			Data: []utils.AggTradeDataStruct{{
+				TimeStamp:    uint64(aggMsg.EventTime),
+				Symbol:       aggMsg.Symbol,
+				AggTradeID:   uint64(aggMsg.AggTradeID),
+				Price:        aggMsg.Price,
+				Quantity:     aggMsg.Quantity,
+				FirstTradeID: uint64(aggMsg.FirstTradeID),
+				LastTradeID:  uint64(aggMsg.LastTradeID),
+				TradeTime:    uint64(aggMsg.TradeTime),
+				IsBuyerMaker: aggMsg.IsBuyerMaker,
+			}},
+		}}, nil
+
+	case "kline":
+		var klineMsg KlineData
+		if err := json.Unmarshal(bmessage, &klineMsg); err != nil {
+			return nil, err
 		}
-
-		if bufferCode != "" {
-			if buffer, exists := buffers[bufferCode]; exists {
-				if dataType == 1 {
-					if err := buffer.AddData(tickerData); err != nil {
-						logger.Println("❌ Error adding data to buffer: ", err)
-						return
-					}
-				} else {
-					if err := buffer.AddData(tradeData); err != nil {
-						logger.Println("❌ Error adding data to buffer: ", err)
-						return
-					}
-				}
-			} else {
-				logger.Printf("❌ No buffer found for ID: %s", bufferCode)
-			}
+		return []utils.ParsedMessage{{
+			DataType: "kline",
+			Symbol:   klineMsg.Symbol,
+			Data: []utils.KlineDataStruct{{
+				TimeStamp: uint64(klineMsg.EventTime),
+				Symbol:    klineMsg.Symbol,
+				Interval:  klineMsg.Kline.Interval,
+				OpenTime:  uint64(klineMsg.Kline.OpenTime),
+				CloseTime: uint64(klineMsg.Kline.CloseTime),
+				Open:      klineMsg.Kline.Open,
+				High:      klineMsg.Kline.High,
+				Low:       klineMsg.Kline.Low,
+				Close:     klineMsg.Kline.Close,
+				Volume:    klineMsg.Kline.Volume,
+				Closed:    klineMsg.Kline.Closed,
+			}},
+		}}, nil
+
+	case "markPriceUpdate":
+		var markMsg MarkPriceData
+		if err := json.Unmarshal(bmessage, &markMsg); err != nil {
+			return nil, err
 		}
-	}
-}
-
-// ReceiveMessages()
-//
-// Inputs:
-//
-//	conn          : *websocket.Conn
-//	messageQueue  : chan []byte
-//	done          : chan struct{}
-//	exchange      : utils.ExchangeConfig
-//
-// Outputs:
-//
-//	No Outputs
-//
-// Description:
-//
-//	Reads messages from the WebSocket connection and sends them to the messageQueue channel.
-func ReceiveMessages(conn *websocket.Conn, messageQueue chan []byte, done chan struct{}, exchange utils.ExchangeConfig, logger *log.Logger) {
-	defer close(done)
-
-	for {
-		_, message, err := conn.ReadMessage()
-		if err != nil {
-			logger.Printf("❌ Error reading message from %s: %v", exchange.Name, err)
-			return
+		return []utils.ParsedMessage{{
+			DataType: "funding",
+			Symbol:   markMsg.Symbol,
+			Data: []utils.FundingDataStruct{{
+				TimeStamp:       uint64(markMsg.EventTime),
+				Symbol:          markMsg.Symbol,
+				MarkPrice:       markMsg.MarkPrice,
+				IndexPrice:      markMsg.IndexPrice,
+				FundingRate:     markMsg.FundingRate,
+				NextFundingTime: uint64(markMsg.NextFundingTime),
+			}},
+		}}, nil
+
+	case "forceOrder":
+		var forceMsg ForceOrderData
+		if err := json.Unmarshal(bmessage, &forceMsg); err != nil {
+			return nil, err
 		}
-
-		select {
-		case messageQueue <- message:
-			//bruh
-		case <-time.After(time.Millisecond * 100):
-			logger.Println("Producer slowed down")
-		default:
-			logger.Printf("❌ Message queue full, dropping message for %s", exchange.Name)
+		order := forceMsg.Order
+		return []utils.ParsedMessage{{
+			DataType: "liquidation",
+			Symbol:   order.Symbol,
+			Data: []utils.LiquidationDataStruct{{
+				TimeStamp: uint64(forceMsg.EventTime),
+				Symbol:    order.Symbol,
+				Side:      order.Side,
+				Price:     order.Price,
+				AvgPrice:  order.AvgPrice,
+				Quantity:  order.Quantity,
+				Filled:    order.FilledQuantity,
+				Status:    order.Status,
+			}},
+		}}, nil
+
+	case "depthUpdate":
+		var depthMsg DepthUpdateData
+		if err := json.Unmarshal(bmessage, &depthMsg); err != nil {
+			return nil, err
 		}
-	}
-}
+		return []utils.ParsedMessage{{
+			DataType: "depth",
+			Symbol:   depthMsg.Symbol,
+			Data:     depthMsg,
+		}}, nil
 
-// CloseConnection()
-//
-// Inputs:
-//
-//	conn         : *websocket.conn
-//	exchangeName : string
-//
-// Outputs:
-//
-//	No Outputs
-//
-// Description:
-//
-//	Gracefully close the connection by sending a closure message and gracefully close connection
-func CloseConnection(conn *websocket.Conn, exchangeName string, logger *log.Logger) {
-	if err := conn.Close(); err != nil {
-		logger.Printf("❌ Error closing connection for %s: %v", exchangeName, err)
-	} else {
-		logger.Printf("Connection for %s closed gracefully", exchangeName)
+	default:
+		return nil, fmt.Errorf("unknown message type: %s", message)
 	}
 }
@@ -1,7 +1,11 @@
 package binance
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/Antkky/go_crypto_scraper/utils"
+	"github.com/Antkky/go_crypto_scraper/utils/orderbook"
 )
 
 // Test Cases for ProcessMessageType
@@ -12,6 +16,8 @@ var ProcessMessageTypeCases = []struct {
 	wrapped    bool
 	r1         utils.TickerDataStruct
 	r2         utils.TradeDataStruct
+	want       interface{}           // records for the other data types
+	also       []utils.ParsedMessage // messages the event produces after the first
 	errorValue error
 	wantError  bool
 }{
@@ -54,8 +60,429 @@ var ProcessMessageTypeCases = []struct {
 			AskPrice:  "0.0026",
 			AskSize:   "100",
 		},
+		also: []utils.ParsedMessage{{
+			DataType: "stats24h",
+			Symbol:   "BNBBTC",
+			Data: []utils.Stats24hDataStruct{{
+				TimeStamp:   1672515782136,
+				Symbol:      "BNBBTC",
+				OpenTime:    0,
+				CloseTime:   86400000,
+				OpenPrice:   "0.0010",
+				HighPrice:   "0.0025",
+				LowPrice:    "0.0010",
+				ClosePrice:  "0.0025",
+				BaseVolume:  "10000",
+				QuoteVolume: "18",
+			}},
+		}},
 		r2:         utils.TradeDataStruct{},
 		errorValue: nil,
 		wantError:  false,
 	},
+	// Wrapped valid trade message
+	{
+		name:      "wrapped valid trade message",
+		eventType: "trade",
+		message: []byte(`{
+			"stream": "btcusdt@trade",
+			"data": {
+				"e": "trade",
+				"E": 1672515782136,
+				"s": "BTCUSDT",
+				"t": 12345,
+				"p": "16500.10",
+				"q": "0.002",
+				"T": 1672515782134,
+				"m": true,
+				"M": true
+			}
+		}`),
+		wrapped: true,
+		r1:      utils.TickerDataStruct{},
+		r2: utils.TradeDataStruct{
+			TimeStamp: 1672515782136,
+			Date:      0,
+			Symbol:    "BTCUSDT",
+			Price:     "16500.10",
+			Quantity:  "0.002",
+			Bid_MM:    true,
+			TradeID:   "12345",
+		},
+		errorValue: nil,
+		wantError:  false,
+	},
+	// Spot bookTicker, which has no event type or time
+	{
+		name:      "unwrapped book ticker",
+		eventType: "ticker",
+		message: []byte(`{
+			"u": 400900217,
+			"s": "BNBUSDT",
+			"b": "25.35190000",
+			"B": "31.21000000",
+			"a": "25.36520000",
+			"A": "40.66000000"
+		}`),
+		r1: utils.TickerDataStruct{
+			TimeStamp: 1700000000000,
+			Symbol:    "BNBUSDT",
+			BidPrice:  "25.35190000",
+			BidSize:   "31.21000000",
+			AskPrice:  "25.36520000",
+			AskSize:   "40.66000000",
+			UpdateID:  400900217,
+		},
+	},
+	// Wrapped futures bookTicker
+	{
+		name:      "wrapped futures book ticker",
+		eventType: "ticker",
+		message: []byte(`{
+			"stream": "btcusdt@bookTicker",
+			"data": {
+				"e": "bookTicker",
+				"u": 400900217,
+				"E": 1568014460893,
+				"T": 1568014460891,
+				"s": "BTCUSDT",
+				"b": "25.35190000",
+				"B": "31.21000000",
+				"a": "25.36520000",
+				"A": "40.66000000"
+			}
+		}`),
+		wrapped: true,
+		r1: utils.TickerDataStruct{
+			TimeStamp: 1568014460893,
+			Symbol:    "BTCUSDT",
+			BidPrice:  "25.35190000",
+			BidSize:   "31.21000000",
+			AskPrice:  "25.36520000",
+			AskSize:   "40.66000000",
+			UpdateID:  400900217,
+		},
+	},
+	// Wrapped aggregate trade
+	{
+		name:      "wrapped agg trade",
+		eventType: "aggTrade",
+		message: []byte(`{
+			"stream": "bnbbtc@aggTrade",
+			"data": {
+				"e": "aggTrade",
+				"E": 123456789,
+				"s": "BNBBTC",
+				"a": 12345,
+				"p": "0.001",
+				"q": "100",
+				"f": 100,
+				"l": 105,
+				"T": 123456785,
+				"m": true,
+				"M": false
+			}
+		}`),
+		wrapped: true,
+		want: []utils.AggTradeDataStruct{{
+			TimeStamp:    123456789,
+			Symbol:       "BNBBTC",
+			AggTradeID:   12345,
+			Price:        "0.001",
+			Quantity:     "100",
+			FirstTradeID: 100,
+			LastTradeID:  105,
+			TradeTime:    123456785,
+			IsBuyerMaker: true,
+		}},
+	},
+	// Closed kline
+	{
+		name:      "closed kline",
+		eventType: "kline",
+		message: []byte(`{
+			"e": "kline",
+			"E": 1672515782136,
+			"s": "BNBBTC",
+			"k": {
+				"t": 1672515720000,
+				"T": 1672515779999,
+				"s": "BNBBTC",
+				"i": "1m",
+				"f": 100,
+				"L": 200,
+				"o": "0.0010",
+				"c": "0.0020",
+				"h": "0.0025",
+				"l": "0.0015",
+				"v": "1000",
+				"n": 100,
+				"x": true,
+				"q": "1.0000",
+				"V": "500",
+				"Q": "0.500",
+				"B": "123456"
+			}
+		}`),
+		want: []utils.KlineDataStruct{{
+			TimeStamp: 1672515782136,
+			Symbol:    "BNBBTC",
+			Interval:  "1m",
+			OpenTime:  1672515720000,
+			CloseTime: 1672515779999,
+			Open:      "0.0010",
+			High:      "0.0025",
+			Low:       "0.0015",
+			Close:     "0.0020",
+			Volume:    "1000",
+			Closed:    true,
+		}},
+	},
+	// Wrapped futures mark price
+	{
+		name:      "wrapped mark price update",
+		eventType: "funding",
+		message: []byte(`{
+			"stream": "btcusdt@markPrice@1s",
+			"data": {
+				"e": "markPriceUpdate",
+				"E": 1562305380000,
+				"s": "BTCUSDT",
+				"p": "11794.15000000",
+				"i": "11784.62659091",
+				"P": "11784.25641265",
+				"r": "0.00038167",
+				"T": 1562306400000
+			}
+		}`),
+		wrapped: true,
+		want: []utils.FundingDataStruct{{
+			TimeStamp:       1562305380000,
+			Symbol:          "BTCUSDT",
+			MarkPrice:       "11794.15000000",
+			IndexPrice:      "11784.62659091",
+			FundingRate:     "0.00038167",
+			NextFundingTime: 1562306400000,
+		}},
+	},
+	// Futures liquidation
+	{
+		name:      "force order",
+		eventType: "liquidation",
+		message: []byte(`{
+			"e": "forceOrder",
+			"E": 1568014460893,
+			"o": {
+				"s": "BTCUSDT",
+				"S": "SELL",
+				"o": "LIMIT",
+				"f": "IOC",
+				"q": "0.014",
+				"p": "9910",
+				"ap": "9910",
+				"X": "FILLED",
+				"l": "0.014",
+				"z": "0.014",
+				"T": 1568014460893
+			}
+		}`),
+		want: []utils.LiquidationDataStruct{{
+			TimeStamp: 1568014460893,
+			Symbol:    "BTCUSDT",
+			Side:      "SELL",
+			Price:     "9910",
+			AvgPrice:  "9910",
+			Quantity:  "0.014",
+			Filled:    "0.014",
+			Status:    "FILLED",
+		}},
+	},
+}
+
+// depthEvent builds a depthUpdate frame that sets the 0.0024 bid to the final
+// update id and sends no asks; pu < 0 leaves the futures field out
+func depthEvent(first int64, final int64, pu int64) []byte {
+	prev := ""
+	if pu >= 0 {
+		prev = fmt.Sprintf(`"pu":%d,`, pu)
+	}
+	return []byte(fmt.Sprintf(`{"e":"depthUpdate","E":1672515782136,"s":"BNBBTC","U":%d,"u":%d,%s"b":[["0.0024","%d"]],"a":[]}`, first, final, prev, final))
+}
+
+// snapshotBody is a REST depth snapshot with two bids and one ask
+func snapshotBody(lastUpdateID int64) string {
+	return fmt.Sprintf(`{"lastUpdateId":%d,"bids":[["0.0024","10"],["0.0023","5"]],"asks":[["0.0026","100"]]}`, lastUpdateID)
+}
+
+// Test Cases for the depth snapshot + diff sync. wantRecords is the number of
+// depth records each message produces, in order. Snapshots are fetched in the
+// background and land before the next message, so they are bridged one event
+// after they were asked for. backoff is the DepthSync's MinBackoff; the test
+// clock stands still, so a non-zero backoff stops any retry.
+var DepthSyncCases = []struct {
+	name            string
+	snapshots       []int64
+	backoff         time.Duration
+	messages        [][]byte
+	wantRecords     []int
+	wantFetches     int
+	wantFetchErrors int
+	wantGaps        int
+	wantBids        []orderbook.Level
+}{
+	{
+		name:        "snapshot bridges the held updates",
+		snapshots:   []int64{100},
+		messages:    [][]byte{depthEvent(99, 101, -1), depthEvent(102, 103, -1)},
+		wantRecords: []int{0, 5},
+		wantFetches: 1,
+		wantBids:    []orderbook.Level{{Price: "0.0024", Size: "103"}, {Price: "0.0023", Size: "5"}},
+	},
+	{
+		name:        "updates covered by the snapshot are dropped",
+		snapshots:   []int64{100},
+		messages:    [][]byte{depthEvent(90, 95, -1), depthEvent(96, 100, -1), depthEvent(101, 102, -1)},
+		wantRecords: []int{0, 3, 1},
+		wantFetches: 1,
+	},
+	{
+		name:        "first live update after a covering snapshot may straddle it",
+		snapshots:   []int64{100},
+		messages:    [][]byte{depthEvent(90, 95, -1), depthEvent(96, 100, -1), depthEvent(99, 102, -1), depthEvent(103, 104, -1)},
+		wantRecords: []int{0, 3, 1, 1},
+		wantFetches: 1,
+		wantBids:    []orderbook.Level{{Price: "0.0024", Size: "104"}, {Price: "0.0023", Size: "5"}},
+	},
+	{
+		name:        "futures: first live update after a covering snapshot may straddle it",
+		snapshots:   []int64{100},
+		messages:    [][]byte{depthEvent(90, 95, 89), depthEvent(96, 100, 95), depthEvent(99, 102, 98), depthEvent(103, 104, 102)},
+		wantRecords: []int{0, 3, 1, 1},
+		wantFetches: 1,
+	},
+	{
+		name:        "gap triggers a resync",
+		snapshots:   []int64{100, 106},
+		messages:    [][]byte{depthEvent(99, 101, -1), depthEvent(102, 103, -1), depthEvent(106, 107, -1), depthEvent(108, 109, -1)},
+		wantRecords: []int{0, 5, 0, 5},
+		wantFetches: 2,
+		wantGaps:    1,
+	},
+	{
+		name:        "stale snapshot is retried",
+		snapshots:   []int64{90, 102},
+		messages:    [][]byte{depthEvent(99, 101, -1), depthEvent(102, 103, -1), depthEvent(104, 105, -1)},
+		wantRecords: []int{0, 0, 5},
+		wantFetches: 2,
+	},
+	{
+		name:        "stale snapshot backs off",
+		snapshots:   []int64{90, 102},
+		backoff:     time.Second,
+		messages:    [][]byte{depthEvent(99, 101, -1), depthEvent(102, 103, -1), depthEvent(104, 105, -1)},
+		wantRecords: []int{0, 0, 0},
+		wantFetches: 1,
+	},
+	{
+		name:        "futures events chain on pu",
+		snapshots:   []int64{100},
+		messages:    [][]byte{depthEvent(98, 101, 97), depthEvent(105, 110, 101), depthEvent(111, 112, 109)},
+		wantRecords: []int{0, 5, 0},
+		wantFetches: 2,
+		wantGaps:    1,
+	},
+	{
+		name:            "failed snapshot request is retried",
+		snapshots:       nil,
+		messages:        [][]byte{depthEvent(99, 101, -1), depthEvent(102, 103, -1)},
+		wantRecords:     []int{0, 0},
+		wantFetches:     2,
+		wantFetchErrors: 2,
+	},
+	{
+		name:            "failed snapshot request backs off",
+		snapshots:       nil,
+		backoff:         time.Second,
+		messages:        [][]byte{depthEvent(99, 101, -1), depthEvent(102, 103, -1), depthEvent(104, 105, -1)},
+		wantRecords:     []int{0, 0, 0},
+		wantFetches:     1,
+		wantFetchErrors: 1,
+	},
+}
+
+// Test Cases for keeping shared event types only when they are configured
+var SharedEventCases = []struct {
+	name      string
+	streams   []utils.StreamConfig
+	wantTypes []string
+}{
+	{
+		name:      "ticker only",
+		streams:   []utils.StreamConfig{{Type: "ticker", Symbol: "BNBBTC"}},
+		wantTypes: []string{"ticker"},
+	},
+	{
+		name:      "stats24h only",
+		streams:   []utils.StreamConfig{{Type: "stats24h", Symbol: "BNBBTC"}},
+		wantTypes: []string{"stats24h"},
+	},
+	{
+		name:      "both",
+		streams:   []utils.StreamConfig{{Type: "ticker", Symbol: "BNBBTC"}, {Type: "stats24h", Symbol: "BNBBTC"}},
+		wantTypes: []string{"ticker", "stats24h"},
+	},
+	{
+		name:      "other symbol",
+		streams:   []utils.StreamConfig{{Type: "ticker", Symbol: "BTCUSDT"}},
+		wantTypes: []string{},
+	},
+}
+
+// bookTickerMessage is a spot bookTicker for BNBBTC
+var bookTickerMessage = []byte(`{"u":400900217,"s":"BNBBTC","b":"0.0024","B":"10","a":"0.0026","A":"100"}`)
+
+// Test Cases for picking the ticker source per stream
+var TickerSourceCases = []struct {
+	name         string
+	source       string
+	wantTicker   bool // whether the 24hrTicker event reaches the ticker buffer
+	wantBookTick bool // whether the bookTicker event does
+}{
+	{name: "default is 24hrTicker", source: "", wantTicker: true},
+	{name: "explicit ticker", source: "ticker", wantTicker: true},
+	{name: "bookTicker", source: "bookTicker", wantBookTick: true},
+	{name: "unknown source falls back", source: "nope", wantTicker: true},
+}
+
+// tradeMessage is a raw trade event for symbol with trade id id
+func tradeMessage(symbol string, id int) []byte {
+	return []byte(fmt.Sprintf(`{"e":"trade","E":1672515782136,"s":%q,"t":%d,"p":"16500.10","q":"0.002","T":1672515782136,"m":true,"M":true}`, symbol, id))
+}
+
+// Test Cases for trade id gaps
+var TradeGapCases = []struct {
+	name     string
+	messages [][]byte
+	want     []utils.GapDataStruct
+}{
+	{
+		name:     "consecutive ids",
+		messages: [][]byte{tradeMessage("BTCUSDT", 10), tradeMessage("BTCUSDT", 11)},
+		want:     nil,
+	},
+	{
+		name:     "skipped ids are one gap",
+		messages: [][]byte{tradeMessage("BTCUSDT", 10), tradeMessage("BTCUSDT", 14)},
+		want:     []utils.GapDataStruct{{Symbol: "BTCUSDT", FirstMissing: 11, LastMissing: 13}},
+	},
+	{
+		name:     "ids are tracked per symbol",
+		messages: [][]byte{tradeMessage("BTCUSDT", 10), tradeMessage("ETHUSDT", 500), tradeMessage("BTCUSDT", 11)},
+		want:     nil,
+	},
+	{
+		name:     "duplicate id is not a gap",
+		messages: [][]byte{tradeMessage("BTCUSDT", 10), tradeMessage("BTCUSDT", 10), tradeMessage("BTCUSDT", 11)},
+		want:     nil,
+	},
 }
@@ -1,7 +1,13 @@
 package binance
 
 import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/Antkky/go_crypto_scraper/utils"
 	"github.com/stretchr/testify/assert"
@@ -11,42 +17,222 @@ import (
 //
 // inputs
 // message : []byte
-// r1      : *utils.TickerDataStruct
-// r2      : *utils.TradeDataStruct
 //
 // Outputs:
-// err : error
+// parsed : []utils.ParsedMessage
+// err    : error
 //
 // Description:
-// routes message for processing changes through pointer reference
+// routes message for processing and checks the parsed records
 func TestProcessMessage(t *testing.T) {
+	now = func() time.Time { return time.UnixMilli(1700000000000) }
+	defer func() { now = time.Now }()
+
 	for _, tt := range ProcessMessageTypeCases {
 		t.Run(tt.name, func(t *testing.T) {
-			var (
-				r1 []utils.TickerDataStruct
-				r2 []utils.TradeDataStruct
-			)
-
 			// Call the ProcessMessage function
-			dataType, err := ProcessMessage(tt.message, &r1, &r2)
+			parsed, err := ProcessMessage(tt.message)
 
 			// Error handling logic
 			if tt.wantError {
 				assert.Error(t, err, "Expected an error but got none")
 				assert.ErrorIs(t, err, tt.errorValue, "Error type does not match expected")
-			} else {
-				assert.NoError(t, err, "Unexpected error occurred")
+				return
+			}
+			assert.NoError(t, err, "Unexpected error occurred")
+
+			if !assert.Len(t, parsed, 1+len(tt.also), "Unexpected number of parsed messages") {
+				return
+			}
+			if len(tt.also) > 0 {
+				assert.Equal(t, tt.also, parsed[1:], "Additional parsed messages do not match expected output")
 			}
 
 			// Validate the result based on event type
-			switch dataType {
-			case 1:
-				assert.Equal(t, tt.r1, r1, "Ticker data (r1) does not match expected output")
-			case 2:
-				assert.Equal(t, tt.r2, r2, "Trade data (r2) does not match expected output")
+			switch parsed[0].DataType {
+			case "ticker":
+				assert.Equal(t, []utils.TickerDataStruct{tt.r1}, parsed[0].Data, "Ticker data (r1) does not match expected output")
+			case "trade":
+				assert.Equal(t, []utils.TradeDataStruct{tt.r2}, parsed[0].Data, "Trade data (r2) does not match expected output")
 			default:
-				t.Errorf("Unexpected event type: %s", tt.eventType)
+				if tt.want == nil {
+					t.Errorf("Unexpected event type: %s", tt.eventType)
+					return
+				}
+				assert.Equal(t, tt.eventType, parsed[0].DataType, "Data type does not match expected")
+				assert.Equal(t, tt.want, parsed[0].Data, "Data does not match expected output")
+			}
+		})
+	}
+}
+
+// TestSharedEvents
+//
+// Description:
+// a 24hrTicker event feeds both ticker and stats24h, and the adapter keeps only the configured ones
+func TestSharedEvents(t *testing.T) {
+	message := ProcessMessageTypeCases[0].message
+	for _, tt := range SharedEventCases {
+		t.Run(tt.name, func(t *testing.T) {
+			config := utils.ExchangeConfig{Name: "Binance Test", Streams: tt.streams}
+			adapter := New(config, log.New(io.Discard, "", 0))
+
+			parsed, err := adapter.Parse(message)
+			assert.NoError(t, err, "Unexpected error occurred")
+
+			types := []string{}
+			for _, msg := range parsed {
+				types = append(types, msg.DataType)
+			}
+			assert.Equal(t, tt.wantTypes, types)
+		})
+	}
+}
+
+// TestTickerSource
+//
+// Description:
+// only the event picked by the stream's source feeds the ticker buffer
+func TestTickerSource(t *testing.T) {
+	for _, tt := range TickerSourceCases {
+		t.Run(tt.name, func(t *testing.T) {
+			streams := []utils.StreamConfig{{Type: "ticker", Symbol: "BNBBTC", Source: tt.source}}
+			adapter := New(utils.ExchangeConfig{Name: "Binance Test", Streams: streams}, log.New(io.Discard, "", 0))
+
+			parsed, err := adapter.Parse(ProcessMessageTypeCases[0].message)
+			assert.NoError(t, err, "Unexpected error occurred")
+			assert.Equal(t, tt.wantTicker, len(parsed) == 1, "24hrTicker")
+
+			parsed, err = adapter.Parse(bookTickerMessage)
+			assert.NoError(t, err, "Unexpected error occurred")
+			assert.Equal(t, tt.wantBookTick, len(parsed) == 1, "bookTicker")
+		})
+	}
+}
+
+// TestDepthSync
+//
+// Description:
+// feeds depthUpdate frames through the adapter with a local stand-in for the
+// REST depth endpoint and checks what the sync lets through
+func TestDepthSync(t *testing.T) {
+	now = func() time.Time { return time.UnixMilli(1700000000000) }
+	defer func() { now = time.Now }()
+
+	for _, tt := range DepthSyncCases {
+		t.Run(tt.name, func(t *testing.T) {
+			var fetches atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fetch := int(fetches.Add(1))
+				assert.Equal(t, "BNBBTC", r.URL.Query().Get("symbol"))
+				if len(tt.snapshots) == 0 {
+					http.Error(w, "unavailable", http.StatusServiceUnavailable)
+					return
+				}
+				snapshot := tt.snapshots[min(fetch, len(tt.snapshots))-1]
+				io.WriteString(w, snapshotBody(snapshot))
+			}))
+			defer server.Close()
+
+			config := utils.ExchangeConfig{Name: "Binance Test", RestURI: server.URL + "/api/v3/depth"}
+			adapter := New(config, log.New(io.Discard, "", 0)).(*Adapter)
+			adapter.Client = server.Client()
+			adapter.depth.MinBackoff = tt.backoff
+			gaps, fetchErrors := 0, 0
+			adapter.depth.OnGap = func(symbol string, expected int64, got int64) { gaps++ }
+			adapter.depth.OnError = func(symbol string, err error) { fetchErrors++ }
+
+			for i, message := range tt.messages {
+				parsed, err := adapter.Parse(message)
+				assert.NoError(t, err, "Unexpected error occurred")
+				// let the snapshot asked for by this event land before the next one
+				adapter.depth.wait()
+
+				records := 0
+				for _, msg := range parsed {
+					assert.Equal(t, "depth", msg.DataType)
+					records += len(msg.Data.([]utils.DepthDataStruct))
+				}
+				assert.Equal(t, tt.wantRecords[i], records, "message %d", i)
+			}
+			assert.Equal(t, tt.wantFetches, int(fetches.Load()), "snapshot fetches")
+			assert.Equal(t, tt.wantFetchErrors, fetchErrors, "snapshot errors")
+			assert.Equal(t, tt.wantGaps, gaps, "gaps")
+			if tt.wantBids != nil {
+				book, synced := adapter.depth.Book("BNBBTC")
+				assert.True(t, synced, "book should be synced")
+				assert.Equal(t, tt.wantBids, book.Bids)
+			}
+		})
+	}
+}
+
+// TestDepthSnapshotInBackground
+//
+// Description:
+// a slow depth endpoint must not hold up Parse, and only one snapshot
+// request per symbol may be in flight however many events arrive
+func TestDepthSnapshotInBackground(t *testing.T) {
+	release := make(chan struct{})
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		<-release
+		io.WriteString(w, snapshotBody(100))
+	}))
+	defer server.Close()
+
+	config := utils.ExchangeConfig{Name: "Binance Test", RestURI: server.URL + "/api/v3/depth"}
+	adapter := New(config, log.New(io.Discard, "", 0)).(*Adapter)
+	adapter.Client = server.Client()
+
+	start := time.Now()
+	for first := int64(99); first < 109; first += 2 {
+		parsed, err := adapter.Parse(depthEvent(first, first+1, -1))
+		assert.NoError(t, err, "Unexpected error occurred")
+		assert.Empty(t, parsed, "nothing is written before the snapshot lands")
+	}
+	parsed, err := adapter.Parse(tradeMessage("BNBBTC", 1))
+	assert.NoError(t, err, "Unexpected error occurred")
+	assert.Len(t, parsed, 1, "other streams keep flowing while depth waits")
+	assert.Less(t, time.Since(start), time.Second, "Parse waited on the snapshot")
+
+	close(release)
+	adapter.depth.wait()
+	assert.Equal(t, int32(1), fetches.Load(), "snapshot fetches")
+
+	parsed, err = adapter.Parse(depthEvent(109, 110, -1))
+	assert.NoError(t, err, "Unexpected error occurred")
+	assert.Len(t, parsed, 1)
+	_, synced := adapter.depth.Book("BNBBTC")
+	assert.True(t, synced, "book should be synced")
+}
+
+// TestTradeGaps
+//
+// Description:
+// skipped trade ids are reported once per missing range
+func TestTradeGaps(t *testing.T) {
+	for _, tt := range TradeGapCases {
+		t.Run(tt.name, func(t *testing.T) {
+			adapter := New(utils.ExchangeConfig{Name: "Binance Test"}, log.New(io.Discard, "", 0))
+
+			var gaps []utils.GapDataStruct
+			for _, message := range tt.messages {
+				parsed, err := adapter.Parse(message)
+				assert.NoError(t, err, "Unexpected error occurred")
+				for _, msg := range parsed {
+					if msg.DataType != "gap" {
+						continue
+					}
+					for _, gap := range msg.Data.([]utils.GapDataStruct) {
+						assert.NotZero(t, gap.WallTime)
+						gap.WallTime = 0
+						gaps = append(gaps, gap)
+					}
+				}
 			}
+			assert.Equal(t, tt.want, gaps)
 		})
 	}
 }
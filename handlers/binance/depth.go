@@ -0,0 +1,369 @@
+package binance
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Antkky/go_crypto_scraper/utils"
+	"github.com/Antkky/go_crypto_scraper/utils/orderbook"
+)
+
+const (
+	defaultDepthURI = "https://api.binance.com/api/v3/depth"
+	snapshotLimit   = 1000
+	// maxPendingDepth caps the events kept per symbol while waiting for a usable snapshot
+	maxPendingDepth = 1000
+	// defaultMinBackoff and defaultMaxBackoff bound the wait before a snapshot is requested
+	// again after a failed or stale one
+	defaultMinBackoff = time.Second
+	defaultMaxBackoff = time.Minute
+)
+
+// now is swapped out in tests; it stamps snapshots, which carry no timestamp
+// of their own, and times snapshot retries
+var now = time.Now
+
+// SnapshotFetcher returns the current REST order book snapshot for symbol
+type SnapshotFetcher func(symbol string) (DepthSnapshot, error)
+
+// depthBook is the sync state of one symbol's diff depth stream
+type depthBook struct {
+	synced bool
+	// bridging is set while no event has been applied on top of the snapshot,
+	// so the next one only has to straddle it rather than chain on it
+	bridging     bool
+	lastUpdateID int64
+	pending      []DepthUpdateData
+	book         *orderbook.Book
+	// fetching is set while a snapshot request is in flight
+	fetching bool
+	// snapshot is a fetched snapshot waiting to be bridged to the held events
+	snapshot *DepthSnapshot
+	// retryAt and backoff space out requests after a failed or stale snapshot
+	retryAt time.Time
+	backoff time.Duration
+}
+
+// DepthSync applies Binance's snapshot + diff rules to depthUpdate events.
+// Events are held until a snapshot covers them, every later event must chain
+// on the previous one, and a break in the chain triggers a fresh snapshot.
+// The synced events are kept in a local orderbook.Book per symbol.
+//
+// Snapshots are fetched in the background, one request per symbol at a time,
+// so Apply never waits on the REST endpoint. A snapshot that lands is bridged
+// on the symbol's next event; until then its events keep being held.
+type DepthSync struct {
+	Fetch SnapshotFetcher
+	// OnGap is called when a symbol's update ids stop chaining and it is resynced
+	OnGap func(symbol string, expected int64, got int64)
+	// OnError is called when a snapshot request fails; it is retried after a backoff
+	OnError func(symbol string, err error)
+	// MinBackoff doubles after every failed or stale snapshot, up to MaxBackoff
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	mu      sync.Mutex
+	books   map[string]*depthBook
+	fetches sync.WaitGroup
+}
+
+// NewDepthSync builds a DepthSync that takes its snapshots from fetch
+func NewDepthSync(fetch SnapshotFetcher) *DepthSync {
+	return &DepthSync{
+		Fetch:      fetch,
+		MinBackoff: defaultMinBackoff,
+		MaxBackoff: defaultMaxBackoff,
+		books:      make(map[string]*depthBook),
+	}
+}
+
+// Reset forgets every book, so each symbol is resynced from a fresh snapshot.
+// Snapshots still in flight for the old books are thrown away when they land.
+func (d *DepthSync) Reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.books = make(map[string]*depthBook)
+}
+
+// Book returns a copy of symbol's local order book, false until it is synced.
+func (d *DepthSync) Book(symbol string) (orderbook.Snapshot, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	book, exists := d.books[symbol]
+	if !exists || !book.synced {
+		return orderbook.Snapshot{}, false
+	}
+	return book.book.Snapshot(), true
+}
+
+// wait blocks until every snapshot request in flight has landed
+func (d *DepthSync) wait() {
+	d.fetches.Wait()
+}
+
+// FetchSnapshot()
+//
+// Inputs:
+//
+//	symbol : string
+//
+// Outputs:
+//
+//	DepthSnapshot
+//	error
+//
+// Description:
+//
+//	GETs the order book snapshot for symbol from config.RestURI (spot /api/v3/depth by default)
+//	through the adapter's Client, so tests can point it at a local stand-in.
+func (a *Adapter) FetchSnapshot(symbol string) (DepthSnapshot, error) {
+	depthURI := a.Config.RestURI
+	if depthURI == "" {
+		depthURI = defaultDepthURI
+	}
+
+	endpoint, err := url.Parse(depthURI)
+	if err != nil {
+		return DepthSnapshot{}, fmt.Errorf("invalid depth uri %q: %w", depthURI, err)
+	}
+	query := endpoint.Query()
+	query.Set("symbol", symbol)
+	query.Set("limit", strconv.Itoa(snapshotLimit))
+	endpoint.RawQuery = query.Encode()
+
+	resp, err := a.Client.Get(endpoint.String())
+	if err != nil {
+		return DepthSnapshot{}, fmt.Errorf("depth snapshot request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return DepthSnapshot{}, fmt.Errorf("depth snapshot request returned %s", resp.Status)
+	}
+
+	var snapshot DepthSnapshot
+	if err := json.NewDecoder(resp.Body).Decode(&snapshot); err != nil {
+		return DepthSnapshot{}, fmt.Errorf("failed to decode depth snapshot: %w", err)
+	}
+	return snapshot, nil
+}
+
+// chains reports whether update directly follows lastUpdateID
+func chains(update DepthUpdateData, lastUpdateID int64) bool {
+	if update.PrevFinalUpdateID != nil {
+		return *update.PrevFinalUpdateID == lastUpdateID
+	}
+	return update.FirstUpdateID == lastUpdateID+1
+}
+
+// bridges reports whether update, the first event after a snapshot at
+// lastUpdateID, covers the update right after it: U <= lastUpdateID+1 < u+1
+func bridges(update DepthUpdateData, lastUpdateID int64) bool {
+	return update.FirstUpdateID <= lastUpdateID+1 && update.FinalUpdateID > lastUpdateID
+}
+
+// depthRecords flattens bids and asks into one record per level
+func depthRecords(timestamp int64, symbol string, kind string, updateID int64, bids []PriceLevel, asks []PriceLevel) []utils.DepthDataStruct {
+	records := make([]utils.DepthDataStruct, 0, len(bids)+len(asks))
+	for _, side := range []struct {
+		name   string
+		levels []PriceLevel
+	}{{"bid", bids}, {"ask", asks}} {
+		for _, level := range side.levels {
+			records = append(records, utils.DepthDataStruct{
+				TimeStamp: uint64(timestamp),
+				Symbol:    symbol,
+				Kind:      kind,
+				UpdateID:  uint64(updateID),
+				Side:      side.name,
+				Price:     level[0],
+				Size:      level[1],
+			})
+		}
+	}
+	return records
+}
+
+// applyLevels writes bids and asks into book
+func applyLevels(book *orderbook.Book, bids []PriceLevel, asks []PriceLevel) error {
+	for _, side := range []struct {
+		side   orderbook.Side
+		levels []PriceLevel
+	}{{orderbook.Bid, bids}, {orderbook.Ask, asks}} {
+		for _, level := range side.levels {
+			if err := book.Apply(side.side, level[0], level[1]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func updateRecords(update DepthUpdateData) []utils.DepthDataStruct {
+	return depthRecords(update.EventTime, update.Symbol, "update", update.FinalUpdateID, update.Bids, update.Asks)
+}
+
+// retryLater pushes book's next snapshot request out by a doubling backoff
+func (d *DepthSync) retryLater(book *depthBook) {
+	switch {
+	case book.backoff < d.MinBackoff:
+		book.backoff = d.MinBackoff
+	case book.backoff*2 > d.MaxBackoff:
+		book.backoff = d.MaxBackoff
+	default:
+		book.backoff *= 2
+	}
+	book.retryAt = now().Add(book.backoff)
+}
+
+// requestSnapshot starts a background fetch for book unless one is in flight
+// or its backoff has not passed. Call it with d.mu held.
+func (d *DepthSync) requestSnapshot(symbol string, book *depthBook) {
+	if book.fetching || now().Before(book.retryAt) {
+		return
+	}
+	book.fetching = true
+	d.fetches.Add(1)
+	go func() {
+		defer d.fetches.Done()
+		snapshot, err := d.Fetch(symbol)
+
+		d.mu.Lock()
+		book.fetching = false
+		// a book dropped by Reset or a resync no longer wants this snapshot
+		current := d.books[symbol] == book
+		if err != nil {
+			d.retryLater(book)
+		} else if current {
+			book.snapshot = &snapshot
+		}
+		d.mu.Unlock()
+
+		if err != nil && current && d.OnError != nil {
+			d.OnError(symbol, err)
+		}
+	}()
+}
+
+// Apply()
+//
+// Inputs:
+//
+//	update : DepthUpdateData
+//
+// Outputs:
+//
+//	[]utils.DepthDataStruct
+//	error
+//
+// Description:
+//
+//	Runs one depthUpdate event through the documented sync rules and returns the records to persist.
+//	Until a symbol is synced its events are held and a snapshot is requested in the background; once
+//	a landed snapshot covers the first held event the snapshot levels are returned followed by every
+//	held diff after it. The first event after the snapshot, held or live, only has to straddle it;
+//	every later event that does not chain on the previous one is reported via OnGap and starts a
+//	resync. Apply never blocks on the snapshot request.
+func (d *DepthSync) Apply(update DepthUpdateData) ([]utils.DepthDataStruct, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	book, exists := d.books[update.Symbol]
+	if !exists {
+		book = &depthBook{}
+		d.books[update.Symbol] = book
+	}
+
+	if book.synced {
+		if update.FinalUpdateID <= book.lastUpdateID {
+			return nil, nil
+		}
+		follows := chains(update, book.lastUpdateID)
+		if book.bridging {
+			follows = bridges(update, book.lastUpdateID)
+		}
+		if follows {
+			if err := applyLevels(book.book, update.Bids, update.Asks); err != nil {
+				d.books[update.Symbol] = &depthBook{}
+				return nil, fmt.Errorf("failed to apply %s depth update %d: %w", update.Symbol, update.FinalUpdateID, err)
+			}
+			book.lastUpdateID = update.FinalUpdateID
+			book.bridging = false
+			return updateRecords(update), nil
+		}
+		if d.OnGap != nil {
+			d.OnGap(update.Symbol, book.lastUpdateID+1, update.FirstUpdateID)
+		}
+		book = &depthBook{}
+		d.books[update.Symbol] = book
+	}
+
+	book.pending = append(book.pending, update)
+	if len(book.pending) > maxPendingDepth {
+		book.pending = book.pending[len(book.pending)-maxPendingDepth:]
+	}
+	return d.sync(update.Symbol, book)
+}
+
+// sync replays the held events on top of a landed snapshot, or asks for one
+func (d *DepthSync) sync(symbol string, book *depthBook) ([]utils.DepthDataStruct, error) {
+	if book.snapshot == nil {
+		d.requestSnapshot(symbol, book)
+		return nil, nil
+	}
+	snapshot := *book.snapshot
+	book.snapshot = nil
+
+	// a snapshot older than everything we hold cannot be bridged, ask again after a backoff
+	if snapshot.LastUpdateID+1 < book.pending[0].FirstUpdateID {
+		d.retryLater(book)
+		d.requestSnapshot(symbol, book)
+		return nil, nil
+	}
+
+	pending := book.pending[:0]
+	for _, held := range book.pending {
+		if held.FinalUpdateID > snapshot.LastUpdateID {
+			pending = append(pending, held)
+		}
+	}
+
+	local := orderbook.New(symbol)
+	if err := applyLevels(local, snapshot.Bids, snapshot.Asks); err != nil {
+		d.retryLater(book)
+		return nil, fmt.Errorf("bad %s depth snapshot %d: %w", symbol, snapshot.LastUpdateID, err)
+	}
+
+	records := depthRecords(now().UnixMilli(), symbol, "snapshot", snapshot.LastUpdateID, snapshot.Bids, snapshot.Asks)
+	lastUpdateID := snapshot.LastUpdateID
+	for i, held := range pending {
+		if i == 0 {
+			if !bridges(held, lastUpdateID) {
+				book.pending = nil
+				return nil, fmt.Errorf("%s depth snapshot %d does not cover update %d", symbol, snapshot.LastUpdateID, held.FirstUpdateID)
+			}
+		} else if !chains(held, lastUpdateID) {
+			book.pending = nil
+			return nil, fmt.Errorf("%s held depth updates break at %d", symbol, held.FirstUpdateID)
+		}
+		if err := applyLevels(local, held.Bids, held.Asks); err != nil {
+			book.pending = nil
+			return nil, fmt.Errorf("failed to apply %s depth update %d: %w", symbol, held.FinalUpdateID, err)
+		}
+		lastUpdateID = held.FinalUpdateID
+		records = append(records, updateRecords(held)...)
+	}
+
+	book.book = local
+	book.synced = true
+	book.bridging = len(pending) == 0
+	book.lastUpdateID = lastUpdateID
+	book.pending = nil
+	book.backoff = 0
+	return records, nil
+}
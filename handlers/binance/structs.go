@@ -9,10 +9,12 @@ type GlobalMessageStruct struct {
 		EventType string `json:"e"`
 		EventTime int64  `json:"E"`
 		Symbol    string `json:"s"`
+		UpdateID  int64  `json:"u"`
 	} `json:"data"`
 	EventType string `json:"e"`
 	EventTime int64  `json:"E"`
 	Symbol    string `json:"s"`
+	UpdateID  int64  `json:"u"`
 	Result    string `json:"result"`
 	ID        int    `json:"id"`
 }
@@ -24,20 +26,33 @@ type GlobalMessagePayload struct {
 	Result    string `json:"result"`
 }
 
+// TickerData is a 24hrTicker event. Every key is declared because encoding/json
+// matches case insensitively, and "O", "C" and "Q" would otherwise overwrite
+// OpenPrice, ClosePrice and QuoteVolume.
 type TickerData struct {
-	EventType   string      `json:"e"`
-	EventTime   int64       `json:"E"`
-	Symbol      string      `json:"s"`
-	BidPrice    json.Number `json:"b"`
-	BidSize     json.Number `json:"B"`
-	AskPrice    json.Number `json:"a"`
-	AskSize     json.Number `json:"A"`
-	ClosePrice  json.Number `json:"c"`
-	OpenPrice   json.Number `json:"o"`
-	HighPrice   json.Number `json:"h"`
-	LowPrice    json.Number `json:"l"`
-	BaseVolume  json.Number `json:"v"`
-	QuoteVolume json.Number `json:"q"`
+	EventType          string      `json:"e"`
+	EventTime          int64       `json:"E"`
+	Symbol             string      `json:"s"`
+	PriceChange        json.Number `json:"p"`
+	PriceChangePercent json.Number `json:"P"`
+	WeightedAvgPrice   json.Number `json:"w"`
+	FirstTradePrice    json.Number `json:"x"`
+	LastQuantity       json.Number `json:"Q"`
+	BidPrice           json.Number `json:"b"`
+	BidSize            json.Number `json:"B"`
+	AskPrice           json.Number `json:"a"`
+	AskSize            json.Number `json:"A"`
+	ClosePrice         json.Number `json:"c"`
+	OpenPrice          json.Number `json:"o"`
+	HighPrice          json.Number `json:"h"`
+	LowPrice           json.Number `json:"l"`
+	BaseVolume         json.Number `json:"v"`
+	QuoteVolume        json.Number `json:"q"`
+	OpenTime           int64       `json:"O"`
+	CloseTime          int64       `json:"C"`
+	FirstTradeID       int64       `json:"F"`
+	LastTradeID        int64       `json:"L"`
+	TradeCount         int64       `json:"n"`
 }
 
 type TradeData struct {
@@ -51,3 +66,121 @@ type TradeData struct {
 	IsMaker   bool   `json:"m"`
 	Ignore    bool   `json:"M"`
 }
+
+// PriceLevel is a ["price","quantity"] pair as sent in depth updates and snapshots
+type PriceLevel [2]string
+
+// DepthUpdateData is a diff depth stream event. PrevFinalUpdateID (pu) is only
+// sent by the futures streams, which chain events on it instead of U.
+type DepthUpdateData struct {
+	EventType         string       `json:"e"`
+	EventTime         int64        `json:"E"`
+	TransactionTime   int64        `json:"T"`
+	Symbol            string       `json:"s"`
+	FirstUpdateID     int64        `json:"U"`
+	FinalUpdateID     int64        `json:"u"`
+	PrevFinalUpdateID *int64       `json:"pu"`
+	Bids              []PriceLevel `json:"b"`
+	Asks              []PriceLevel `json:"a"`
+}
+
+// DepthSnapshot is the REST /depth response the diff stream is synced against
+type DepthSnapshot struct {
+	LastUpdateID int64        `json:"lastUpdateId"`
+	Bids         []PriceLevel `json:"bids"`
+	Asks         []PriceLevel `json:"asks"`
+}
+
+// KlineData is a kline/candlestick stream event
+type KlineData struct {
+	EventType string       `json:"e"`
+	EventTime int64        `json:"E"`
+	Symbol    string       `json:"s"`
+	Kline     KlinePayload `json:"k"`
+}
+
+// KlinePayload declares every field Binance sends: encoding/json matches keys
+// case insensitively, so an undeclared "L" would otherwise land in Low.
+type KlinePayload struct {
+	OpenTime            int64  `json:"t"`
+	CloseTime           int64  `json:"T"`
+	Symbol              string `json:"s"`
+	Interval            string `json:"i"`
+	FirstTradeID        int64  `json:"f"`
+	LastTradeID         int64  `json:"L"`
+	Open                string `json:"o"`
+	Close               string `json:"c"`
+	High                string `json:"h"`
+	Low                 string `json:"l"`
+	Volume              string `json:"v"`
+	TradeCount          int64  `json:"n"`
+	Closed              bool   `json:"x"`
+	QuoteVolume         string `json:"q"`
+	TakerBuyBaseVolume  string `json:"V"`
+	TakerBuyQuoteVolume string `json:"Q"`
+	Ignore              string `json:"B"`
+}
+
+// MarkPriceData is a futures markPriceUpdate event. "p" and "P" differ only in
+// case, so both are declared to keep encoding/json from mixing them up.
+type MarkPriceData struct {
+	EventType            string `json:"e"`
+	EventTime            int64  `json:"E"`
+	Symbol               string `json:"s"`
+	MarkPrice            string `json:"p"`
+	IndexPrice           string `json:"i"`
+	EstimatedSettlePrice string `json:"P"`
+	FundingRate          string `json:"r"`
+	NextFundingTime      int64  `json:"T"`
+}
+
+// ForceOrderData is a futures liquidation order event
+type ForceOrderData struct {
+	EventType string           `json:"e"`
+	EventTime int64            `json:"E"`
+	Order     ForceOrderDetail `json:"o"`
+}
+
+// ForceOrderDetail declares every field so case-insensitive matching cannot
+// mix up pairs like "s"/"S"
+type ForceOrderDetail struct {
+	Symbol         string `json:"s"`
+	Side           string `json:"S"`
+	OrderType      string `json:"o"`
+	TimeInForce    string `json:"f"`
+	Quantity       string `json:"q"`
+	Price          string `json:"p"`
+	AvgPrice       string `json:"ap"`
+	Status         string `json:"X"`
+	LastFilled     string `json:"l"`
+	FilledQuantity string `json:"z"`
+	TradeTime      int64  `json:"T"`
+}
+
+// BookTickerData is a bookTicker event. Spot sends no "e" or "E", futures do.
+type BookTickerData struct {
+	EventType       string `json:"e"`
+	EventTime       int64  `json:"E"`
+	TransactionTime int64  `json:"T"`
+	UpdateID        int64  `json:"u"`
+	Symbol          string `json:"s"`
+	BidPrice        string `json:"b"`
+	BidSize         string `json:"B"`
+	AskPrice        string `json:"a"`
+	AskSize         string `json:"A"`
+}
+
+// AggTradeData is an aggTrade event; "m" and "M" differ only in case, so both are declared
+type AggTradeData struct {
+	EventType    string `json:"e"`
+	EventTime    int64  `json:"E"`
+	Symbol       string `json:"s"`
+	AggTradeID   int64  `json:"a"`
+	Price        string `json:"p"`
+	Quantity     string `json:"q"`
+	FirstTradeID int64  `json:"f"`
+	LastTradeID  int64  `json:"l"`
+	TradeTime    int64  `json:"T"`
+	IsBuyerMaker bool   `json:"m"`
+	Ignore       bool   `json:"M"`
+}
@@ -0,0 +1,332 @@
+package bitfinex
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Antkky/go_crypto_scraper/handlers/exchange"
+	"github.com/Antkky/go_crypto_scraper/utils"
+)
+
+func init() {
+	exchange.Register("bitfinex", New)
+}
+
+// now is swapped out in tests; ticker frames carry no timestamp of their own
+var now = time.Now
+
+// Adapter implements exchange.Adapter for the Bitfinex v2 public websocket.
+type Adapter struct {
+	exchange.Base
+	channels *ChannelMap
+}
+
+// New builds a Bitfinex adapter for the given config.
+func New(config utils.ExchangeConfig, logger *log.Logger) exchange.Adapter {
+	return &Adapter{
+		Base:     exchange.Base{Config: config, Logger: logger},
+		channels: NewChannelMap(config.Streams),
+	}
+}
+
+func (a *Adapter) Parse(message []byte) ([]utils.ParsedMessage, error) {
+	return ProcessMessage(message, a.channels)
+}
+
+// NewChannelMap reads the configured subscribe requests so that "subscribed"
+// events can be mapped back onto the stream's symbol and type.
+func NewChannelMap(streams []utils.StreamConfig) *ChannelMap {
+	channels := &ChannelMap{Channels: make(map[int64]*Channel)}
+	for _, stream := range streams {
+		var sub SubscribeMessage
+		if err := json.Unmarshal(stream.Message, &sub); err != nil {
+			continue
+		}
+		channels.Streams = append(channels.Streams, SubscribedStream{
+			Channel:  sub.Channel,
+			Key:      sub.Symbol,
+			Symbol:   stream.Symbol,
+			DataType: stream.Type,
+		})
+	}
+	return channels
+}
+
+// ________Small Helper Functions________
+
+// defaultDataType maps a Bitfinex channel name onto our stream types
+func defaultDataType(channel string) string {
+	switch channel {
+	case "trades":
+		return "trade"
+	case "ticker", "book":
+		return "ticker"
+	default:
+		return channel
+	}
+}
+
+// subscribe records the channel announced in a "subscribed" event
+func (m *ChannelMap) subscribe(event EventMessage) {
+	channel := &Channel{
+		Channel:  event.Channel,
+		Symbol:   strings.TrimPrefix(event.Symbol, "t"),
+		DataType: defaultDataType(event.Channel),
+		Bids:     make(map[string]json.Number),
+		Asks:     make(map[string]json.Number),
+	}
+	for _, stream := range m.Streams {
+		if stream.Channel == event.Channel && stream.Key == event.Symbol {
+			channel.Symbol = stream.Symbol
+			channel.DataType = stream.DataType
+			break
+		}
+	}
+	m.Channels[event.ChanID] = channel
+}
+
+// decodeNumbers unmarshals keeping numbers as json.Number so prices keep their exact text
+func decodeNumbers(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+func absNumber(n json.Number) string {
+	return strings.TrimPrefix(n.String(), "-")
+}
+
+func processEvent(message []byte, channels *ChannelMap) ([]utils.ParsedMessage, error) {
+	var event EventMessage
+	if err := json.Unmarshal(message, &event); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal bitfinex event: %w", err)
+	}
+
+	switch event.Event {
+	case "info":
+		// A fresh connection, channel ids from the previous one are gone
+		channels.Channels = make(map[int64]*Channel)
+		return nil, nil
+	case "subscribed":
+		channels.subscribe(event)
+		return []utils.ParsedMessage{{Event: "subscribed"}}, nil
+	case "unsubscribed":
+		delete(channels.Channels, event.ChanID)
+		return nil, nil
+	case "pong":
+		return []utils.ParsedMessage{{Event: "pong"}}, nil
+	case "error":
+		return nil, fmt.Errorf("bitfinex error %d: %s", event.Code, event.Msg)
+	default:
+		return nil, fmt.Errorf("unknown event: %s", event.Event)
+	}
+}
+
+func processTrade(channel *Channel, raw json.RawMessage) ([]utils.ParsedMessage, error) {
+	var trade []json.Number
+	if err := decodeNumbers(raw, &trade); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal trade: %w", err)
+	}
+	if len(trade) < 4 {
+		return nil, fmt.Errorf("short trade frame: %s", raw)
+	}
+
+	timestamp, err := trade[1].Int64()
+	if err != nil {
+		return nil, fmt.Errorf("invalid trade timestamp: %w", err)
+	}
+	return []utils.ParsedMessage{{
+		DataType: channel.DataType,
+		Symbol:   channel.Symbol,
+		Data: []utils.TradeDataStruct{{
+			TimeStamp: uint64(timestamp),
+			Symbol:    channel.Symbol,
+			Price:     trade[3].String(),
+			Quantity:  absNumber(trade[2]),
+			Bid_MM:    strings.HasPrefix(trade[2].String(), "-"),
+			TradeID:   trade[0].String(),
+		}},
+	}}, nil
+}
+
+func processTicker(channel *Channel, raw json.RawMessage) ([]utils.ParsedMessage, error) {
+	var ticker []json.Number
+	if err := decodeNumbers(raw, &ticker); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal ticker: %w", err)
+	}
+	if len(ticker) < 4 {
+		return nil, fmt.Errorf("short ticker frame: %s", raw)
+	}
+
+	return []utils.ParsedMessage{{
+		DataType: channel.DataType,
+		Symbol:   channel.Symbol,
+		Data: []utils.TickerDataStruct{{
+			TimeStamp: uint64(now().UnixMilli()),
+			Symbol:    channel.Symbol,
+			BidPrice:  ticker[0].String(),
+			BidSize:   ticker[1].String(),
+			AskPrice:  ticker[2].String(),
+			AskSize:   ticker[3].String(),
+		}},
+	}}, nil
+}
+
+// applyBookLevel applies one [PRICE, COUNT, AMOUNT] entry; COUNT 0 removes the level
+func applyBookLevel(channel *Channel, level []json.Number) error {
+	if len(level) < 3 {
+		return fmt.Errorf("short book level: %v", level)
+	}
+	price := level[0].String()
+	count, err := level[1].Int64()
+	if err != nil {
+		return fmt.Errorf("invalid book count: %w", err)
+	}
+	bid := !strings.HasPrefix(level[2].String(), "-")
+
+	if count == 0 {
+		delete(channel.Bids, price)
+		delete(channel.Asks, price)
+	} else if bid {
+		channel.Bids[price] = level[2]
+	} else {
+		channel.Asks[price] = level[2]
+	}
+	return nil
+}
+
+// bestLevel returns the highest (bids) or lowest (asks) price in side
+func bestLevel(side map[string]json.Number, highest bool) (string, json.Number, bool) {
+	prices := make([]string, 0, len(side))
+	for price := range side {
+		prices = append(prices, price)
+	}
+	if len(prices) == 0 {
+		return "", "", false
+	}
+	sort.Slice(prices, func(i, j int) bool {
+		a, _ := strconv.ParseFloat(prices[i], 64)
+		b, _ := strconv.ParseFloat(prices[j], 64)
+		if highest {
+			return a > b
+		}
+		return a < b
+	})
+	return prices[0], side[prices[0]], true
+}
+
+func processBook(channel *Channel, raw json.RawMessage) ([]utils.ParsedMessage, error) {
+	var levels [][]json.Number
+	if err := decodeNumbers(raw, &levels); err == nil {
+		// Snapshot: replace the whole book
+		channel.Bids = make(map[string]json.Number)
+		channel.Asks = make(map[string]json.Number)
+		for _, level := range levels {
+			if err := applyBookLevel(channel, level); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		var level []json.Number
+		if err := decodeNumbers(raw, &level); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal book update: %w", err)
+		}
+		if err := applyBookLevel(channel, level); err != nil {
+			return nil, err
+		}
+	}
+
+	bidPrice, bidSize, hasBid := bestLevel(channel.Bids, true)
+	askPrice, askSize, hasAsk := bestLevel(channel.Asks, false)
+	if !hasBid || !hasAsk {
+		return nil, nil
+	}
+	return []utils.ParsedMessage{{
+		DataType: channel.DataType,
+		Symbol:   channel.Symbol,
+		Data: []utils.TickerDataStruct{{
+			TimeStamp: uint64(now().UnixMilli()),
+			Symbol:    channel.Symbol,
+			BidPrice:  bidPrice,
+			BidSize:   absNumber(bidSize),
+			AskPrice:  askPrice,
+			AskSize:   absNumber(askSize),
+		}},
+	}}, nil
+}
+
+// ________Main Functions________
+
+// ProcessMessage()
+//
+// Inputs:
+//
+//	message  : []byte
+//	channels : *ChannelMap
+//
+// Outputs:
+//
+//	[]utils.ParsedMessage
+//	error
+//
+// Description:
+//
+//	Bitfinex data frames are arrays keyed by a numeric chanId, so instead of switching on an
+//	event type this looks the chanId up in the map built from "subscribed" events.
+//	Heartbeats and "tu" updates are dropped ("te" already carries the trade) and so are
+//	trade snapshots, which only replay history and would be written twice after a resubscribe.
+func ProcessMessage(message []byte, channels *ChannelMap) ([]utils.ParsedMessage, error) {
+	trimmed := bytes.TrimSpace(message)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		return processEvent(trimmed, channels)
+	}
+
+	var frame []json.RawMessage
+	if err := json.Unmarshal(trimmed, &frame); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal bitfinex frame: %w", err)
+	}
+	if len(frame) < 2 {
+		return nil, fmt.Errorf("short bitfinex frame: %s", message)
+	}
+
+	var chanID int64
+	if err := json.Unmarshal(frame[0], &chanID); err != nil {
+		return nil, fmt.Errorf("invalid chanId: %w", err)
+	}
+	channel, exists := channels.Channels[chanID]
+	if !exists {
+		return nil, fmt.Errorf("unknown chanId %d", chanID)
+	}
+
+	var code string
+	if err := json.Unmarshal(frame[1], &code); err == nil {
+		switch code {
+		case "hb", "tu":
+			return nil, nil
+		case "te":
+			if len(frame) < 3 {
+				return nil, fmt.Errorf("short trade frame: %s", message)
+			}
+			return processTrade(channel, frame[2])
+		default:
+			return nil, fmt.Errorf("unknown message type: %s", code)
+		}
+	}
+
+	switch channel.Channel {
+	case "trades":
+		return nil, nil
+	case "ticker":
+		return processTicker(channel, frame[1])
+	case "book":
+		return processBook(channel, frame[1])
+	default:
+		return nil, fmt.Errorf("unsupported channel: %s", channel.Channel)
+	}
+}
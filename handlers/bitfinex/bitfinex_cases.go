@@ -0,0 +1,110 @@
+package bitfinex
+
+import (
+	"github.com/Antkky/go_crypto_scraper/utils"
+)
+
+// Streams the test channel map is built from
+var testStreams = []utils.StreamConfig{
+	{Type: "trade", Symbol: "BTCUSD", Message: []byte(`{"event":"subscribe","channel":"trades","symbol":"tBTCUSD"}`)},
+	{Type: "ticker", Symbol: "BTCUSD", Message: []byte(`{"event":"subscribe","channel":"ticker","symbol":"tBTCUSD"}`)},
+	{Type: "ticker", Symbol: "ETHUSD", Message: []byte(`{"event":"subscribe","channel":"book","symbol":"tETHUSD","prec":"P0","len":"25"}`)},
+}
+
+const (
+	subscribedTrades = `{"event":"subscribed","channel":"trades","chanId":17470,"symbol":"tBTCUSD","pair":"BTCUSD"}`
+	subscribedTicker = `{"event":"subscribed","channel":"ticker","chanId":224555,"symbol":"tBTCUSD","pair":"BTCUSD"}`
+	subscribedBook   = `{"event":"subscribed","channel":"book","chanId":333,"symbol":"tETHUSD","prec":"P0","freq":"F0","len":"25","pair":"ETHUSD"}`
+)
+
+// Test Cases for ProcessMessage. Messages are fed in order through the same
+// channel map and only the result of the last one is checked.
+var ProcessMessageCases = []struct {
+	name      string
+	messages  []string
+	want      []utils.ParsedMessage
+	wantError bool
+}{
+	{
+		name:     "subscribed event",
+		messages: []string{subscribedTrades},
+		want:     []utils.ParsedMessage{{Event: "subscribed"}},
+	},
+	{
+		name:     "trade execution",
+		messages: []string{subscribedTrades, `[17470,"te",[401597395,1574694478808,-0.005,7245.3]]`},
+		want: []utils.ParsedMessage{{
+			DataType: "trade",
+			Symbol:   "BTCUSD",
+			Data: []utils.TradeDataStruct{{
+				TimeStamp: 1574694478808,
+				Symbol:    "BTCUSD",
+				Price:     "7245.3",
+				Quantity:  "0.005",
+				Bid_MM:    true,
+				TradeID:   "401597395",
+			}},
+		}},
+	},
+	{
+		name:     "trade update is not written twice",
+		messages: []string{subscribedTrades, `[17470,"tu",[401597395,1574694478808,-0.005,7245.3]]`},
+		want:     nil,
+	},
+	{
+		name:     "trade snapshot is skipped",
+		messages: []string{subscribedTrades, `[17470,[[401597393,1574694475039,0.005,7244.9],[401597394,1574694478807,0.1,7245]]]`},
+		want:     nil,
+	},
+	{
+		name:     "heartbeat",
+		messages: []string{subscribedTicker, `[224555,"hb"]`},
+		want:     nil,
+	},
+	{
+		name:     "ticker",
+		messages: []string{subscribedTicker, `[224555,[7616.5,31.89055171,7617.5,43.358118629999986,-550.8,-0.0674,7617.1,8314.71200815,8257.8,7500]]`},
+		want: []utils.ParsedMessage{{
+			DataType: "ticker",
+			Symbol:   "BTCUSD",
+			Data: []utils.TickerDataStruct{{
+				TimeStamp: 1700000000000,
+				Symbol:    "BTCUSD",
+				BidPrice:  "7616.5",
+				BidSize:   "31.89055171",
+				AskPrice:  "7617.5",
+				AskSize:   "43.358118629999986",
+			}},
+		}},
+	},
+	{
+		name: "book snapshot then update",
+		messages: []string{
+			subscribedBook,
+			`[333,[[2000.1,2,1.5],[2000,1,3],[2000.3,1,-2],[2000.5,3,-0.7]]]`,
+			`[333,[2000.3,0,-1]]`,
+		},
+		want: []utils.ParsedMessage{{
+			DataType: "ticker",
+			Symbol:   "ETHUSD",
+			Data: []utils.TickerDataStruct{{
+				TimeStamp: 1700000000000,
+				Symbol:    "ETHUSD",
+				BidPrice:  "2000.1",
+				BidSize:   "1.5",
+				AskPrice:  "2000.5",
+				AskSize:   "0.7",
+			}},
+		}},
+	},
+	{
+		name:      "info resets channel ids",
+		messages:  []string{subscribedTrades, `{"event":"info","version":2,"platform":{"status":1}}`, `[17470,"hb"]`},
+		wantError: true,
+	},
+	{
+		name:      "error event",
+		messages:  []string{`{"event":"error","msg":"symbol: invalid","code":10300}`},
+		wantError: true,
+	},
+}
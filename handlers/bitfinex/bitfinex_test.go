@@ -0,0 +1,32 @@
+package bitfinex
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Antkky/go_crypto_scraper/handlers/exchange/exchangetest"
+	"github.com/Antkky/go_crypto_scraper/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessMessage(t *testing.T) {
+	now = func() time.Time { return time.UnixMilli(1700000000000) }
+	defer func() { now = time.Now }()
+
+	for _, tt := range ProcessMessageCases {
+		t.Run(tt.name, func(t *testing.T) {
+			channels := NewChannelMap(testStreams)
+
+			parsed, err := exchangetest.ProcessAll(t, tt.messages, func(message []byte) ([]utils.ParsedMessage, error) {
+				return ProcessMessage(message, channels)
+			})
+
+			if tt.wantError {
+				assert.Error(t, err, "Expected an error but got none")
+				return
+			}
+			assert.NoError(t, err, "Unexpected error occurred")
+			assert.Equal(t, tt.want, parsed)
+		})
+	}
+}
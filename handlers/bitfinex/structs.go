@@ -0,0 +1,45 @@
+package bitfinex
+
+import "encoding/json"
+
+// EventMessage covers every object-shaped frame: info, subscribed, error and pong
+type EventMessage struct {
+	Event   string `json:"event"`
+	Channel string `json:"channel"`
+	ChanID  int64  `json:"chanId"`
+	Symbol  string `json:"symbol"`
+	Pair    string `json:"pair"`
+	Msg     string `json:"msg"`
+	Code    int    `json:"code"`
+	Version int    `json:"version"`
+}
+
+// SubscribeMessage is the subscribe request as written in the stream config
+type SubscribeMessage struct {
+	Event   string `json:"event"`
+	Channel string `json:"channel"`
+	Symbol  string `json:"symbol"`
+}
+
+// Channel is what a numeric chanId stands for, learned from the "subscribed" event
+type Channel struct {
+	Channel  string
+	Symbol   string
+	DataType string
+	Bids     map[string]json.Number
+	Asks     map[string]json.Number
+}
+
+// ChannelMap tracks chanId -> Channel for the current connection
+type ChannelMap struct {
+	Channels map[int64]*Channel
+	Streams  []SubscribedStream
+}
+
+// SubscribedStream links a configured subscribe request to its buffer symbol and type
+type SubscribedStream struct {
+	Channel  string
+	Symbol   string
+	Key      string
+	DataType string
+}
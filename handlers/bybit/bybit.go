@@ -0,0 +1,165 @@
+package bybit
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/Antkky/go_crypto_scraper/handlers/exchange"
+	"github.com/Antkky/go_crypto_scraper/utils"
+	"github.com/gorilla/websocket"
+)
+
+func init() {
+	exchange.Register("bybit", New)
+}
+
+// Adapter implements exchange.Adapter for the Bybit v5 public spot and linear streams.
+type Adapter struct {
+	exchange.Base
+	books map[string]*TopOfBook
+}
+
+// New builds a Bybit adapter for the given config.
+func New(config utils.ExchangeConfig, logger *log.Logger) exchange.Adapter {
+	return &Adapter{
+		Base:  exchange.Base{Config: config, Logger: logger},
+		books: make(map[string]*TopOfBook),
+	}
+}
+
+func (a *Adapter) Parse(message []byte) ([]utils.ParsedMessage, error) {
+	return ProcessMessage(message, a.books)
+}
+
+// Heartbeat sends Bybit's {"op":"ping"} unless a custom ping is configured.
+// Bybit drops connections that stay silent for more than 20 seconds.
+func (a *Adapter) Heartbeat(conn *websocket.Conn) error {
+	if len(a.Config.Ping) > 0 {
+		return a.Base.Heartbeat(conn)
+	}
+	return conn.WriteMessage(websocket.TextMessage, []byte(`{"op":"ping"}`))
+}
+
+// ________Small Helper Functions________
+
+// applyLevels updates one side of the top of book. A size of "0" means the level was removed.
+func applyLevels(side *Level, levels [][2]string) {
+	for _, l := range levels {
+		if isZero(l[1]) {
+			*side = Level{}
+		} else {
+			*side = Level{Price: l[0], Size: l[1]}
+		}
+	}
+}
+
+func isZero(size string) bool {
+	return strings.Trim(strings.ReplaceAll(size, ".", ""), "0") == ""
+}
+
+// processOp handles the replies to subscribe and ping requests
+func processOp(pMessage GlobalMessageStruct) ([]utils.ParsedMessage, error) {
+	if pMessage.Success != nil && !*pMessage.Success {
+		return nil, fmt.Errorf("bybit %s request failed: %s", pMessage.Op, pMessage.RetMsg)
+	}
+	switch {
+	case pMessage.Op == "subscribe":
+		return []utils.ParsedMessage{{Event: "subscribed"}}, nil
+	case pMessage.Op == "ping", pMessage.Op == "pong", pMessage.RetMsg == "pong":
+		return []utils.ParsedMessage{{Event: "pong"}}, nil
+	default:
+		return nil, fmt.Errorf("unknown op reply: %s", pMessage.Op)
+	}
+}
+
+// ________Main Functions________
+
+// ProcessMessage()
+//
+// Inputs:
+//
+//	message : []byte
+//	books   : map[string]*TopOfBook
+//
+// Outputs:
+//
+//	[]utils.ParsedMessage
+//	error
+//
+// Description:
+//
+//	routes orderbook.1 and publicTrade pushes into the shared structs.
+//	books keeps the current top of book per symbol so deltas can be applied.
+func ProcessMessage(message []byte, books map[string]*TopOfBook) ([]utils.ParsedMessage, error) {
+	var pMessage GlobalMessageStruct
+	if err := json.Unmarshal(message, &pMessage); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal bybit message: %w", err)
+	}
+
+	if pMessage.Op != "" {
+		return processOp(pMessage)
+	}
+
+	switch {
+	case strings.HasPrefix(pMessage.Topic, "orderbook.1."):
+		var bookMsg OrderBookData
+		if err := json.Unmarshal(pMessage.Data, &bookMsg); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal orderbook data: %w", err)
+		}
+
+		book, exists := books[bookMsg.Symbol]
+		if !exists || pMessage.Type == "snapshot" {
+			book = &TopOfBook{}
+			books[bookMsg.Symbol] = book
+		}
+		applyLevels(&book.Bid, bookMsg.Bids)
+		applyLevels(&book.Ask, bookMsg.Asks)
+
+		if book.Bid.Price == "" || book.Ask.Price == "" {
+			return nil, nil
+		}
+		return []utils.ParsedMessage{{
+			DataType: "ticker",
+			Symbol:   bookMsg.Symbol,
+			Data: []utils.TickerDataStruct{{
+				TimeStamp: uint64(pMessage.TS),
+				Symbol:    bookMsg.Symbol,
+				BidPrice:  book.Bid.Price,
+				BidSize:   book.Bid.Size,
+				AskPrice:  book.Ask.Price,
+				AskSize:   book.Ask.Size,
+			}},
+		}}, nil
+
+	case strings.HasPrefix(pMessage.Topic, "publicTrade."):
+		var tradeMsg []TradeData
+		if err := json.Unmarshal(pMessage.Data, &tradeMsg); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal trade data: %w", err)
+		}
+		if len(tradeMsg) == 0 {
+			return nil, nil
+		}
+
+		trades := make([]utils.TradeDataStruct, 0, len(tradeMsg))
+		for _, trade := range tradeMsg {
+			trades = append(trades, utils.TradeDataStruct{
+				TimeStamp: uint64(trade.TradeTime),
+				Symbol:    trade.Symbol,
+				Price:     trade.Price,
+				Quantity:  trade.Volume,
+				Bid_MM:    trade.Side == "Sell",
+				TradeID:   trade.TradeID,
+			})
+		}
+		return []utils.ParsedMessage{{
+			DataType: "trade",
+			Symbol:   tradeMsg[0].Symbol,
+			Data:     trades,
+		}}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown message type: %s", pMessage.Topic)
+	}
+}
@@ -0,0 +1,96 @@
+package bybit
+
+import (
+	"github.com/Antkky/go_crypto_scraper/utils"
+)
+
+// Test Cases for ProcessMessage. Messages are fed in order through the same
+// book state and only the result of the last one is checked.
+var ProcessMessageCases = []struct {
+	name      string
+	messages  []string
+	want      []utils.ParsedMessage
+	wantError bool
+}{
+	{
+		name: "orderbook.1 snapshot",
+		messages: []string{
+			`{"topic":"orderbook.1.BTCUSDT","type":"snapshot","ts":1672304484978,"data":{"s":"BTCUSDT","b":[["16493.50","0.006"]],"a":[["16611.00","0.029"]],"u":18521288,"seq":7961638724},"cts":1672304484976}`,
+		},
+		want: []utils.ParsedMessage{{
+			DataType: "ticker",
+			Symbol:   "BTCUSDT",
+			Data: []utils.TickerDataStruct{{
+				TimeStamp: 1672304484978,
+				Symbol:    "BTCUSDT",
+				BidPrice:  "16493.50",
+				BidSize:   "0.006",
+				AskPrice:  "16611.00",
+				AskSize:   "0.029",
+			}},
+		}},
+	},
+	{
+		name: "orderbook.1 delta keeps untouched side",
+		messages: []string{
+			`{"topic":"orderbook.1.ETHUSDT","type":"snapshot","ts":1672304484978,"data":{"s":"ETHUSDT","b":[["1200.10","2"]],"a":[["1200.20","3"]],"u":1,"seq":1}}`,
+			`{"topic":"orderbook.1.ETHUSDT","type":"delta","ts":1672304485000,"data":{"s":"ETHUSDT","b":[],"a":[["1200.30","1.5"]],"u":2,"seq":2}}`,
+		},
+		want: []utils.ParsedMessage{{
+			DataType: "ticker",
+			Symbol:   "ETHUSDT",
+			Data: []utils.TickerDataStruct{{
+				TimeStamp: 1672304485000,
+				Symbol:    "ETHUSDT",
+				BidPrice:  "1200.10",
+				BidSize:   "2",
+				AskPrice:  "1200.30",
+				AskSize:   "1.5",
+			}},
+		}},
+	},
+	{
+		name: "orderbook.1 delta empties a side",
+		messages: []string{
+			`{"topic":"orderbook.1.SOLUSDT","type":"snapshot","ts":1,"data":{"s":"SOLUSDT","b":[["20.1","2"]],"a":[["20.2","3"]],"u":1,"seq":1}}`,
+			`{"topic":"orderbook.1.SOLUSDT","type":"delta","ts":2,"data":{"s":"SOLUSDT","b":[["20.1","0"]],"a":[],"u":2,"seq":2}}`,
+		},
+		want: nil,
+	},
+	{
+		name: "publicTrade",
+		messages: []string{
+			`{"topic":"publicTrade.BTCUSDT","type":"snapshot","ts":1672304486868,"data":[
+				{"T":1672304486865,"s":"BTCUSDT","S":"Buy","v":"0.001","p":"16578.50","L":"PlusTick","i":"20f43950-d8dd-5b31-9112-a178eb6023af","BT":false},
+				{"T":1672304486866,"s":"BTCUSDT","S":"Sell","v":"0.002","p":"16578.00","L":"MinusTick","i":"20f43950-d8dd-5b31-9112-a178eb6023b0","BT":false}]}`,
+		},
+		want: []utils.ParsedMessage{{
+			DataType: "trade",
+			Symbol:   "BTCUSDT",
+			Data: []utils.TradeDataStruct{
+				{TimeStamp: 1672304486865, Symbol: "BTCUSDT", Price: "16578.50", Quantity: "0.001", Bid_MM: false, TradeID: "20f43950-d8dd-5b31-9112-a178eb6023af"},
+				{TimeStamp: 1672304486866, Symbol: "BTCUSDT", Price: "16578.00", Quantity: "0.002", Bid_MM: true, TradeID: "20f43950-d8dd-5b31-9112-a178eb6023b0"},
+			},
+		}},
+	},
+	{
+		name:     "subscribe ack",
+		messages: []string{`{"success":true,"ret_msg":"subscribe","conn_id":"2324d924-aa4d-45b0-a858-7b8be29ab52b","req_id":"10001","op":"subscribe"}`},
+		want:     []utils.ParsedMessage{{Event: "subscribed"}},
+	},
+	{
+		name:     "pong",
+		messages: []string{`{"success":true,"ret_msg":"pong","conn_id":"0970e817-426e-429a-a679-ff7f55e0b16a","op":"ping"}`},
+		want:     []utils.ParsedMessage{{Event: "pong"}},
+	},
+	{
+		name:      "failed subscription",
+		messages:  []string{`{"success":false,"ret_msg":"error:handler not found,topic:orderbook.1.NOPE","conn_id":"abc","op":"subscribe"}`},
+		wantError: true,
+	},
+	{
+		name:      "unknown topic",
+		messages:  []string{`{"topic":"liquidation.BTCUSDT","type":"snapshot","ts":1,"data":{}}`},
+		wantError: true,
+	},
+}
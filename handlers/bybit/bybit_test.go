@@ -0,0 +1,28 @@
+package bybit
+
+import (
+	"testing"
+
+	"github.com/Antkky/go_crypto_scraper/handlers/exchange/exchangetest"
+	"github.com/Antkky/go_crypto_scraper/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessMessage(t *testing.T) {
+	for _, tt := range ProcessMessageCases {
+		t.Run(tt.name, func(t *testing.T) {
+			books := make(map[string]*TopOfBook)
+
+			parsed, err := exchangetest.ProcessAll(t, tt.messages, func(message []byte) ([]utils.ParsedMessage, error) {
+				return ProcessMessage(message, books)
+			})
+
+			if tt.wantError {
+				assert.Error(t, err, "Expected an error but got none")
+				return
+			}
+			assert.NoError(t, err, "Unexpected error occurred")
+			assert.Equal(t, tt.want, parsed)
+		})
+	}
+}
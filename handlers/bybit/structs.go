@@ -0,0 +1,47 @@
+package bybit
+
+import "encoding/json"
+
+// Global Message Struct, covers data pushes as well as op replies
+type GlobalMessageStruct struct {
+	Topic   string          `json:"topic"`
+	Type    string          `json:"type"`
+	TS      int64           `json:"ts"`
+	Data    json.RawMessage `json:"data"`
+	Op      string          `json:"op"`
+	Success *bool           `json:"success"`
+	RetMsg  string          `json:"ret_msg"`
+	ConnID  string          `json:"conn_id"`
+	ReqID   string          `json:"req_id"`
+}
+
+type OrderBookData struct {
+	Symbol   string      `json:"s"`
+	Bids     [][2]string `json:"b"`
+	Asks     [][2]string `json:"a"`
+	UpdateID int64       `json:"u"`
+	Seq      int64       `json:"seq"`
+}
+
+type TradeData struct {
+	TradeTime  int64  `json:"T"`
+	Symbol     string `json:"s"`
+	Side       string `json:"S"`
+	Volume     string `json:"v"`
+	Price      string `json:"p"`
+	Direction  string `json:"L"`
+	TradeID    string `json:"i"`
+	BlockTrade bool   `json:"BT"`
+}
+
+// Level is one side of the top of book
+type Level struct {
+	Price string
+	Size  string
+}
+
+// TopOfBook is the best bid and ask kept per symbol
+type TopOfBook struct {
+	Bid Level
+	Ask Level
+}
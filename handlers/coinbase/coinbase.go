@@ -0,0 +1,302 @@
+package coinbase
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Antkky/go_crypto_scraper/handlers/exchange"
+	"github.com/Antkky/go_crypto_scraper/utils"
+	"github.com/gorilla/websocket"
+)
+
+func init() {
+	exchange.Register("coinbase", New)
+}
+
+// channels maps our stream types onto Advanced Trade channels
+var channels = map[string]string{
+	"trade":  "market_trades",
+	"ticker": "ticker",
+}
+
+// heartbeatsSubscription keeps otherwise quiet subscriptions from being closed by Coinbase
+var heartbeatsSubscription = json.RawMessage(`{"type":"subscribe","channel":"heartbeats"}`)
+
+// Adapter implements exchange.Adapter for the Coinbase Advanced Trade market data websocket.
+type Adapter struct {
+	exchange.Base
+	state *State
+}
+
+// New builds a Coinbase adapter for the given config.
+func New(config utils.ExchangeConfig, logger *log.Logger) exchange.Adapter {
+	state := NewState(config.Streams)
+	state.OnGap = func(channel string, expected int64, got int64) {
+		logger.Printf("⚠️ %s %s sequence gap: expected %d, got %d", config.Name, channel, expected, got)
+	}
+	return &Adapter{
+		Base:  exchange.Base{Config: config, Logger: logger},
+		state: state,
+	}
+}
+
+func (a *Adapter) Parse(message []byte) ([]utils.ParsedMessage, error) {
+	return ProcessMessage(message, a.state)
+}
+
+// Subscribe sends the configured message for each stream, or builds one from
+// the stream's type and symbol, then subscribes to heartbeats. Sequence numbers
+// restart with every connection, so the trackers are marked for a reset.
+func (a *Adapter) Subscribe(conn *websocket.Conn) error {
+	a.state.Reconnected()
+
+	messages := make([]json.RawMessage, 0, len(a.Config.Streams)+1)
+	for _, stream := range a.Config.Streams {
+		if len(stream.Message) > 0 {
+			messages = append(messages, stream.Message)
+			continue
+		}
+		message, err := SubscribeMessageFor(stream)
+		if err != nil {
+			return err
+		}
+		messages = append(messages, message)
+	}
+	messages = append(messages, heartbeatsSubscription)
+	return a.SendSubscriptions(conn, messages)
+}
+
+// ________Small Helper Functions________
+
+// ProductID converts a symbol such as BTCUSD into Coinbase's BTC-USD form
+func ProductID(symbol string) string {
+	if strings.Contains(symbol, "-") {
+		return symbol
+	}
+	if base, quote, ok := utils.SplitSymbol(symbol); ok {
+		return base + "-" + quote
+	}
+	return symbol
+}
+
+// SubscribeMessageFor builds the subscribe request for a stream without an explicit message
+func SubscribeMessageFor(stream utils.StreamConfig) (json.RawMessage, error) {
+	channel, exists := channels[stream.Type]
+	if !exists {
+		return nil, fmt.Errorf("coinbase: unsupported stream type %q", stream.Type)
+	}
+	return json.Marshal(SubscribeMessage{
+		Type:       "subscribe",
+		ProductIDs: []string{ProductID(stream.Symbol)},
+		Channel:    channel,
+	})
+}
+
+// NewState maps every configured channel/product to the stream's symbol and type
+func NewState(streams []utils.StreamConfig) *State {
+	state := &State{
+		Subscriptions: make(map[SubscriptionKey]Subscription),
+		Sequence:      make(map[string]int64),
+		LastTradeID:   make(map[string]int64),
+	}
+	for _, stream := range streams {
+		subscription := Subscription{Symbol: stream.Symbol, DataType: stream.Type}
+
+		var sub SubscribeMessage
+		if len(stream.Message) > 0 && json.Unmarshal(stream.Message, &sub) == nil && len(sub.ProductIDs) > 0 {
+			for _, product := range sub.ProductIDs {
+				state.Subscriptions[SubscriptionKey{Channel: sub.Channel, ProductID: product}] = subscription
+			}
+			continue
+		}
+		if channel, exists := channels[stream.Type]; exists {
+			state.Subscriptions[SubscriptionKey{Channel: channel, ProductID: ProductID(stream.Symbol)}] = subscription
+		}
+	}
+	return state
+}
+
+// lookup finds the stream a product belongs to, falling back to the product id with the dash removed
+func (s *State) lookup(channel string, productID string) Subscription {
+	if subscription, exists := s.Subscriptions[SubscriptionKey{Channel: channel, ProductID: productID}]; exists {
+		return subscription
+	}
+	dataType := "ticker"
+	if channel == "market_trades" {
+		dataType = "trade"
+	}
+	return Subscription{Symbol: strings.ReplaceAll(productID, "-", ""), DataType: dataType}
+}
+
+// Reconnected marks the sequence trackers stale. It only sets a flag, so it is
+// safe to call from the supervisor while the consumer is still draining frames
+// from the old connection; those frames are checked against the old trackers
+// and the reset happens when the new connection's subscriptions ack is parsed.
+func (s *State) Reconnected() {
+	s.reconnected.Store(true)
+}
+
+// checkSequence reports whether the message should be processed. Replayed or
+// out of order sequence numbers are dropped; skipped ones are reported via OnGap.
+func (s *State) checkSequence(channel string, sequence int64) bool {
+	last, seen := s.Sequence[channel]
+	if seen && sequence <= last {
+		return false
+	}
+	if seen && sequence > last+1 && s.OnGap != nil {
+		s.OnGap(channel, last+1, sequence)
+	}
+	s.Sequence[channel] = sequence
+	return true
+}
+
+// parseTimestamp converts Coinbase's RFC3339 timestamps into unix milliseconds
+func parseTimestamp(timestamp string) (uint64, error) {
+	t, err := time.Parse(time.RFC3339Nano, timestamp)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timestamp %q: %w", timestamp, err)
+	}
+	return uint64(t.UnixMilli()), nil
+}
+
+// processTrades groups trades per product and drops any already written. Coinbase
+// trade ids go up by one per product, so skipped ids are reported as a gap.
+func processTrades(events json.RawMessage, state *State) ([]utils.ParsedMessage, error) {
+	var tradeEvents []TradeEvent
+	if err := json.Unmarshal(events, &tradeEvents); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal market_trades events: %w", err)
+	}
+
+	var parsed []utils.ParsedMessage
+	index := make(map[string]int)
+	for _, event := range tradeEvents {
+		// Snapshots arrive newest first
+		for i := len(event.Trades) - 1; i >= 0; i-- {
+			trade := event.Trades[i]
+			subscription := state.lookup("market_trades", trade.ProductID)
+			if id, err := strconv.ParseInt(trade.TradeID, 10, 64); err == nil {
+				if last, seen := state.LastTradeID[trade.ProductID]; seen {
+					if id <= last {
+						continue
+					}
+					if gap, missed := utils.TradeIDGap(subscription.Symbol, last, id); missed {
+						parsed = append(parsed, utils.ParsedMessage{DataType: "gap", Symbol: subscription.Symbol, Data: []utils.GapDataStruct{gap}})
+					}
+				}
+				state.LastTradeID[trade.ProductID] = id
+			}
+
+			timestamp, err := parseTimestamp(trade.Time)
+			if err != nil {
+				return nil, err
+			}
+			record := utils.TradeDataStruct{
+				TimeStamp: timestamp,
+				Symbol:    subscription.Symbol,
+				Price:     trade.Price,
+				Quantity:  trade.Size,
+				Bid_MM:    trade.Side == "SELL",
+				TradeID:   trade.TradeID,
+			}
+
+			j, exists := index[trade.ProductID]
+			if !exists {
+				j = len(parsed)
+				index[trade.ProductID] = j
+				parsed = append(parsed, utils.ParsedMessage{
+					DataType: subscription.DataType,
+					Symbol:   subscription.Symbol,
+					Data:     []utils.TradeDataStruct{},
+				})
+			}
+			parsed[j].Data = append(parsed[j].Data.([]utils.TradeDataStruct), record)
+		}
+	}
+	return parsed, nil
+}
+
+func processTickers(events json.RawMessage, timestamp uint64, state *State) ([]utils.ParsedMessage, error) {
+	var tickerEvents []TickerEvent
+	if err := json.Unmarshal(events, &tickerEvents); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal ticker events: %w", err)
+	}
+
+	var parsed []utils.ParsedMessage
+	for _, event := range tickerEvents {
+		for _, ticker := range event.Tickers {
+			subscription := state.lookup("ticker", ticker.ProductID)
+			parsed = append(parsed, utils.ParsedMessage{
+				DataType: subscription.DataType,
+				Symbol:   subscription.Symbol,
+				Data: []utils.TickerDataStruct{{
+					TimeStamp: timestamp,
+					Symbol:    subscription.Symbol,
+					BidPrice:  ticker.BestBid,
+					BidSize:   ticker.BestBidQuantity,
+					AskPrice:  ticker.BestAsk,
+					AskSize:   ticker.BestAskQuantity,
+				}},
+			})
+		}
+	}
+	return parsed, nil
+}
+
+// ________Main Functions________
+
+// ProcessMessage()
+//
+// Inputs:
+//
+//	message : []byte
+//	state   : *State
+//
+// Outputs:
+//
+//	[]utils.ParsedMessage
+//	error
+//
+// Description:
+//
+//	routes market_trades and ticker pushes into the shared structs after
+//	checking the channel's sequence number. Heartbeats only advance the sequence.
+//	Only the consumer goroutine may call it; after Reconnected the trackers are
+//	reset by the next subscriptions ack.
+func ProcessMessage(message []byte, state *State) ([]utils.ParsedMessage, error) {
+	var pMessage GlobalMessageStruct
+	if err := json.Unmarshal(message, &pMessage); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal coinbase message: %w", err)
+	}
+
+	if pMessage.Type == "error" {
+		return nil, fmt.Errorf("coinbase error: %s", pMessage.Message)
+	}
+	// The first ack of a new connection starts its sequence numbers
+	if pMessage.Channel == "subscriptions" && state.reconnected.CompareAndSwap(true, false) {
+		state.Sequence = make(map[string]int64)
+	}
+	if !state.checkSequence(pMessage.Channel, pMessage.SequenceNum) {
+		return nil, nil
+	}
+
+	switch pMessage.Channel {
+	case "subscriptions":
+		return []utils.ParsedMessage{{Event: "subscribed"}}, nil
+	case "heartbeats":
+		return nil, nil
+	case "market_trades":
+		return processTrades(pMessage.Events, state)
+	case "ticker", "ticker_batch":
+		timestamp, err := parseTimestamp(pMessage.Timestamp)
+		if err != nil {
+			return nil, err
+		}
+		return processTickers(pMessage.Events, timestamp, state)
+	default:
+		return nil, fmt.Errorf("unknown message type: %s", pMessage.Channel)
+	}
+}
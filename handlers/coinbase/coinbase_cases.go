@@ -0,0 +1,106 @@
+package coinbase
+
+import (
+	"github.com/Antkky/go_crypto_scraper/utils"
+)
+
+// Streams the test state is built from
+var testStreams = []utils.StreamConfig{
+	{Type: "trade", Symbol: "BTCUSD", Market: "spot"},
+	{Type: "ticker", Symbol: "BTCUSD", Market: "spot"},
+}
+
+const (
+	tradeSnapshot = `{"channel":"market_trades","client_id":"","timestamp":"2023-02-09T20:19:35.39625135Z","sequence_num":1,"events":[{"type":"snapshot","trades":[
+		{"trade_id":"501","product_id":"BTC-USD","price":"21920.01","size":"0.3","side":"SELL","time":"2023-02-09T20:19:35.1Z"},
+		{"trade_id":"500","product_id":"BTC-USD","price":"21920.00","size":"0.1","side":"BUY","time":"2023-02-09T20:19:35.0Z"}]}]}`
+	tradeUpdate = `{"channel":"market_trades","client_id":"","timestamp":"2023-02-09T20:19:36Z","sequence_num":2,"events":[{"type":"update","trades":[
+		{"trade_id":"502","product_id":"BTC-USD","price":"21921","size":"0.02","side":"BUY","time":"2023-02-09T20:19:36Z"}]}]}`
+)
+
+// Test Cases for ProcessMessage. Messages are fed in order through the same
+// state and only the result of the last one is checked.
+var ProcessMessageCases = []struct {
+	name      string
+	messages  []string
+	want      []utils.ParsedMessage
+	wantGaps  int
+	wantError bool
+}{
+	{
+		name:     "market_trades snapshot is written oldest first",
+		messages: []string{tradeSnapshot},
+		want: []utils.ParsedMessage{{
+			DataType: "trade",
+			Symbol:   "BTCUSD",
+			Data: []utils.TradeDataStruct{
+				{TimeStamp: 1675973975000, Symbol: "BTCUSD", Price: "21920.00", Quantity: "0.1", Bid_MM: false, TradeID: "500"},
+				{TimeStamp: 1675973975100, Symbol: "BTCUSD", Price: "21920.01", Quantity: "0.3", Bid_MM: true, TradeID: "501"},
+			},
+		}},
+	},
+	{
+		name:     "replayed sequence number is dropped",
+		messages: []string{tradeSnapshot, tradeUpdate, tradeUpdate},
+		want:     nil,
+	},
+	{
+		name: "sequence gap is reported but data kept",
+		messages: []string{
+			`{"channel":"heartbeats","timestamp":"2023-06-23T20:31:26Z","sequence_num":1,"events":[{"current_time":"2023-06-23 20:31:56 +0000 UTC","heartbeat_counter":"3049"}]}`,
+			`{"channel":"heartbeats","timestamp":"2023-06-23T20:31:27Z","sequence_num":4,"events":[{"current_time":"2023-06-23 20:31:57 +0000 UTC","heartbeat_counter":"3050"}]}`,
+		},
+		want:     nil,
+		wantGaps: 1,
+	},
+	{
+		name:     "ticker",
+		messages: []string{`{"channel":"ticker","client_id":"","timestamp":"2023-02-09T20:30:37.167Z","sequence_num":0,"events":[{"type":"snapshot","tickers":[{"type":"ticker","product_id":"BTC-USD","price":"21932.98","volume_24_h":"16038.28770938","low_24_h":"21835.29","high_24_h":"23011.18","low_52_w":"15460","high_52_w":"48240","price_percent_chg_24h":"-4.15775596190603","best_bid":"21931.98","best_bid_quantity":"8000.21","best_ask":"21933.98","best_ask_quantity":"8038.07770938"}]}]}`},
+		want: []utils.ParsedMessage{{
+			DataType: "ticker",
+			Symbol:   "BTCUSD",
+			Data: []utils.TickerDataStruct{{
+				TimeStamp: 1675974637167,
+				Symbol:    "BTCUSD",
+				BidPrice:  "21931.98",
+				BidSize:   "8000.21",
+				AskPrice:  "21933.98",
+				AskSize:   "8038.07770938",
+			}},
+		}},
+	},
+	{
+		name:     "subscriptions ack",
+		messages: []string{`{"channel":"subscriptions","client_id":"","timestamp":"2023-02-09T20:32:59Z","sequence_num":0,"events":[{"subscriptions":{"ticker":["BTC-USD"]}}]}`},
+		want:     []utils.ParsedMessage{{Event: "subscribed"}},
+	},
+	{
+		name:      "error",
+		messages:  []string{`{"type":"error","message":"failure to subscribe"}`},
+		wantError: true,
+	},
+}
+
+// Test Cases for State.Reconnected. oldFrames were queued by the previous
+// connection before Subscribe ran, newFrames come from the new one; every frame
+// of the new connection must be kept even though its sequence numbers restart.
+var ReconnectCases = []struct {
+	name       string
+	oldFrames  []string
+	newFrames  []string
+	wantTrades int
+}{
+	{
+		name:      "old frames drain before the new connection's ack",
+		oldFrames: []string{tradeSnapshot, tradeUpdate},
+		newFrames: []string{
+			`{"channel":"subscriptions","client_id":"","timestamp":"2023-02-09T20:20:00Z","sequence_num":0,"events":[{"subscriptions":{"market_trades":["BTC-USD"]}}]}`,
+			`{"channel":"market_trades","client_id":"","timestamp":"2023-02-09T20:20:01Z","sequence_num":1,"events":[{"type":"update","trades":[
+				{"trade_id":"503","product_id":"BTC-USD","price":"21922","size":"0.01","side":"BUY","time":"2023-02-09T20:20:01Z"}]}]}`,
+			`{"channel":"subscriptions","client_id":"","timestamp":"2023-02-09T20:20:01Z","sequence_num":1,"events":[{"subscriptions":{"heartbeats":["heartbeats"]}}]}`,
+			`{"channel":"market_trades","client_id":"","timestamp":"2023-02-09T20:20:02Z","sequence_num":2,"events":[{"type":"update","trades":[
+				{"trade_id":"504","product_id":"BTC-USD","price":"21923","size":"0.01","side":"SELL","time":"2023-02-09T20:20:02Z"}]}]}`,
+		},
+		wantTrades: 5,
+	},
+}
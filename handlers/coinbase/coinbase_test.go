@@ -0,0 +1,68 @@
+package coinbase
+
+import (
+	"testing"
+
+	"github.com/Antkky/go_crypto_scraper/handlers/exchange/exchangetest"
+	"github.com/Antkky/go_crypto_scraper/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProductID(t *testing.T) {
+	assert.Equal(t, "BTC-USD", ProductID("BTCUSD"))
+	assert.Equal(t, "ETH-USDC", ProductID("ETHUSDC"))
+	assert.Equal(t, "SOL-USD", ProductID("SOL-USD"))
+}
+
+func TestProcessMessage(t *testing.T) {
+	for _, tt := range ProcessMessageCases {
+		t.Run(tt.name, func(t *testing.T) {
+			state := NewState(testStreams)
+			gaps := 0
+			state.OnGap = func(channel string, expected int64, got int64) { gaps++ }
+
+			parsed, err := exchangetest.ProcessAll(t, tt.messages, func(message []byte) ([]utils.ParsedMessage, error) {
+				return ProcessMessage(message, state)
+			})
+
+			if tt.wantError {
+				assert.Error(t, err, "Expected an error but got none")
+				return
+			}
+			assert.NoError(t, err, "Unexpected error occurred")
+			assert.Equal(t, tt.want, parsed)
+			assert.Equal(t, tt.wantGaps, gaps, "unexpected number of sequence gaps")
+		})
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	for _, tt := range ReconnectCases {
+		t.Run(tt.name, func(t *testing.T) {
+			state := NewState(testStreams)
+			gaps := 0
+			state.OnGap = func(channel string, expected int64, got int64) { gaps++ }
+
+			trades := 0
+			consume := func(frames []string) {
+				for i, message := range frames {
+					parsed, err := ProcessMessage([]byte(message), state)
+					assert.NoError(t, err, "frame %d", i)
+					for _, msg := range parsed {
+						if msg.DataType == "trade" {
+							trades += len(msg.Data.([]utils.TradeDataStruct))
+						}
+					}
+				}
+			}
+
+			// Subscribe runs on the supervisor while the old frames are still queued
+			state.Reconnected()
+			consume(tt.oldFrames)
+			consume(tt.newFrames)
+
+			assert.Equal(t, tt.wantTrades, trades)
+			assert.Zero(t, gaps, "unexpected sequence gaps")
+		})
+	}
+}
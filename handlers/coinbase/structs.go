@@ -0,0 +1,80 @@
+package coinbase
+
+import (
+	"encoding/json"
+	"sync/atomic"
+)
+
+// SubscribeMessage is a single channel subscribe request
+type SubscribeMessage struct {
+	Type       string   `json:"type"`
+	ProductIDs []string `json:"product_ids,omitempty"`
+	Channel    string   `json:"channel"`
+}
+
+// Global Message Struct, every push shares this envelope
+type GlobalMessageStruct struct {
+	Type        string          `json:"type"`
+	Message     string          `json:"message"`
+	Channel     string          `json:"channel"`
+	Timestamp   string          `json:"timestamp"`
+	SequenceNum int64           `json:"sequence_num"`
+	Events      json.RawMessage `json:"events"`
+}
+
+type TradeEvent struct {
+	Type   string      `json:"type"`
+	Trades []TradeData `json:"trades"`
+}
+
+type TradeData struct {
+	TradeID   string `json:"trade_id"`
+	ProductID string `json:"product_id"`
+	Price     string `json:"price"`
+	Size      string `json:"size"`
+	Side      string `json:"side"`
+	Time      string `json:"time"`
+}
+
+type TickerEvent struct {
+	Type    string       `json:"type"`
+	Tickers []TickerData `json:"tickers"`
+}
+
+type TickerData struct {
+	Type            string `json:"type"`
+	ProductID       string `json:"product_id"`
+	Price           string `json:"price"`
+	BestBid         string `json:"best_bid"`
+	BestBidQuantity string `json:"best_bid_quantity"`
+	BestAsk         string `json:"best_ask"`
+	BestAskQuantity string `json:"best_ask_quantity"`
+}
+
+// Subscription maps a Coinbase channel and product back to the configured stream
+type Subscription struct {
+	Symbol   string
+	DataType string
+}
+
+// SubscriptionKey identifies a channel for one product such as BTC-USD
+type SubscriptionKey struct {
+	Channel   string
+	ProductID string
+}
+
+// State is what the adapter remembers across messages on a connection
+type State struct {
+	Subscriptions map[SubscriptionKey]Subscription
+	// Sequence is the last sequence_num seen per channel
+	Sequence map[string]int64
+	// LastTradeID is the highest trade id written per product, so snapshot
+	// trades replayed after a resubscribe are not written twice
+	LastTradeID map[string]int64
+	// OnGap is called when a channel skips sequence numbers
+	OnGap func(channel string, expected int64, got int64)
+
+	// reconnected is set by Subscribe on the supervisor goroutine and cleared by the
+	// consumer when the new connection's first subscriptions ack resets Sequence
+	reconnected atomic.Bool
+}
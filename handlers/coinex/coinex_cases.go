@@ -1 +1,230 @@
 package coinex
+
+import (
+	"fmt"
+
+	"github.com/Antkky/go_crypto_scraper/utils"
+	"github.com/Antkky/go_crypto_scraper/utils/codec"
+)
+
+// gzipped compresses a test payload the way Coinex frames arrive on the wire
+func gzipped(payload string) []byte {
+	compressed, _ := codec.Gzip([]byte(payload))
+	return compressed
+}
+
+// Test Cases for ProcessMessage
+var ProcessMessageCases = []struct {
+	name      string
+	message   []byte
+	want      []utils.ParsedMessage
+	wantError bool
+}{
+	{
+		name: "bbo update",
+		message: gzipped(`{"method":"bbo.update","data":{"market":"BTCUSDT","updated_at":1642145331234,
+			"best_bid_price":"20000","best_bid_size":"0.1","best_ask_price":"20001","best_ask_size":"0.15"},"id":null}`),
+		want: []utils.ParsedMessage{{
+			DataType: "ticker",
+			Symbol:   "BTCUSDT",
+			Data: []utils.TickerDataStruct{{
+				TimeStamp: 1642145331234,
+				Symbol:    "BTCUSDT",
+				BidPrice:  "20000",
+				BidSize:   "0.1",
+				AskPrice:  "20001",
+				AskSize:   "0.15",
+			}},
+		}},
+	},
+	{
+		name: "deals update",
+		message: gzipped(`{"method":"deals.update","data":{"market":"BTCUSDT","deal_list":[
+			{"deal_id":3514376759,"created_at":1689152421692,"side":"buy","price":"30718.42","amount":"0.00000325"},
+			{"deal_id":3514376758,"created_at":1689152421692,"side":"sell","price":"30718.42","amount":"0.00015729"}]},"id":null}`),
+		want: []utils.ParsedMessage{{
+			DataType: "trade",
+			Symbol:   "BTCUSDT",
+			Data: []utils.TradeDataStruct{
+				{TimeStamp: 1689152421692, Symbol: "BTCUSDT", Price: "30718.42", Quantity: "0.00000325", Bid_MM: false, TradeID: "3514376759"},
+				{TimeStamp: 1689152421692, Symbol: "BTCUSDT", Price: "30718.42", Quantity: "0.00015729", Bid_MM: true, TradeID: "3514376758"},
+			},
+		}},
+	},
+	{
+		name: "futures state update",
+		message: gzipped(`{"method":"state.update","data":{"state_list":[{"market":"BTCUSDT","last":"30000.1","open":"29000","close":"30000.1",
+			"high":"30100","low":"28900","volume":"1000","value":"30000000","volume_sell":"500","volume_buy":"500","open_interest_size":"100",
+			"insurance_fund_size":"1000000","mark_price":"30000.05","index_price":"30001.2","latest_funding_rate":"0.0001",
+			"next_funding_rate":"0.00012","latest_funding_time":1689152400000,"next_funding_time":1689181200000,"period":86400}]},"id":null}`),
+		want: []utils.ParsedMessage{{
+			DataType: "funding",
+			Symbol:   "BTCUSDT",
+			Data: []utils.FundingDataStruct{{
+				TimeStamp:       1700000000000,
+				Symbol:          "BTCUSDT",
+				MarkPrice:       "30000.05",
+				IndexPrice:      "30001.2",
+				FundingRate:     "0.0001",
+				NextFundingTime: 1689181200000,
+			}},
+		}},
+	},
+	{
+		name:    "subscribe ack",
+		message: gzipped(`{"id":1,"code":0,"message":"OK"}`),
+		want:    []utils.ParsedMessage{{Event: "subscribed"}},
+	},
+	{
+		name:    "pong",
+		message: gzipped(`{"id":1,"code":0,"message":"OK","data":{"result":"pong"}}`),
+		want:    []utils.ParsedMessage{{Event: "pong"}},
+	},
+	{
+		name:      "not gzipped",
+		message:   []byte(`{"method":"bbo.update"}`),
+		wantError: true,
+	},
+}
+
+// depthStreams subscribes BTCUSDT depth limited to two levels a side
+var depthStreams = []utils.StreamConfig{{
+	Type:    "depth",
+	Symbol:  "BTCUSDT",
+	Market:  "spot",
+	Message: []byte(`{"method":"depth.subscribe","params":{"market_list":[["BTCUSDT",2,"0",true]]},"id":1}`),
+}}
+
+// depthUpdate builds a gzipped depth.update push
+func depthUpdate(full bool, bids string, asks string, checksum uint32) []byte {
+	return gzipped(fmt.Sprintf(`{"method":"depth.update","data":{"market":"BTCUSDT","is_full":%t,"depth":{"bids":%s,"asks":%s,"last":"30000.5","updated_at":1689152421692,"checksum":%d}},"id":null}`,
+		full, bids, asks, checksum))
+}
+
+func depthRecord(kind string, side string, price string, size string) utils.DepthDataStruct {
+	return utils.DepthDataStruct{TimeStamp: 1689152421692, Symbol: "BTCUSDT", Kind: kind, Side: side, Price: price, Size: size}
+}
+
+// Test Cases for the Coinex depth book, applied in order to one adapter
+var DepthCases = []struct {
+	name    string
+	message []byte
+	want    []utils.ParsedMessage
+}{
+	{
+		name:    "full depth",
+		message: depthUpdate(true, `[["30000","1"],["29999","2"]]`, `[["30001","3"]]`, 3425646693),
+		want: []utils.ParsedMessage{{DataType: "depth", Symbol: "BTCUSDT", Data: []utils.DepthDataStruct{
+			depthRecord("snapshot", "bid", "30000", "1"),
+			depthRecord("snapshot", "bid", "29999", "2"),
+			depthRecord("snapshot", "ask", "30001", "3"),
+		}}},
+	},
+	{
+		name:    "incremental update removes and adds levels",
+		message: depthUpdate(false, `[["29999","0"]]`, `[["30002","4"]]`, 4028953160),
+		want: []utils.ParsedMessage{{DataType: "depth", Symbol: "BTCUSDT", Data: []utils.DepthDataStruct{
+			depthRecord("update", "bid", "29999", "0"),
+			depthRecord("update", "ask", "30002", "4"),
+		}}},
+	},
+	{
+		name:    "levels beyond the subscribed limit are trimmed",
+		message: depthUpdate(false, `[]`, `[["30000.5","1"]]`, 400986608),
+		want: []utils.ParsedMessage{{DataType: "depth", Symbol: "BTCUSDT", Data: []utils.DepthDataStruct{
+			depthRecord("update", "ask", "30000.5", "1"),
+		}}},
+	},
+	{
+		name:    "checksum mismatch resubscribes",
+		message: depthUpdate(false, `[["30000","2"]]`, `[]`, 1),
+		want: []utils.ParsedMessage{
+			{Event: "resubscribe", Reply: []byte(`{"method":"depth.unsubscribe","params":{"market_list":["BTCUSDT"]},"id":1001}`)},
+			{Event: "resubscribe", Reply: []byte(`{"method":"depth.subscribe","params":{"market_list":[["BTCUSDT",2,"0",true]]},"id":1002}`)},
+		},
+	},
+	{
+		name:    "incremental update before the next full depth is ignored",
+		message: depthUpdate(false, `[["30000","3"]]`, `[]`, 0),
+		want:    []utils.ParsedMessage{},
+	},
+	{
+		name:    "full depth after resubscribe",
+		message: depthUpdate(true, `[["30000","1"],["29999","2"]]`, `[["30001","3"]]`, 3425646693),
+		want: []utils.ParsedMessage{{DataType: "depth", Symbol: "BTCUSDT", Data: []utils.DepthDataStruct{
+			depthRecord("snapshot", "bid", "30000", "1"),
+			depthRecord("snapshot", "bid", "29999", "2"),
+			depthRecord("snapshot", "ask", "30001", "3"),
+		}}},
+	},
+}
+
+// klineStreams polls BTCUSDT one minute candles; ETHUSDT has no Coinex period and is left out
+var klineStreams = []utils.StreamConfig{
+	{Type: "kline", Symbol: "BTCUSDT", Market: "spot", Interval: "1m"},
+	{Type: "kline", Symbol: "ETHUSDT", Market: "spot", Interval: "7m"},
+}
+
+func kline(openTime uint64, closePrice string, closed bool) utils.KlineDataStruct {
+	return utils.KlineDataStruct{
+		TimeStamp: 1700000000000,
+		Symbol:    "BTCUSDT",
+		Interval:  "1m",
+		OpenTime:  openTime,
+		CloseTime: openTime + 59999,
+		Open:      "30000",
+		High:      "30010",
+		Low:       "29990",
+		Close:     closePrice,
+		Volume:    "1.5",
+		Closed:    closed,
+	}
+}
+
+// Test Cases for Coinex candles: REST kline responses, polled in order by one adapter
+var KlineCases = []struct {
+	name      string
+	response  string
+	want      []utils.ParsedMessage
+	wantError bool
+}{
+	{
+		name: "first poll closes the older candle",
+		response: `{"code":0,"data":[` +
+			`{"market":"BTCUSDT","created_at":1699999940000,"open":"30000","close":"30005","high":"30010","low":"29990","volume":"1.5","value":"45000"},` +
+			`{"market":"BTCUSDT","created_at":1700000000000,"open":"30000","close":"30001","high":"30010","low":"29990","volume":"1.5","value":"45000"}` +
+			`],"message":"OK"}`,
+		want: []utils.ParsedMessage{{DataType: "kline", Symbol: "BTCUSDT", Data: []utils.KlineDataStruct{
+			kline(1699999940000, "30005", false),
+			kline(1699999940000, "30005", true),
+			kline(1700000000000, "30001", false),
+		}}},
+	},
+	{
+		name: "open candle updates and the closed one is not repeated",
+		response: `{"code":0,"data":[` +
+			`{"market":"BTCUSDT","created_at":1699999940000,"open":"30000","close":"30005","high":"30010","low":"29990","volume":"1.5","value":"45000"},` +
+			`{"market":"BTCUSDT","created_at":1700000000000,"open":"30000","close":"30004","high":"30010","low":"29990","volume":"1.5","value":"45000"}` +
+			`],"message":"OK"}`,
+		want: []utils.ParsedMessage{{DataType: "kline", Symbol: "BTCUSDT", Data: []utils.KlineDataStruct{
+			kline(1700000000000, "30004", false),
+		}}},
+	},
+	{
+		name: "next candle closes the open one with its final values, newest first",
+		response: `{"code":0,"data":[` +
+			`{"market":"BTCUSDT","created_at":1700000060000,"open":"30000","close":"30002","high":"30010","low":"29990","volume":"1.5","value":"45000"},` +
+			`{"market":"BTCUSDT","created_at":1700000000000,"open":"30000","close":"30006","high":"30010","low":"29990","volume":"1.5","value":"45000"}` +
+			`],"message":"OK"}`,
+		want: []utils.ParsedMessage{{DataType: "kline", Symbol: "BTCUSDT", Data: []utils.KlineDataStruct{
+			kline(1700000000000, "30006", false),
+			kline(1700000000000, "30006", true),
+			kline(1700000060000, "30002", false),
+		}}},
+	},
+	{
+		name:      "error response",
+		response:  `{"code":3008,"data":{},"message":"Service busy"}`,
+		wantError: true,
+	},
+}
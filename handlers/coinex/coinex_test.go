@@ -1 +1,87 @@
 package coinex
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Antkky/go_crypto_scraper/utils"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessMessage(t *testing.T) {
+	now = func() time.Time { return time.UnixMilli(1700000000000) }
+	defer func() { now = time.Now }()
+
+	for _, tt := range ProcessMessageCases {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := ProcessMessage(tt.message)
+			if tt.wantError {
+				assert.Error(t, err, "Expected an error but got none")
+				return
+			}
+			assert.NoError(t, err, "Unexpected error occurred")
+			assert.Equal(t, tt.want, parsed)
+		})
+	}
+}
+
+func TestDepth(t *testing.T) {
+	config := utils.ExchangeConfig{Name: "Coinex Test", Streams: depthStreams}
+	adapter := New(config, log.New(io.Discard, "", 0))
+
+	for _, tt := range DepthCases {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := adapter.Parse(tt.message)
+			assert.NoError(t, err, "Unexpected error occurred")
+			assert.Equal(t, tt.want, parsed)
+		})
+	}
+}
+
+func TestKlines(t *testing.T) {
+	now = func() time.Time { return time.UnixMilli(1700000000000) }
+	defer func() { now = time.Now }()
+
+	var response string
+	var requests []*url.URL
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL)
+		io.WriteString(w, response)
+	}))
+	defer server.Close()
+
+	config := utils.ExchangeConfig{Name: "Coinex Test", RestURI: server.URL, Streams: klineStreams}
+	adapter := New(config, log.New(io.Discard, "", 0)).(*Adapter)
+
+	for _, tt := range KlineCases {
+		t.Run(tt.name, func(t *testing.T) {
+			response = tt.response
+			var frames [][]byte
+			// a closed stop lets Poll make exactly one round
+			stop := make(chan struct{})
+			close(stop)
+			adapter.Poll(func(frame []byte) { frames = append(frames, frame) }, stop)
+
+			request := requests[len(requests)-1]
+			assert.Equal(t, "/spot/kline", request.Path)
+			assert.Equal(t, url.Values{"market": {"BTCUSDT"}, "period": {"1min"}, "limit": {"2"}}, request.Query())
+			if tt.wantError {
+				assert.Empty(t, frames, "Expected a failed poll to emit nothing")
+				return
+			}
+			if !assert.Len(t, frames, 1) {
+				return
+			}
+			parsed, err := adapter.Parse(frames[0])
+			assert.NoError(t, err, "Unexpected error occurred")
+			assert.Equal(t, tt.want, parsed)
+		})
+	}
+	assert.Len(t, requests, len(KlineCases), "only streams with a Coinex period are polled")
+}
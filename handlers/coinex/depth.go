@@ -0,0 +1,210 @@
+package coinex
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"strings"
+	"sync"
+
+	"github.com/Antkky/go_crypto_scraper/utils"
+	"github.com/Antkky/go_crypto_scraper/utils/orderbook"
+)
+
+// defaultDepthLimit is used when the subscribe message does not say how many levels were asked for
+const defaultDepthLimit = 50
+
+// depthSubscription is one [market, limit, interval, if_full] entry of a depth.subscribe
+type depthSubscription struct {
+	entry json.RawMessage
+	limit int
+}
+
+// DepthState rebuilds every subscribed market's book from depth.update pushes
+// and checks it against the checksum Coinex sends with each one.
+type DepthState struct {
+	// OnMismatch is called when a book fails its checksum and is resubscribed
+	OnMismatch func(market string, want uint32, got uint32)
+
+	mu            sync.Mutex
+	subscriptions map[string]depthSubscription
+	books         map[string]*orderbook.Book
+	nextID        int
+}
+
+// NewDepthState reads the depth.subscribe messages in streams so the state
+// knows each market's depth limit and how to resubscribe it.
+func NewDepthState(streams []utils.StreamConfig) *DepthState {
+	state := &DepthState{
+		subscriptions: make(map[string]depthSubscription),
+		books:         make(map[string]*orderbook.Book),
+		nextID:        1000,
+	}
+	for _, stream := range streams {
+		if stream.Type != "depth" || len(stream.Message) == 0 {
+			continue
+		}
+		var sub SubscribeMessage
+		if err := json.Unmarshal(stream.Message, &sub); err != nil || sub.Method != "depth.subscribe" {
+			continue
+		}
+		for _, entry := range sub.Params.MarketList {
+			var fields []json.RawMessage
+			if err := json.Unmarshal(entry, &fields); err != nil || len(fields) == 0 {
+				continue
+			}
+			var market string
+			if err := json.Unmarshal(fields[0], &market); err != nil {
+				continue
+			}
+			limit := defaultDepthLimit
+			if len(fields) > 1 {
+				json.Unmarshal(fields[1], &limit)
+			}
+			state.subscriptions[market] = depthSubscription{entry: entry, limit: limit}
+		}
+	}
+	return state
+}
+
+// Reset drops every book; Coinex sends a full depth again after subscribing.
+func (s *DepthState) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.books = make(map[string]*orderbook.Book)
+}
+
+// ________Small Helper Functions________
+
+// levels converts the wire pairs into order book levels
+func levels(pairs []PriceLevel) []orderbook.Level {
+	converted := make([]orderbook.Level, len(pairs))
+	for i, pair := range pairs {
+		converted[i] = orderbook.Level{Price: pair[0], Size: pair[1]}
+	}
+	return converted
+}
+
+// Checksum()
+//
+// Inputs:
+//
+//	bids : []orderbook.Level
+//	asks : []orderbook.Level
+//
+// Outputs:
+//
+//	uint32
+//
+// Description:
+//
+//	CRC32 (IEEE) of "bid1_price:bid1_amount:bid2_price:...:ask1_price:ask1_amount:...",
+//	bids best first then asks best first, which is what Coinex sends as depth.checksum.
+func Checksum(bids []orderbook.Level, asks []orderbook.Level) uint32 {
+	parts := make([]string, 0, 2*(len(bids)+len(asks)))
+	for _, level := range bids {
+		parts = append(parts, level.Price, level.Size)
+	}
+	for _, level := range asks {
+		parts = append(parts, level.Price, level.Size)
+	}
+	return crc32.ChecksumIEEE([]byte(strings.Join(parts, ":")))
+}
+
+// resubscribe builds the unsubscribe and subscribe replies that make Coinex send a fresh full depth
+func (s *DepthState) resubscribe(market string) []utils.ParsedMessage {
+	subscription, exists := s.subscriptions[market]
+	if !exists {
+		subscription = depthSubscription{entry: json.RawMessage(fmt.Sprintf(`[%q,%d,"0",true]`, market, defaultDepthLimit))}
+	}
+	s.nextID++
+	unsubscribe := fmt.Sprintf(`{"method":"depth.unsubscribe","params":{"market_list":[%q]},"id":%d}`, market, s.nextID)
+	s.nextID++
+	subscribe := fmt.Sprintf(`{"method":"depth.subscribe","params":{"market_list":[%s]},"id":%d}`, subscription.entry, s.nextID)
+	return []utils.ParsedMessage{
+		{Event: "resubscribe", Reply: []byte(unsubscribe)},
+		{Event: "resubscribe", Reply: []byte(subscribe)},
+	}
+}
+
+func depthRecords(timestamp int64, market string, kind string, bids []PriceLevel, asks []PriceLevel) []utils.DepthDataStruct {
+	records := make([]utils.DepthDataStruct, 0, len(bids)+len(asks))
+	for _, side := range []struct {
+		name   string
+		levels []PriceLevel
+	}{{"bid", bids}, {"ask", asks}} {
+		for _, level := range side.levels {
+			records = append(records, utils.DepthDataStruct{
+				TimeStamp: uint64(timestamp),
+				Symbol:    market,
+				Kind:      kind,
+				Side:      side.name,
+				Price:     level[0],
+				Size:      level[1],
+			})
+		}
+	}
+	return records
+}
+
+// ________Main Functions________
+
+// Apply()
+//
+// Inputs:
+//
+//	update : DepthDataPayload
+//
+// Outputs:
+//
+//	[]utils.ParsedMessage
+//
+// Description:
+//
+//	Applies a full or incremental depth.update to the market's book and verifies the checksum.
+//	A matching update is returned as depth records, a full depth as "snapshot" and an incremental
+//	one as "update". On a mismatch the book is dropped, nothing is recorded, and the returned
+//	messages carry the unsubscribe/subscribe replies that fetch a new full depth. Incremental
+//	updates that arrive before the next full depth are ignored.
+func (s *DepthState) Apply(update DepthDataPayload) []utils.ParsedMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	limit := defaultDepthLimit
+	if subscription, exists := s.subscriptions[update.Market]; exists {
+		limit = subscription.limit
+	}
+
+	book, exists := s.books[update.Market]
+	if update.IsFull {
+		book = orderbook.New(update.Market)
+		s.books[update.Market] = book
+	} else if !exists {
+		return nil
+	}
+
+	// a level that does not parse leaves the book unusable, same as a failed checksum
+	if book.ApplyLevels(orderbook.Bid, levels(update.Depth.Bids)) != nil || book.ApplyLevels(orderbook.Ask, levels(update.Depth.Asks)) != nil {
+		delete(s.books, update.Market)
+		return s.resubscribe(update.Market)
+	}
+	book.Truncate(limit)
+
+	if got := Checksum(book.Top(orderbook.Bid, 0), book.Top(orderbook.Ask, 0)); got != update.Depth.Checksum {
+		if s.OnMismatch != nil {
+			s.OnMismatch(update.Market, update.Depth.Checksum, got)
+		}
+		delete(s.books, update.Market)
+		return s.resubscribe(update.Market)
+	}
+
+	kind := "update"
+	if update.IsFull {
+		kind = "snapshot"
+	}
+	return []utils.ParsedMessage{{
+		DataType: "depth",
+		Symbol:   update.Market,
+		Data:     depthRecords(update.Depth.UpdatedAt, update.Market, kind, update.Depth.Bids, update.Depth.Asks),
+	}}
+}
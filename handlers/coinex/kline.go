@@ -0,0 +1,232 @@
+package coinex
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Antkky/go_crypto_scraper/utils"
+	"github.com/Antkky/go_crypto_scraper/utils/codec"
+)
+
+const (
+	// defaultRestURI is the v2 API root candles are polled from when config.RestURI is not set
+	defaultRestURI = "https://api.coinex.com/v2"
+	// klineLimit asks for the open candle and the one before it, so a candle
+	// that closed between two polls is still seen with its final values
+	klineLimit = 2
+	// klinePollInterval is how often every kline stream is polled
+	klinePollInterval = 10 * time.Second
+)
+
+// klinePeriod is a Coinex period name and the candle length it stands for
+type klinePeriod struct {
+	name   string
+	length time.Duration
+}
+
+// klinePeriods maps the Binance style interval of a stream to the Coinex period
+var klinePeriods = map[string]klinePeriod{
+	"1m":  {"1min", time.Minute},
+	"3m":  {"3min", 3 * time.Minute},
+	"5m":  {"5min", 5 * time.Minute},
+	"15m": {"15min", 15 * time.Minute},
+	"30m": {"30min", 30 * time.Minute},
+	"1h":  {"1hour", time.Hour},
+	"2h":  {"2hour", 2 * time.Hour},
+	"4h":  {"4hour", 4 * time.Hour},
+	"6h":  {"6hour", 6 * time.Hour},
+	"12h": {"12hour", 12 * time.Hour},
+	"1d":  {"1day", 24 * time.Hour},
+	"3d":  {"3day", 72 * time.Hour},
+	"1w":  {"1week", 7 * 24 * time.Hour},
+}
+
+// klineStream is a kline stream from the config, resolved to what the REST request needs
+type klineStream struct {
+	symbol   string
+	market   string
+	interval string
+	period   klinePeriod
+}
+
+// KlineState remembers the open candle of every polled market. Coinex has no
+// candle push on the v2 socket and its candles carry no closed flag, so they
+// are polled and a candle is emitted as closed once a later one starts.
+type KlineState struct {
+	mu      sync.Mutex
+	streams []klineStream
+	open    map[string]utils.KlineDataStruct
+}
+
+// NewKlineState picks the kline streams out of streams; those with an interval
+// Coinex has no period for are left out.
+func NewKlineState(streams []utils.StreamConfig) *KlineState {
+	state := &KlineState{open: make(map[string]utils.KlineDataStruct)}
+	for _, stream := range streams {
+		period, ok := klinePeriods[stream.Interval]
+		if stream.Type != "kline" || !ok {
+			continue
+		}
+		market := "spot"
+		if stream.Market == "futures" {
+			market = "futures"
+		}
+		state.streams = append(state.streams, klineStream{
+			symbol:   stream.Symbol,
+			market:   market,
+			interval: stream.Interval,
+			period:   period,
+		})
+	}
+	return state
+}
+
+// fetchKlines()
+//
+// Inputs:
+//
+//	stream : klineStream
+//
+// Outputs:
+//
+//	[]byte
+//	error
+//
+// Description:
+//
+//	Requests the last klineLimit candles of the stream from the REST API and wraps them in a
+//	gzipped kline.poll frame, so they reach Parse through the queue like a websocket frame.
+func (a *Adapter) fetchKlines(stream klineStream) ([]byte, error) {
+	restURI := a.Config.RestURI
+	if restURI == "" {
+		restURI = defaultRestURI
+	}
+
+	endpoint, err := url.Parse(restURI + "/" + stream.market + "/kline")
+	if err != nil {
+		return nil, fmt.Errorf("invalid rest uri %q: %w", restURI, err)
+	}
+	query := endpoint.Query()
+	query.Set("market", stream.symbol)
+	query.Set("period", stream.period.name)
+	query.Set("limit", strconv.Itoa(klineLimit))
+	endpoint.RawQuery = query.Encode()
+
+	resp, err := a.Client.Get(endpoint.String())
+	if err != nil {
+		return nil, fmt.Errorf("kline request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var response KlineResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to decode kline response (%s): %w", resp.Status, err)
+	}
+	if response.Code != 0 {
+		return nil, fmt.Errorf("kline request returned code %d: %s", response.Code, response.Message)
+	}
+
+	frame, err := json.Marshal(KlineData{
+		Method: "kline.poll",
+		Data:   KlineDataPayload{Market: stream.symbol, Interval: stream.interval, Klines: response.Data},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal kline frame: %w", err)
+	}
+	return codec.Gzip(frame)
+}
+
+// Poll requests every kline stream right away and then every klinePollInterval
+// until stop is closed. A failed request is logged and tried again next round.
+func (a *Adapter) Poll(emit func(frame []byte), stop <-chan struct{}) {
+	if len(a.klines.streams) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(klinePollInterval)
+	defer ticker.Stop()
+
+	for {
+		for _, stream := range a.klines.streams {
+			frame, err := a.fetchKlines(stream)
+			if err != nil {
+				a.Logger.Printf("❌ %s %s kline poll failed: %v", a.Config.Name, stream.symbol, err)
+				continue
+			}
+			emit(frame)
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Apply()
+//
+// Inputs:
+//
+//	payload : KlineDataPayload
+//
+// Outputs:
+//
+//	[]utils.ParsedMessage
+//
+// Description:
+//
+//	Turns the candles of one poll into kline records. Every candle at or after the open one is
+//	returned as in progress; when the candle time moves on, the previous candle is returned first
+//	with Closed set. Candles older than the open one were already closed and are dropped.
+func (s *KlineState) Apply(payload KlineDataPayload) []utils.ParsedMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	period, ok := klinePeriods[payload.Interval]
+	if !ok {
+		return nil
+	}
+
+	klines := append([]Kline(nil), payload.Klines...)
+	sort.Slice(klines, func(i, j int) bool { return klines[i].CreatedAt < klines[j].CreatedAt })
+
+	timestamp := uint64(now().UnixMilli())
+	candles := make([]utils.KlineDataStruct, 0, len(klines)+1)
+	for _, kline := range klines {
+		candle := utils.KlineDataStruct{
+			TimeStamp: timestamp,
+			Symbol:    payload.Market,
+			Interval:  payload.Interval,
+			OpenTime:  uint64(kline.CreatedAt),
+			CloseTime: uint64(kline.CreatedAt + period.length.Milliseconds() - 1),
+			Open:      kline.Open,
+			High:      kline.High,
+			Low:       kline.Low,
+			Close:     kline.Close,
+			Volume:    kline.Volume,
+		}
+
+		previous, exists := s.open[payload.Market]
+		if exists && candle.OpenTime < previous.OpenTime {
+			continue
+		}
+		if exists && candle.OpenTime > previous.OpenTime {
+			previous.TimeStamp = timestamp
+			previous.Closed = true
+			candles = append(candles, previous)
+		}
+		s.open[payload.Market] = candle
+		candles = append(candles, candle)
+	}
+
+	if len(candles) == 0 {
+		return nil
+	}
+	return []utils.ParsedMessage{{DataType: "kline", Symbol: payload.Market, Data: candles}}
+}
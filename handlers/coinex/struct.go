@@ -5,6 +5,7 @@ import "encoding/json"
 type GlobalMessageStruct struct {
 	Method  string          `json:"method"`
 	Data    json.RawMessage `json:"data"`
+	Params  json.RawMessage `json:"params"`
 	Id      int             `json:"id"`
 	Code    int             `json:"code"`
 	Message string          `json:"message"`
@@ -47,3 +48,90 @@ type Trade struct {
 	Price      string `json:"price"`
 	Amount     string `json:"amount"`
 }
+
+// PriceLevel is a ["price","amount"] pair as sent in depth updates
+type PriceLevel [2]string
+
+type DepthData struct {
+	Method  string           `json:"method"`
+	Data    DepthDataPayload `json:"data"`
+	Id      int              `json:"id"`
+	Code    int              `json:"code"`
+	Message string           `json:"message"`
+}
+
+type DepthDataPayload struct {
+	Market string      `json:"market"`
+	IsFull bool        `json:"is_full"`
+	Depth  DepthLevels `json:"depth"`
+}
+
+type DepthLevels struct {
+	Asks      []PriceLevel `json:"asks"`
+	Bids      []PriceLevel `json:"bids"`
+	Last      string       `json:"last"`
+	UpdatedAt int64        `json:"updated_at"`
+	Checksum  uint32       `json:"checksum"`
+}
+
+// SubscribeMessage is a {"method":"depth.subscribe","params":{"market_list":[...]},"id":1} request
+type SubscribeMessage struct {
+	Method string `json:"method"`
+	Params struct {
+		MarketList []json.RawMessage `json:"market_list"`
+	} `json:"params"`
+	Id int `json:"id"`
+}
+
+// StateData is a futures state.update push
+type StateData struct {
+	Method string           `json:"method"`
+	Data   StateDataPayload `json:"data"`
+	Id     int              `json:"id"`
+}
+
+type StateDataPayload struct {
+	StateList []MarketState `json:"state_list"`
+}
+
+type MarketState struct {
+	Market            string `json:"market"`
+	Last              string `json:"last"`
+	MarkPrice         string `json:"mark_price"`
+	IndexPrice        string `json:"index_price"`
+	LatestFundingRate string `json:"latest_funding_rate"`
+	NextFundingRate   string `json:"next_funding_rate"`
+	LatestFundingTime int64  `json:"latest_funding_time"`
+	NextFundingTime   int64  `json:"next_funding_time"`
+}
+
+// KlineData is a kline.poll frame, built by Poll from a REST kline response
+type KlineData struct {
+	Method string           `json:"method"`
+	Data   KlineDataPayload `json:"data"`
+}
+
+type KlineDataPayload struct {
+	Market   string  `json:"market"`
+	Interval string  `json:"interval"`
+	Klines   []Kline `json:"kline_list"`
+}
+
+// Kline is one candle of a /spot/kline or /futures/kline response
+type Kline struct {
+	Market    string `json:"market"`
+	CreatedAt int64  `json:"created_at"`
+	Open      string `json:"open"`
+	Close     string `json:"close"`
+	High      string `json:"high"`
+	Low       string `json:"low"`
+	Volume    string `json:"volume"`
+	Value     string `json:"value"`
+}
+
+// KlineResponse is the body of a REST kline request
+type KlineResponse struct {
+	Code    int     `json:"code"`
+	Data    []Kline `json:"data"`
+	Message string  `json:"message"`
+}
@@ -0,0 +1,164 @@
+package exchange
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Antkky/go_crypto_scraper/utils"
+	"github.com/gorilla/websocket"
+)
+
+// Adapter is everything the connection lifecycle needs to know about a venue.
+// One adapter is created per ExchangeConfig, so it may keep per-connection state.
+type Adapter interface {
+	// Dial opens the websocket connection to the exchange.
+	Dial() (*websocket.Conn, error)
+	// Subscribe sends the subscribe messages for every configured stream.
+	Subscribe(conn *websocket.Conn) error
+	// Parse decodes a raw frame into zero or more parsed messages.
+	Parse(message []byte) ([]utils.ParsedMessage, error)
+	// Heartbeat is called periodically to keep the connection alive.
+	Heartbeat(conn *websocket.Conn) error
+	// Close tears the connection down.
+	Close(conn *websocket.Conn) error
+}
+
+// Bootstrapper is implemented by adapters that must do some work before every
+// dial, such as fetching a token and endpoint over REST. The supervisor calls
+// Bootstrap right before Dial, including on reconnects.
+type Bootstrapper interface {
+	Bootstrap() error
+}
+
+// Poller is implemented by adapters that pull part of their data over REST,
+// such as candles a venue does not push on the socket. The supervisor runs
+// Poll in its own goroutine for as long as it runs, independent of the
+// websocket. Every frame passed to emit is queued and parsed like a socket
+// frame, so Parse must recognise it; Poll returns once stop is closed.
+type Poller interface {
+	Poll(emit func(frame []byte), stop <-chan struct{})
+}
+
+// Factory builds an adapter for a single exchange config.
+type Factory func(config utils.ExchangeConfig, logger *log.Logger) Adapter
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+// Register makes an adapter available under the given "exchange" config key.
+// It is meant to be called from the adapter package's init function.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	key := strings.ToLower(name)
+	if factory == nil {
+		panic("exchange: Register factory is nil")
+	}
+	if _, dup := registry[key]; dup {
+		panic("exchange: Register called twice for adapter " + key)
+	}
+	registry[key] = factory
+}
+
+// New looks up the adapter registered for config.Exchange and builds it.
+func New(config utils.ExchangeConfig, logger *log.Logger) (Adapter, error) {
+	registryMu.RLock()
+	factory, exists := registry[strings.ToLower(config.Exchange)]
+	registryMu.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("no adapter registered for exchange %q (%s)", config.Exchange, config.Name)
+	}
+	return factory(config, logger), nil
+}
+
+// Registered returns the sorted list of registered adapter names.
+func Registered() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Base implements the parts of Adapter that most venues share: dialing
+// config.URI, sending each stream's subscribe message verbatim, and closing
+// the socket. Adapters embed it and provide Parse.
+type Base struct {
+	Config utils.ExchangeConfig
+	Logger *log.Logger
+}
+
+func (b *Base) Dial() (*websocket.Conn, error) {
+	return b.DialURI(b.Config.URI)
+}
+
+// DialURI dials uri instead of config.URI, for adapters that learn their endpoint at runtime.
+func (b *Base) DialURI(uri string) (*websocket.Conn, error) {
+	conn, _, err := websocket.DefaultDialer.Dial(uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (b *Base) Subscribe(conn *websocket.Conn) error {
+	messages := make([]json.RawMessage, 0, len(b.Config.Streams))
+	for _, stream := range b.Config.Streams {
+		// streams without a subscribe message are polled, not pushed
+		if len(stream.Message) == 0 {
+			continue
+		}
+		messages = append(messages, stream.Message)
+	}
+	return b.SendSubscriptions(conn, messages)
+}
+
+// SendSubscriptions writes each subscribe message as a text frame, pacing
+// them so exchanges with per-connection rate limits do not drop any.
+func (b *Base) SendSubscriptions(conn *websocket.Conn, messages []json.RawMessage) error {
+	for _, message := range messages {
+		bMessage, err := json.Marshal(message)
+		if err != nil {
+			b.Logger.Printf("❌ Error marshalling subscribe message %s: %s", message, err)
+			return err
+		}
+
+		if err := conn.WriteMessage(websocket.TextMessage, bMessage); err != nil {
+			b.Logger.Printf("❌ Error subscribing to stream %s: %s", message, err)
+			return err
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	return nil
+}
+
+// Heartbeat sends config.Ping as an application level ping when one is
+// configured, and a websocket ping control frame otherwise.
+func (b *Base) Heartbeat(conn *websocket.Conn) error {
+	if len(b.Config.Ping) == 0 {
+		return conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait))
+	}
+
+	payload, err := json.Marshal(b.Config.Ping)
+	if err != nil {
+		return fmt.Errorf("failed to marshal ping payload: %w", err)
+	}
+	return conn.WriteMessage(websocket.TextMessage, payload)
+}
+
+func (b *Base) Close(conn *websocket.Conn) error {
+	return conn.Close()
+}
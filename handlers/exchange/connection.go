@@ -0,0 +1,422 @@
+package exchange
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Antkky/go_crypto_scraper/utils"
+	"github.com/Antkky/go_crypto_scraper/utils/buffer"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	defaultPingInterval = 30 * time.Second
+	// missedHeartbeats is how many ping intervals may pass without any frame
+	// before the connection is considered dead, unless ReadTimeout is set.
+	missedHeartbeats = 3
+	writeWait        = 10 * time.Second
+	// DefaultMaxSize and DefaultFlushInterval are the buffer limits when the config sets none
+	DefaultMaxSize       = 50
+	DefaultFlushInterval = time.Minute
+	// maxFlushCheck is the longest the consumer waits between checks for expired buffers
+	maxFlushCheck = time.Second
+)
+
+// PingInterval returns the configured heartbeat interval or the default.
+func PingInterval(exchange utils.ExchangeConfig) time.Duration {
+	if exchange.PingInterval > 0 {
+		return time.Duration(exchange.PingInterval)
+	}
+	return defaultPingInterval
+}
+
+// ReadTimeout returns the configured read deadline, defaulting to missedHeartbeats ping intervals.
+func ReadTimeout(exchange utils.ExchangeConfig) time.Duration {
+	if exchange.ReadTimeout > 0 {
+		return time.Duration(exchange.ReadTimeout)
+	}
+	return missedHeartbeats * PingInterval(exchange)
+}
+
+// NormalizeName strips spaces from an exchange name for use in buffer ids and paths.
+func NormalizeName(name string) string {
+	return strings.ReplaceAll(name, " ", "")
+}
+
+// BufferCode returns the id of the buffer that holds dataType records for symbol.
+func BufferCode(symbol string, dataType string, exchangeName string) string {
+	return fmt.Sprintf("%s:%s@%s", symbol, dataType, NormalizeName(exchangeName))
+}
+
+// GapsBufferCode returns the id of the buffer that logs trade id gaps for an exchange.
+func GapsBufferCode(exchangeName string) string {
+	return BufferCode("*", "gap", exchangeName)
+}
+
+// BufferLimits returns the MaxSize and flush interval for stream: its own, else the exchange's, else the defaults.
+func BufferLimits(exchange utils.ExchangeConfig, stream utils.StreamConfig) (int, time.Duration) {
+	maxSize := DefaultMaxSize
+	if stream.MaxSize > 0 {
+		maxSize = stream.MaxSize
+	} else if exchange.MaxSize > 0 {
+		maxSize = exchange.MaxSize
+	}
+
+	flushInterval := DefaultFlushInterval
+	if stream.FlushInterval > 0 {
+		flushInterval = time.Duration(stream.FlushInterval)
+	} else if exchange.FlushInterval > 0 {
+		flushInterval = time.Duration(exchange.FlushInterval)
+	}
+	return maxSize, flushInterval
+}
+
+// SinkName returns the storage backend for stream: its own, else the exchange's, else buffer.DefaultSink.
+func SinkName(exchange utils.ExchangeConfig, stream utils.StreamConfig) string {
+	if stream.Sink != "" {
+		return stream.Sink
+	}
+	if exchange.Sink != "" {
+		return exchange.Sink
+	}
+	return buffer.DefaultSink
+}
+
+// newStreamSink builds the named sink for stream under filePath and returns it with its file name
+func newStreamSink(sinkName string, exchangeName string, stream utils.StreamConfig, filePath string, options utils.SinkOptions) (buffer.Sink, string, error) {
+	extension, err := buffer.SinkExtension(sinkName)
+	if err != nil {
+		return nil, "", err
+	}
+	filename := fmt.Sprintf("%s_%s_%s%s", exchangeName, stream.Symbol, stream.Type, extension)
+	sink, err := buffer.NewSink(sinkName, stream.Type, fmt.Sprintf("%s/%s", filePath, filename), options)
+	return sink, filename, err
+}
+
+// InitializeBuffers()
+//
+// Inputs:
+//
+//	exchange    : utils.ExchangeConfig
+//	dataBuffers : *map[string]*buffer.DataBuffer
+//	logger      : *log.Logger
+//
+// Outputs:
+//
+//	No Outputs
+//
+// Description:
+//
+//	Creates one data buffer per configured stream, keyed by BufferCode, sized by BufferLimits and writing through
+//	the sink picked by SinkName. A sink that is unknown or cannot store the stream's type,
+//	or that rejects the sink options, is logged and replaced by the default. When the sink options ask for rotation the sink
+//	is wrapped in a RotatingSink partitioned under the stream's directory. A sink that cannot append only leaves a
+//	readable file once it is closed, so it rotates hourly unless the options say otherwise.
+//	Every exchange also gets a CSV gaps log, written through on every gap since gaps are rare.
+func InitializeBuffers(exchange utils.ExchangeConfig, dataBuffers *map[string]*buffer.DataBuffer, logger *log.Logger) {
+	*dataBuffers = make(map[string]*buffer.DataBuffer)
+	name := NormalizeName(exchange.Name)
+
+	for _, stream := range exchange.Streams {
+		bufferCode := BufferCode(stream.Symbol, stream.Type, exchange.Name)
+		filePath := fmt.Sprintf("data/%s/%s", name, stream.Symbol)
+		options := exchange.SinkOptions.Merge(stream.SinkOptions)
+
+		sinkName := SinkName(exchange, stream)
+		if !buffer.SinkSupports(sinkName, stream.Type) {
+			logger.Printf("⚠️ %s %s %s: sink %q is unknown or cannot store %s data, using %s", exchange.Name, stream.Symbol, stream.Type, sinkName, stream.Type, buffer.DefaultSink)
+			sinkName = buffer.DefaultSink
+		}
+		sink, filename, err := newStreamSink(sinkName, name, stream, filePath, options)
+		if err != nil {
+			logger.Printf("⚠️ %s %s %s: %v, using %s", exchange.Name, stream.Symbol, stream.Type, err, buffer.DefaultSink)
+			sinkName = buffer.DefaultSink
+			sink, filename, _ = newStreamSink(sinkName, name, stream, filePath, options)
+		}
+
+		maxSize, flushInterval := BufferLimits(exchange, stream)
+		dataBuffer := buffer.NewDataBuffer(stream.Type, stream.Market, bufferCode, maxSize, filename, filePath)
+		dataBuffer.MaxAge = flushInterval
+		dataBuffer.IncludePartial = stream.IncludePartial
+		dataBuffer.Sink = sink
+
+		if fileSink, ok := sink.(buffer.FileSink); ok && !fileSink.Appends() && options.Rotate == "" && options.MaxFileSize == 0 {
+			options.Rotate = buffer.RotateHourly
+		}
+		if options.Rotate != "" || options.MaxFileSize > 0 {
+			policy := options.Rotate
+			if policy == "" {
+				policy = buffer.RotateSize
+			}
+			dataType := stream.Type
+			rotating, err := buffer.NewRotatingSink(filePath, filename, policy, options.MaxFileSize, func(path string) (buffer.Sink, error) {
+				return buffer.NewSink(sinkName, dataType, path, options)
+			})
+			if err != nil {
+				logger.Printf("⚠️ %s %s %s: %v, not rotating", exchange.Name, stream.Symbol, stream.Type, err)
+			} else {
+				dataBuffer.Sink = rotating
+			}
+		}
+		(*dataBuffers)[bufferCode] = dataBuffer
+	}
+
+	gapsFile := fmt.Sprintf("%s_gaps.csv", name)
+	(*dataBuffers)[GapsBufferCode(exchange.Name)] = buffer.NewDataBuffer("gap", exchange.Market, GapsBufferCode(exchange.Name), 1, gapsFile, fmt.Sprintf("data/%s", name))
+}
+
+// CloseBuffers()
+//
+// Inputs:
+//
+//	buffers  : map[string]*buffer.DataBuffer
+//	exchange : utils.ExchangeConfig
+//	logger   : *log.Logger
+//
+// Outputs:
+//
+//	No Outputs
+//
+// Description:
+//
+//	Flushes what is left in every buffer and closes its sink, which syncs the file to disk.
+//	Call it only once nothing else is adding to the buffers. A buffer that fails is logged
+//	and the rest are still closed.
+func CloseBuffers(buffers map[string]*buffer.DataBuffer, exchange utils.ExchangeConfig, logger *log.Logger) {
+	flushed := 0
+	for code, dataBuffer := range buffers {
+		pending := dataBuffer.Len()
+		if err := dataBuffer.Close(); err != nil {
+			logger.Printf("❌ Error closing buffer %s: %v", code, err)
+			continue
+		}
+		flushed += pending
+	}
+	logger.Printf("✅ Closed %d buffer(s) for %s, flushed %d record(s)", len(buffers), exchange.Name, flushed)
+}
+
+// flushCheckInterval is how often the consumer looks for expired buffers: often
+// enough for the shortest MaxAge, and at most maxFlushCheck apart
+func flushCheckInterval(buffers map[string]*buffer.DataBuffer) time.Duration {
+	interval := maxFlushCheck
+	for _, dataBuffer := range buffers {
+		if dataBuffer.MaxAge > 0 && dataBuffer.MaxAge < interval {
+			interval = dataBuffer.MaxAge
+		}
+	}
+	return interval
+}
+
+// ConsumeMessages()
+//
+// Inputs:
+//
+//	adapter       : Adapter
+//	sender        : Sender
+//	messageQueue  : chan []byte
+//	exchange      : utils.ExchangeConfig
+//	buffers       : map[string]*buffer.DataBuffer
+//
+// Outputs:
+//
+//	No Outputs
+//
+// Description:
+//
+//	Parses incoming messages with the adapter and adds them to the appropriate data buffer.
+//	This function performs constant time lookups for the buffer associated with each message.
+//	Replies requested by the adapter are written back through sender, and trade id gaps
+//	go to the exchange's gaps log. A ticker flushes buffers whose oldest record is past their
+//	MaxAge; it runs on this goroutine, so the buffers are never touched from two at once.
+func ConsumeMessages(adapter Adapter, sender Sender, messageQueue chan []byte, exchange utils.ExchangeConfig, buffers map[string]*buffer.DataBuffer, logger *log.Logger) {
+	ticker := time.NewTicker(flushCheckInterval(buffers))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case message, ok := <-messageQueue:
+			if !ok {
+				return
+			}
+			consumeMessage(adapter, sender, message, exchange, buffers, logger)
+		case now := <-ticker.C:
+			for code, dataBuffer := range buffers {
+				if err := dataBuffer.FlushExpired(now); err != nil {
+					logger.Printf("❌ Error flushing expired buffer %s: %v", code, err)
+				}
+			}
+		}
+	}
+}
+
+// consumeMessage parses one frame and routes its records to their buffers
+func consumeMessage(adapter Adapter, sender Sender, message []byte, exchange utils.ExchangeConfig, buffers map[string]*buffer.DataBuffer, logger *log.Logger) {
+	parsed, err := adapter.Parse(message)
+	if err != nil {
+		logger.Printf("❌ Error processing message: %v", err)
+		return
+	}
+
+	for _, msg := range parsed {
+		if msg.Reply != nil {
+			// replies to frames drained after Stop have nowhere to go
+			if err := sender.Send(msg.Reply); err != nil && !errors.Is(err, errStopped) {
+				logger.Printf("❌ Error replying to %s: %v", exchange.Name, err)
+			}
+		}
+		if msg.DataType == "" {
+			if msg.Event == "subscribed" {
+				logger.Println("✅ Subscribe Success")
+			}
+			continue
+		}
+		if msg.Symbol == "" {
+			continue
+		}
+
+		bufferCode := BufferCode(msg.Symbol, msg.DataType, exchange.Name)
+		if msg.DataType == "gap" {
+			bufferCode = GapsBufferCode(exchange.Name)
+			for _, gap := range msg.Data.([]utils.GapDataStruct) {
+				logger.Printf("⚠️ %s %s missed trade ids %d-%d", exchange.Name, gap.Symbol, gap.FirstMissing, gap.LastMissing)
+			}
+		}
+		buffer, exists := buffers[bufferCode]
+		if !exists {
+			logger.Printf("❌ No buffer found for ID: %s", bufferCode)
+			continue
+		}
+		if err := buffer.AddData(msg.Data); err != nil {
+			logger.Println("❌ Error adding data to buffer: ", err)
+		}
+	}
+}
+
+// ReceiveMessages()
+//
+// Inputs:
+//
+//	conn          : *websocket.Conn
+//	messageQueue  : chan []byte
+//	done          : chan struct{}
+//	exchange      : utils.ExchangeConfig
+//
+// Outputs:
+//
+//	No Outputs
+//
+// Description:
+//
+//	Reads messages from the WebSocket connection and sends them to the messageQueue channel.
+//	The send never blocks the reader: when the queue is full the frame is dropped and counted.
+//	Every frame, ping or pong pushes the read deadline out by ReadTimeout, so a silent
+//	connection errors out here and gets redialed by the supervisor.
+func ReceiveMessages(conn *websocket.Conn, messageQueue chan []byte, done chan struct{}, exchange utils.ExchangeConfig, logger *log.Logger) {
+	defer close(done)
+
+	timeout := ReadTimeout(exchange)
+	extendDeadline := func() error {
+		return conn.SetReadDeadline(time.Now().Add(timeout))
+	}
+	conn.SetPongHandler(func(string) error {
+		return extendDeadline()
+	})
+	conn.SetPingHandler(func(appData string) error {
+		if err := extendDeadline(); err != nil {
+			return err
+		}
+		err := conn.WriteControl(websocket.PongMessage, []byte(appData), time.Now().Add(writeWait))
+		if err == websocket.ErrCloseSent {
+			return nil
+		}
+		return err
+	})
+
+	// dropped counts frames lost because the consumer fell behind and the queue was full
+	var dropped int
+	for {
+		if err := extendDeadline(); err != nil {
+			logger.Printf("❌ Error setting read deadline for %s: %v", exchange.Name, err)
+			return
+		}
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			logger.Printf("❌ Error reading message from %s: %v", exchange.Name, err)
+			return
+		}
+
+		select {
+		case messageQueue <- message:
+		default:
+			dropped++
+			logger.Printf("❌ Message queue full, dropping message for %s (%d dropped)", exchange.Name, dropped)
+		}
+	}
+}
+
+// KeepAlive()
+//
+// Inputs:
+//
+//	adapter  : Adapter
+//	conn     : *websocket.Conn
+//	writeMu  : *sync.Mutex
+//	done     : chan struct{}
+//	exchange : utils.ExchangeConfig
+//
+// Outputs:
+//
+//	No Outputs
+//
+// Description:
+//
+//	Calls the adapter's Heartbeat every PingInterval until the reader stops.
+//	A heartbeat that cannot be written closes the connection so the reader fails fast.
+//	writeMu serialises the heartbeat with every other writer on conn.
+func KeepAlive(adapter Adapter, conn *websocket.Conn, writeMu *sync.Mutex, done chan struct{}, exchange utils.ExchangeConfig, logger *log.Logger) {
+	ticker := time.NewTicker(PingInterval(exchange))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			writeMu.Lock()
+			err := adapter.Heartbeat(conn)
+			writeMu.Unlock()
+			if err != nil {
+				logger.Printf("❌ Heartbeat failed for %s, dropping connection: %v", exchange.Name, err)
+				conn.Close()
+				return
+			}
+		}
+	}
+}
+
+// CloseConnection()
+//
+// Inputs:
+//
+//	adapter      : Adapter
+//	conn         : *websocket.conn
+//	exchangeName : string
+//
+// Outputs:
+//
+//	No Outputs
+//
+// Description:
+//
+//	Gracefully close the connection through the adapter
+func CloseConnection(adapter Adapter, conn *websocket.Conn, exchangeName string, logger *log.Logger) {
+	if err := adapter.Close(conn); err != nil {
+		logger.Printf("❌ Error closing connection for %s: %v", exchangeName, err)
+	} else {
+		logger.Printf("Connection for %s closed gracefully", exchangeName)
+	}
+}
@@ -0,0 +1,142 @@
+package exchange
+
+import (
+	"time"
+
+	"github.com/Antkky/go_crypto_scraper/utils"
+)
+
+// Test Cases for Backoff.Delay with Initial 100ms, Max 1s, Multiplier 2
+var BackoffDelayCases = []struct {
+	name    string
+	attempt int
+	min     time.Duration
+	max     time.Duration
+}{
+	{
+		name:    "first attempt",
+		attempt: 0,
+		min:     50 * time.Millisecond,
+		max:     100 * time.Millisecond,
+	},
+	{
+		name:    "third attempt",
+		attempt: 2,
+		min:     200 * time.Millisecond,
+		max:     400 * time.Millisecond,
+	},
+	{
+		name:    "capped at max",
+		attempt: 20,
+		min:     500 * time.Millisecond,
+		max:     time.Second,
+	},
+}
+
+// Test Cases for the sink each stream's buffer writes through
+var SinkSelectionCases = []struct {
+	name       string
+	exchange   utils.ExchangeConfig
+	wantSink   string
+	wantPolicy string
+	wantFile   string
+}{
+	{
+		name:     "default is csv",
+		exchange: utils.ExchangeConfig{Name: "Sink Test", Streams: []utils.StreamConfig{{Type: "trade", Symbol: "BTCUSDT"}}},
+		wantSink: "csv",
+		wantFile: "SinkTest_BTCUSDT_trade.csv",
+	},
+	{
+		name:     "exchange sink",
+		exchange: utils.ExchangeConfig{Name: "Sink Test", Sink: "memory", Streams: []utils.StreamConfig{{Type: "trade", Symbol: "BTCUSDT"}}},
+		wantSink: "memory",
+		wantFile: "SinkTest_BTCUSDT_trade.mem",
+	},
+	{
+		name:     "stream overrides exchange",
+		exchange: utils.ExchangeConfig{Name: "Sink Test", Sink: "memory", Streams: []utils.StreamConfig{{Type: "trade", Symbol: "BTCUSDT", Sink: "csv"}}},
+		wantSink: "csv",
+		wantFile: "SinkTest_BTCUSDT_trade.csv",
+	},
+	{
+		name:     "sink that cannot store the type falls back to csv",
+		exchange: utils.ExchangeConfig{Name: "Sink Test", Sink: "memory", Streams: []utils.StreamConfig{{Type: "ticker", Symbol: "BTCUSDT"}}},
+		wantSink: "csv",
+		wantFile: "SinkTest_BTCUSDT_ticker.csv",
+	},
+	{
+		name:     "sink that rejects its options falls back to csv",
+		exchange: utils.ExchangeConfig{Name: "Sink Test", Sink: "memory", SinkOptions: utils.SinkOptions{Compression: "zstd"}, Streams: []utils.StreamConfig{{Type: "trade", Symbol: "BTCUSDT"}}},
+		wantSink: "csv",
+		wantFile: "SinkTest_BTCUSDT_trade.csv",
+	},
+	{
+		name:       "rotation wraps the sink",
+		exchange:   utils.ExchangeConfig{Name: "Sink Test", SinkOptions: utils.SinkOptions{Rotate: "hourly"}, Streams: []utils.StreamConfig{{Type: "trade", Symbol: "BTCUSDT"}}},
+		wantSink:   "rotating",
+		wantPolicy: "hourly",
+		wantFile:   "SinkTest_BTCUSDT_trade.csv",
+	},
+	{
+		name:       "sink that cannot append rotates hourly",
+		exchange:   utils.ExchangeConfig{Name: "Sink Test", Sink: "once", Streams: []utils.StreamConfig{{Type: "trade", Symbol: "BTCUSDT"}}},
+		wantSink:   "rotating",
+		wantPolicy: "hourly",
+		wantFile:   "SinkTest_BTCUSDT_trade.once",
+	},
+	{
+		name:       "sink that cannot append keeps the configured rotation",
+		exchange:   utils.ExchangeConfig{Name: "Sink Test", Sink: "once", SinkOptions: utils.SinkOptions{MaxFileSize: 1024}, Streams: []utils.StreamConfig{{Type: "trade", Symbol: "BTCUSDT"}}},
+		wantSink:   "rotating",
+		wantPolicy: "size",
+		wantFile:   "SinkTest_BTCUSDT_trade.once",
+	},
+	{
+		name:     "unknown rotation is logged and ignored",
+		exchange: utils.ExchangeConfig{Name: "Sink Test", Streams: []utils.StreamConfig{{Type: "trade", Symbol: "BTCUSDT", SinkOptions: utils.SinkOptions{Rotate: "weekly"}}}},
+		wantSink: "csv",
+		wantFile: "SinkTest_BTCUSDT_trade.csv",
+	},
+	{
+		name:     "unknown sink falls back to csv",
+		exchange: utils.ExchangeConfig{Name: "Sink Test", Streams: []utils.StreamConfig{{Type: "trade", Symbol: "BTCUSDT", Sink: "nowhere"}}},
+		wantSink: "csv",
+		wantFile: "SinkTest_BTCUSDT_trade.csv",
+	},
+}
+
+// Test Cases for BufferLimits
+var BufferLimitsCases = []struct {
+	name              string
+	exchange          utils.ExchangeConfig
+	stream            utils.StreamConfig
+	wantMaxSize       int
+	wantFlushInterval time.Duration
+}{
+	{
+		name:              "defaults",
+		wantMaxSize:       DefaultMaxSize,
+		wantFlushInterval: DefaultFlushInterval,
+	},
+	{
+		name:              "exchange limits",
+		exchange:          utils.ExchangeConfig{MaxSize: 500, FlushInterval: utils.Duration(10 * time.Second)},
+		wantMaxSize:       500,
+		wantFlushInterval: 10 * time.Second,
+	},
+	{
+		name:              "stream overrides exchange",
+		exchange:          utils.ExchangeConfig{MaxSize: 500, FlushInterval: utils.Duration(10 * time.Second)},
+		stream:            utils.StreamConfig{MaxSize: 5, FlushInterval: utils.Duration(time.Hour)},
+		wantMaxSize:       5,
+		wantFlushInterval: time.Hour,
+	},
+	{
+		name:              "stream sets only its interval",
+		exchange:          utils.ExchangeConfig{MaxSize: 500},
+		stream:            utils.StreamConfig{FlushInterval: utils.Duration(2 * time.Second)},
+		wantMaxSize:       500,
+		wantFlushInterval: 2 * time.Second,
+	},
+}
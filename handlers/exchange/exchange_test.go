@@ -0,0 +1,431 @@
+package exchange
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Antkky/go_crypto_scraper/utils"
+	"github.com/Antkky/go_crypto_scraper/utils/buffer"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+type stubAdapter struct {
+	Base
+}
+
+func (s *stubAdapter) Parse(message []byte) ([]utils.ParsedMessage, error) {
+	return nil, nil
+}
+
+func newStubAdapter(config utils.ExchangeConfig, logger *log.Logger) Adapter {
+	return &stubAdapter{Base: Base{Config: config, Logger: logger}}
+}
+
+func TestRegistry(t *testing.T) {
+	logger := log.New(os.Stdout, "[Test] ", log.LstdFlags)
+	Register("stub", newStubAdapter)
+
+	adapter, err := New(utils.ExchangeConfig{Name: "Stub Spot", Exchange: "Stub"}, logger)
+	assert.NoError(t, err)
+	assert.IsType(t, &stubAdapter{}, adapter)
+	assert.Contains(t, Registered(), "stub")
+
+	_, err = New(utils.ExchangeConfig{Name: "Nowhere", Exchange: "nowhere"}, logger)
+	assert.Error(t, err)
+
+	assert.Panics(t, func() {
+		Register("STUB", newStubAdapter)
+	})
+}
+
+// memorySink keeps batches in memory
+type memorySink struct {
+	path    string
+	batches []interface{}
+}
+
+func (m *memorySink) Open() error { return nil }
+func (m *memorySink) WriteBatch(batch interface{}) error {
+	m.batches = append(m.batches, batch)
+	return nil
+}
+func (m *memorySink) Flush() error { return nil }
+func (m *memorySink) Close() error { return nil }
+
+// onceSink is a memorySink that, like Parquet, cannot append to its file
+type onceSink struct {
+	memorySink
+}
+
+func (o *onceSink) File() string  { return o.path }
+func (o *onceSink) Appends() bool { return false }
+
+func TestSinkSelection(t *testing.T) {
+	buffer.RegisterSink("memory", ".mem", []string{"trade"}, func(dataType string, path string, options utils.SinkOptions) (buffer.Sink, error) {
+		if options.Compression != "" {
+			return nil, fmt.Errorf("memory sink cannot compress")
+		}
+		return &memorySink{path: path}, nil
+	})
+	buffer.RegisterSink("once", ".once", []string{"trade"}, func(dataType string, path string, options utils.SinkOptions) (buffer.Sink, error) {
+		return &onceSink{memorySink{path: path}}, nil
+	})
+	logger := log.New(io.Discard, "", 0)
+
+	for _, tt := range SinkSelectionCases {
+		t.Run(tt.name, func(t *testing.T) {
+			var buffers map[string]*buffer.DataBuffer
+			InitializeBuffers(tt.exchange, &buffers, logger)
+
+			stream := tt.exchange.Streams[0]
+			dataBuffer := buffers[BufferCode(stream.Symbol, stream.Type, tt.exchange.Name)]
+			if !assert.NotNil(t, dataBuffer) {
+				return
+			}
+			assert.Equal(t, tt.wantFile, dataBuffer.FileName)
+			if tt.wantSink == "rotating" {
+				assert.IsType(t, &buffer.RotatingSink{}, dataBuffer.Sink)
+				assert.Equal(t, "data/SinkTest/BTCUSDT", dataBuffer.Sink.(*buffer.RotatingSink).Dir)
+				assert.Equal(t, tt.wantPolicy, dataBuffer.Sink.(*buffer.RotatingSink).Policy)
+			} else if tt.wantSink == "memory" {
+				assert.IsType(t, &memorySink{}, dataBuffer.Sink)
+				assert.Equal(t, "data/SinkTest/BTCUSDT/"+tt.wantFile, dataBuffer.Sink.(*memorySink).path)
+
+				assert.NoError(t, dataBuffer.AddData(utils.TradeDataStruct{Price: "1", Quantity: "2"}))
+				assert.NoError(t, dataBuffer.Close())
+				assert.Len(t, dataBuffer.Sink.(*memorySink).batches, 1)
+			} else {
+				assert.IsType(t, &buffer.CSVSink{}, dataBuffer.Sink)
+			}
+		})
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	b := Backoff{Initial: 100 * time.Millisecond, Max: time.Second, Multiplier: 2}
+	for _, tt := range BackoffDelayCases {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 50; i++ {
+				d := b.Delay(tt.attempt)
+				assert.GreaterOrEqual(t, d, tt.min)
+				assert.LessOrEqual(t, d, tt.max)
+			}
+		})
+	}
+}
+
+// TestSupervisorReconnect drops the first connection right after the
+// subscriptions arrive and checks that the supervisor redials and replays them.
+func TestSupervisorReconnect(t *testing.T) {
+	var (
+		mu            sync.Mutex
+		subscriptions [][]string
+	)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		mu.Lock()
+		subscriptions = append(subscriptions, nil)
+		index := len(subscriptions) - 1
+		mu.Unlock()
+
+		for i := 0; i < 2; i++ {
+			_, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			subscriptions[index] = append(subscriptions[index], string(message))
+			mu.Unlock()
+		}
+		if index == 0 {
+			return
+		}
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	config := utils.ExchangeConfig{
+		Name: "Stub Spot",
+		URI:  "ws" + strings.TrimPrefix(server.URL, "http"),
+		Streams: []utils.StreamConfig{
+			{Type: "trade", Symbol: "BTCUSDT", Message: []byte(`{"sub":1}`)},
+			{Type: "ticker", Symbol: "BTCUSDT", Message: []byte(`{"sub":2}`)},
+		},
+	}
+	logger := log.New(os.Stdout, "[Test] ", log.LstdFlags)
+	supervisor := NewSupervisor(newStubAdapter(config, logger), config, logger)
+	supervisor.Backoff = Backoff{Initial: 10 * time.Millisecond, Max: 50 * time.Millisecond, Multiplier: 2}
+
+	go supervisor.Run()
+	defer supervisor.Stop()
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(subscriptions) >= 2 && len(subscriptions[1]) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, subscriptions[0], subscriptions[1], "subscriptions were not replayed after reconnect")
+}
+
+// TestHeartbeatDeadline has the server swallow every ping without answering,
+// so the read deadline must expire and force a redial.
+func TestHeartbeatDeadline(t *testing.T) {
+	var (
+		mu          sync.Mutex
+		connections int
+		pings       []string
+	)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		mu.Lock()
+		connections++
+		mu.Unlock()
+
+		for {
+			_, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			pings = append(pings, string(message))
+			mu.Unlock()
+		}
+	}))
+	defer server.Close()
+
+	config := utils.ExchangeConfig{
+		Name:         "Stub Spot",
+		URI:          "ws" + strings.TrimPrefix(server.URL, "http"),
+		Ping:         map[string]interface{}{"method": "server.ping"},
+		PingInterval: utils.Duration(20 * time.Millisecond),
+		ReadTimeout:  utils.Duration(100 * time.Millisecond),
+	}
+	logger := log.New(os.Stdout, "[Test] ", log.LstdFlags)
+	supervisor := NewSupervisor(newStubAdapter(config, logger), config, logger)
+	supervisor.Backoff = Backoff{Initial: 10 * time.Millisecond, Max: 50 * time.Millisecond, Multiplier: 2}
+
+	go supervisor.Run()
+	defer supervisor.Stop()
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return connections >= 2
+	}, 5*time.Second, 10*time.Millisecond, "silent connection was never dropped")
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Contains(t, pings, `{"method":"server.ping"}`)
+}
+
+// tradeAdapter parses every frame into one BTCUSDT trade
+type tradeAdapter struct {
+	Base
+	mu     sync.Mutex
+	parsed int
+}
+
+func (a *tradeAdapter) Parse(message []byte) ([]utils.ParsedMessage, error) {
+	a.mu.Lock()
+	a.parsed++
+	a.mu.Unlock()
+	return []utils.ParsedMessage{{
+		DataType: "trade",
+		Symbol:   "BTCUSDT",
+		Data:     []utils.TradeDataStruct{{TimeStamp: 1, Symbol: "BTCUSDT", Price: string(message), Quantity: "1"}},
+	}}, nil
+}
+
+// TestShutdownFlushes checks that records still sitting in a buffer when Stop
+// is called are written to disk before Done is closed.
+func TestShutdownFlushes(t *testing.T) {
+	wd, _ := os.Getwd()
+	assert.NoError(t, os.Chdir(t.TempDir()))
+	defer os.Chdir(wd)
+
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for _, price := range []string{"1", "2", "3"} {
+			conn.WriteMessage(websocket.TextMessage, []byte(price))
+		}
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	config := utils.ExchangeConfig{
+		Name:    "Stub Spot",
+		URI:     "ws" + strings.TrimPrefix(server.URL, "http"),
+		Streams: []utils.StreamConfig{{Type: "trade", Symbol: "BTCUSDT"}},
+	}
+	logger := log.New(io.Discard, "", 0)
+	adapter := &tradeAdapter{Base: Base{Config: config, Logger: logger}}
+	supervisor := NewSupervisor(adapter, config, logger)
+
+	go supervisor.Run()
+	assert.Eventually(t, func() bool {
+		adapter.mu.Lock()
+		defer adapter.mu.Unlock()
+		return adapter.parsed == 3
+	}, 5*time.Second, 10*time.Millisecond)
+
+	supervisor.Stop()
+	select {
+	case <-supervisor.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("supervisor did not finish shutting down")
+	}
+
+	contents, err := os.ReadFile("data/StubSpot/BTCUSDT/StubSpot_BTCUSDT_trade.csv")
+	assert.NoError(t, err)
+	assert.Equal(t, "TimeStamp,Date,Symbol,Price,Quantity,Bid_MM,TradeID\n1,0,BTCUSDT,1,1,false,\n1,0,BTCUSDT,2,1,false,\n1,0,BTCUSDT,3,1,false,\n", string(contents))
+}
+
+// pollingAdapter also emits a trade frame per price in prices, then waits for stop
+type pollingAdapter struct {
+	tradeAdapter
+	prices []string
+}
+
+func (a *pollingAdapter) Poll(emit func(frame []byte), stop <-chan struct{}) {
+	for _, price := range a.prices {
+		emit([]byte(price))
+	}
+	<-stop
+}
+
+// TestPoller checks that polled frames are parsed and written alongside the
+// socket's, and that shutdown waits for the poller before closing the queue.
+func TestPoller(t *testing.T) {
+	wd, _ := os.Getwd()
+	assert.NoError(t, os.Chdir(t.TempDir()))
+	defer os.Chdir(wd)
+
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	config := utils.ExchangeConfig{
+		Name:    "Stub Spot",
+		URI:     "ws" + strings.TrimPrefix(server.URL, "http"),
+		Streams: []utils.StreamConfig{{Type: "trade", Symbol: "BTCUSDT"}},
+	}
+	logger := log.New(io.Discard, "", 0)
+	adapter := &pollingAdapter{tradeAdapter: tradeAdapter{Base: Base{Config: config, Logger: logger}}, prices: []string{"4", "5"}}
+	supervisor := NewSupervisor(adapter, config, logger)
+
+	go supervisor.Run()
+	assert.Eventually(t, func() bool {
+		adapter.mu.Lock()
+		defer adapter.mu.Unlock()
+		return adapter.parsed == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	supervisor.Stop()
+	select {
+	case <-supervisor.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("supervisor did not finish shutting down")
+	}
+
+	contents, err := os.ReadFile("data/StubSpot/BTCUSDT/StubSpot_BTCUSDT_trade.csv")
+	assert.NoError(t, err)
+	assert.Equal(t, "TimeStamp,Date,Symbol,Price,Quantity,Bid_MM,TradeID\n1,0,BTCUSDT,4,1,false,\n1,0,BTCUSDT,5,1,false,\n", string(contents))
+}
+
+func TestBufferLimits(t *testing.T) {
+	for _, tt := range BufferLimitsCases {
+		t.Run(tt.name, func(t *testing.T) {
+			maxSize, flushInterval := BufferLimits(tt.exchange, tt.stream)
+			assert.Equal(t, tt.wantMaxSize, maxSize)
+			assert.Equal(t, tt.wantFlushInterval, flushInterval)
+		})
+	}
+}
+
+// TestFlushInterval checks that a buffer far below MaxSize is still written
+// once its oldest record is older than the stream's flush interval.
+func TestFlushInterval(t *testing.T) {
+	wd, _ := os.Getwd()
+	assert.NoError(t, os.Chdir(t.TempDir()))
+	defer os.Chdir(wd)
+
+	config := utils.ExchangeConfig{
+		Name:    "Stub Spot",
+		Streams: []utils.StreamConfig{{Type: "trade", Symbol: "BTCUSDT", MaxSize: 1000, FlushInterval: utils.Duration(50 * time.Millisecond)}},
+	}
+	logger := log.New(io.Discard, "", 0)
+	var buffers map[string]*buffer.DataBuffer
+	InitializeBuffers(config, &buffers, logger)
+
+	messageQueue := make(chan []byte, 1)
+	consumed := make(chan struct{})
+	go func() {
+		defer close(consumed)
+		ConsumeMessages(&tradeAdapter{}, nil, messageQueue, config, buffers, logger)
+	}()
+	messageQueue <- []byte("1")
+
+	path := "data/StubSpot/BTCUSDT/StubSpot_BTCUSDT_trade.csv"
+	assert.Eventually(t, func() bool {
+		contents, err := os.ReadFile(path)
+		return err == nil && strings.Count(string(contents), "\n") == 2
+	}, 5*time.Second, 10*time.Millisecond, "record was not flushed by age")
+
+	close(messageQueue)
+	<-consumed
+	CloseBuffers(buffers, config, logger)
+}
+
+func TestReadTimeoutDefaults(t *testing.T) {
+	assert.Equal(t, defaultPingInterval, PingInterval(utils.ExchangeConfig{}))
+	assert.Equal(t, missedHeartbeats*defaultPingInterval, ReadTimeout(utils.ExchangeConfig{}))
+	assert.Equal(t, 15*time.Second, ReadTimeout(utils.ExchangeConfig{PingInterval: utils.Duration(5 * time.Second)}))
+}
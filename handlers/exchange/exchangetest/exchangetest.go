@@ -0,0 +1,42 @@
+// Package exchangetest holds helpers shared by the exchange adapter tests.
+package exchangetest
+
+import (
+	"testing"
+
+	"github.com/Antkky/go_crypto_scraper/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// ProcessAll()
+//
+// Inputs:
+//
+//	t        : *testing.T
+//	messages : []string
+//	process  : func(message []byte) ([]utils.ParsedMessage, error)
+//
+// Outputs:
+//
+//	[]utils.ParsedMessage
+//	error
+//
+// Description:
+//
+//	Feeds messages to process in order and returns what the last one produced. Every
+//	message before the last one sets up state, so an error from any of them fails t.
+func ProcessAll(t *testing.T, messages []string, process func(message []byte) ([]utils.ParsedMessage, error)) ([]utils.ParsedMessage, error) {
+	t.Helper()
+
+	var (
+		parsed []utils.ParsedMessage
+		err    error
+	)
+	for i, message := range messages {
+		parsed, err = process([]byte(message))
+		if i < len(messages)-1 {
+			assert.NoError(t, err, "message %d", i)
+		}
+	}
+	return parsed, err
+}
@@ -0,0 +1,273 @@
+package exchange
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/Antkky/go_crypto_scraper/utils"
+	"github.com/Antkky/go_crypto_scraper/utils/buffer"
+	"github.com/gorilla/websocket"
+)
+
+// Backoff computes jittered exponential delays between reconnect attempts.
+type Backoff struct {
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+}
+
+// DefaultBackoff starts at one second and caps at two minutes.
+var DefaultBackoff = Backoff{
+	Initial:    time.Second,
+	Max:        2 * time.Minute,
+	Multiplier: 2,
+}
+
+// Delay returns the wait before the given (zero based) attempt. The result is
+// drawn uniformly from the upper half of the exponential window so that many
+// connections dropped at once do not redial in lockstep.
+func (b Backoff) Delay(attempt int) time.Duration {
+	window := float64(b.Initial)
+	for i := 0; i < attempt && window < float64(b.Max); i++ {
+		window *= b.Multiplier
+	}
+	if window > float64(b.Max) {
+		window = float64(b.Max)
+	}
+	half := window / 2
+	return time.Duration(half + rand.Float64()*half)
+}
+
+var errStopped = errors.New("supervisor stopped")
+
+// Sender writes a frame on whatever connection is currently live.
+type Sender interface {
+	Send(message []byte) error
+}
+
+// Supervisor keeps a single exchange connected. It owns the data buffers and
+// the consumer for the whole process lifetime, and redials and resubscribes
+// whenever the socket dies. After Stop it drains the queue and closes every
+// buffer, then closes Done.
+type Supervisor struct {
+	Adapter Adapter
+	Config  utils.ExchangeConfig
+	Backoff Backoff
+
+	logger *log.Logger
+
+	mu      sync.Mutex
+	writeMu sync.Mutex
+	conn    *websocket.Conn
+	stop    chan struct{}
+	stopped bool
+	// done is closed once Run has drained the queue and closed every buffer
+	done chan struct{}
+}
+
+// NewSupervisor builds a supervisor using DefaultBackoff.
+func NewSupervisor(adapter Adapter, config utils.ExchangeConfig, logger *log.Logger) *Supervisor {
+	return &Supervisor{
+		Adapter: adapter,
+		Config:  config,
+		Backoff: DefaultBackoff,
+		logger:  logger,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// Done is closed when Run has returned and every buffer has been flushed and closed.
+func (s *Supervisor) Done() <-chan struct{} {
+	return s.done
+}
+
+// Run()
+//
+// Inputs:
+//
+//	No Inputs
+//
+// Outputs:
+//
+//	No Outputs
+//
+// Description:
+//
+//	Creates the buffers and consumer, starts the adapter's REST poller if it has one,
+//	then loops dial -> subscribe -> read until Stop is called.
+//	Every subscribe message in the config is replayed after a reconnect and the outage window is logged.
+//	On the way out the queue is closed, the consumer drains what is left in it, and every buffer
+//	is flushed and closed through its sink before Done is closed.
+func (s *Supervisor) Run() {
+	messageQueue := make(chan []byte, 500)
+
+	dataBuffers := make(map[string]*buffer.DataBuffer)
+	InitializeBuffers(s.Config, &dataBuffers, s.logger)
+	consumed := make(chan struct{})
+	go func() {
+		defer close(consumed)
+		ConsumeMessages(s.Adapter, s, messageQueue, s.Config, dataBuffers, s.logger)
+	}()
+
+	polled := make(chan struct{})
+	if poller, ok := s.Adapter.(Poller); ok {
+		go func() {
+			defer close(polled)
+			poller.Poll(func(frame []byte) {
+				select {
+				case messageQueue <- frame:
+				case <-s.stop:
+				}
+			}, s.stop)
+		}()
+	} else {
+		close(polled)
+	}
+
+	defer func() {
+		// the poller may still be sending, so it has to finish before the queue closes
+		<-polled
+		close(messageQueue)
+		<-consumed
+		CloseBuffers(dataBuffers, s.Config, s.logger)
+		close(s.done)
+	}()
+
+	var outageStart time.Time
+	attempt := 0
+
+	for {
+		conn, err := s.connect()
+		if err != nil {
+			if s.isStopped() {
+				return
+			}
+			if outageStart.IsZero() {
+				outageStart = time.Now()
+			}
+			delay := s.Backoff.Delay(attempt)
+			attempt++
+			s.logger.Printf("❌ Error connecting to exchange %s: %v (retry %d in %s)", s.Config.Name, err, attempt, delay.Round(time.Millisecond))
+			if !s.wait(delay) {
+				return
+			}
+			continue
+		}
+
+		if outageStart.IsZero() {
+			s.logger.Printf("✅ Connection established for %s", s.Config.Name)
+		} else {
+			now := time.Now()
+			s.logger.Printf("✅ Reconnected to %s after %d attempt(s), outage %s → %s (%s)",
+				s.Config.Name, attempt, outageStart.Format(time.RFC3339), now.Format(time.RFC3339), now.Sub(outageStart).Round(time.Millisecond))
+			outageStart = time.Time{}
+		}
+		attempt = 0
+
+		done := make(chan struct{})
+		go KeepAlive(s.Adapter, conn, &s.writeMu, done, s.Config, s.logger)
+		ReceiveMessages(conn, messageQueue, done, s.Config, s.logger)
+		conn.Close()
+
+		if s.isStopped() {
+			return
+		}
+		outageStart = time.Now()
+		s.logger.Printf("⚠️ Connection to %s lost at %s, reconnecting", s.Config.Name, outageStart.Format(time.RFC3339))
+		attempt = 1
+		if !s.wait(s.Backoff.Delay(0)) {
+			return
+		}
+	}
+}
+
+// connect bootstraps, dials and subscribes, registering the connection so Stop can close it.
+func (s *Supervisor) connect() (*websocket.Conn, error) {
+	if bootstrapper, ok := s.Adapter.(Bootstrapper); ok {
+		if err := bootstrapper.Bootstrap(); err != nil {
+			return nil, fmt.Errorf("bootstrap failed: %w", err)
+		}
+	}
+
+	conn, err := s.Adapter.Dial()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		conn.Close()
+		return nil, errStopped
+	}
+	s.conn = conn
+	s.mu.Unlock()
+
+	s.writeMu.Lock()
+	err = s.Adapter.Subscribe(conn)
+	s.writeMu.Unlock()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// Send writes a text frame on the live connection. Writes are serialised with
+// subscriptions and heartbeats, since a websocket allows one writer at a time.
+func (s *Supervisor) Send(message []byte) error {
+	s.mu.Lock()
+	conn, stopped := s.conn, s.stopped
+	s.mu.Unlock()
+	if stopped {
+		return errStopped
+	}
+	if conn == nil {
+		return errors.New("no live connection")
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	return conn.WriteMessage(websocket.TextMessage, message)
+}
+
+// wait sleeps for d and reports false if the supervisor was stopped meanwhile.
+func (s *Supervisor) wait(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-s.stop:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+func (s *Supervisor) isStopped() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stopped
+}
+
+// Stop closes the live connection through the adapter and prevents any redial.
+func (s *Supervisor) Stop() {
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		return
+	}
+	s.stopped = true
+	close(s.stop)
+	conn := s.conn
+	s.mu.Unlock()
+
+	if conn != nil {
+		CloseConnection(s.Adapter, conn, s.Config.Name, s.logger)
+	}
+}
@@ -0,0 +1,16 @@
+// Package handlers links every exchange adapter into the binary. Each adapter
+// registers itself with the exchange package from its init function, so adding
+// a venue only needs a new import here.
+package handlers
+
+import (
+	_ "github.com/Antkky/go_crypto_scraper/handlers/binance"
+	_ "github.com/Antkky/go_crypto_scraper/handlers/bitfinex"
+	_ "github.com/Antkky/go_crypto_scraper/handlers/bybit"
+	_ "github.com/Antkky/go_crypto_scraper/handlers/coinbase"
+	_ "github.com/Antkky/go_crypto_scraper/handlers/coinex"
+	_ "github.com/Antkky/go_crypto_scraper/handlers/htx"
+	_ "github.com/Antkky/go_crypto_scraper/handlers/kraken"
+	_ "github.com/Antkky/go_crypto_scraper/handlers/kucoin"
+	_ "github.com/Antkky/go_crypto_scraper/handlers/okx"
+)
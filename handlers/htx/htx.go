@@ -0,0 +1,210 @@
+package htx
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/Antkky/go_crypto_scraper/handlers/exchange"
+	"github.com/Antkky/go_crypto_scraper/utils"
+	"github.com/Antkky/go_crypto_scraper/utils/codec"
+	"github.com/gorilla/websocket"
+)
+
+func init() {
+	exchange.Register("htx", New)
+}
+
+// channelSuffixes maps our stream types onto HTX market channels
+var channelSuffixes = map[string]string{
+	"trade":  "trade.detail",
+	"ticker": "bbo",
+}
+
+// Adapter implements exchange.Adapter for the HTX (Huobi) market websocket.
+type Adapter struct {
+	exchange.Base
+	subscriptions map[string]Subscription
+}
+
+// New builds an HTX adapter for the given config.
+func New(config utils.ExchangeConfig, logger *log.Logger) exchange.Adapter {
+	return &Adapter{
+		Base:          exchange.Base{Config: config, Logger: logger},
+		subscriptions: Subscriptions(config.Streams),
+	}
+}
+
+func (a *Adapter) Parse(message []byte) ([]utils.ParsedMessage, error) {
+	return ProcessMessage(message, a.subscriptions)
+}
+
+// Subscribe sends the configured message for each stream, or builds one from
+// the stream's type and symbol when the message is left out.
+func (a *Adapter) Subscribe(conn *websocket.Conn) error {
+	messages := make([]json.RawMessage, 0, len(a.Config.Streams))
+	for i, stream := range a.Config.Streams {
+		if len(stream.Message) > 0 {
+			messages = append(messages, stream.Message)
+			continue
+		}
+		message, err := SubscribeMessageFor(stream, i+1)
+		if err != nil {
+			return err
+		}
+		messages = append(messages, message)
+	}
+	return a.SendSubscriptions(conn, messages)
+}
+
+// ________Small Helper Functions________
+
+// Channel returns the HTX channel for a stream, e.g. market.btcusdt.trade.detail
+func Channel(stream utils.StreamConfig) (string, error) {
+	suffix, exists := channelSuffixes[stream.Type]
+	if !exists {
+		return "", fmt.Errorf("htx: unsupported stream type %q", stream.Type)
+	}
+	return fmt.Sprintf("market.%s.%s", strings.ToLower(stream.Symbol), suffix), nil
+}
+
+// SubscribeMessageFor builds the subscribe request for a stream without an explicit message
+func SubscribeMessageFor(stream utils.StreamConfig, id int) (json.RawMessage, error) {
+	channel, err := Channel(stream)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(SubscribeMessage{Sub: channel, ID: strconv.Itoa(id)})
+}
+
+// Subscriptions maps every configured channel to the stream's symbol and type
+func Subscriptions(streams []utils.StreamConfig) map[string]Subscription {
+	subscriptions := make(map[string]Subscription)
+	for _, stream := range streams {
+		subscription := Subscription{Symbol: stream.Symbol, DataType: stream.Type}
+
+		var sub SubscribeMessage
+		if len(stream.Message) > 0 && json.Unmarshal(stream.Message, &sub) == nil && sub.Sub != "" {
+			subscriptions[sub.Sub] = subscription
+			continue
+		}
+		if channel, err := Channel(stream); err == nil {
+			subscriptions[channel] = subscription
+		}
+	}
+	return subscriptions
+}
+
+// lookup finds the stream a channel belongs to, falling back to the upper cased symbol in the channel name
+func lookup(channel string, dataType string, subscriptions map[string]Subscription) Subscription {
+	if subscription, exists := subscriptions[channel]; exists {
+		return subscription
+	}
+	parts := strings.Split(channel, ".")
+	if len(parts) < 2 {
+		return Subscription{DataType: dataType}
+	}
+	return Subscription{Symbol: strings.ToUpper(parts[1]), DataType: dataType}
+}
+
+// decodeNumbers unmarshals keeping numbers as json.Number so prices keep their exact text
+func decodeNumbers(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// ________Main Functions________
+
+// ProcessMessage()
+//
+// Inputs:
+//
+//	message       : []byte
+//	subscriptions : map[string]Subscription
+//
+// Outputs:
+//
+//	[]utils.ParsedMessage
+//	error
+//
+// Description:
+//
+//	decompresses the gzip frame and routes market.$symbol.trade.detail and
+//	market.$symbol.bbo pushes into the shared structs. A {"ping":n} is answered
+//	with {"pong":n} through the message's Reply.
+func ProcessMessage(message []byte, subscriptions map[string]Subscription) ([]utils.ParsedMessage, error) {
+	decompressed, err := codec.Gunzip(message)
+	if err != nil {
+		return nil, err
+	}
+
+	var pMessage GlobalMessageStruct
+	if err := decodeNumbers(decompressed, &pMessage); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal htx message: %w", err)
+	}
+
+	switch {
+	case pMessage.Ping != nil:
+		return []utils.ParsedMessage{{
+			Event: "ping",
+			Reply: []byte(fmt.Sprintf(`{"pong":%s}`, pMessage.Ping.String())),
+		}}, nil
+
+	case pMessage.Status == "error":
+		return nil, fmt.Errorf("htx error: %s", pMessage.ErrMsg)
+
+	case pMessage.Subbed != "":
+		return []utils.ParsedMessage{{Event: "subscribed"}}, nil
+
+	case strings.HasSuffix(pMessage.Ch, ".trade.detail"):
+		var tick TradeTick
+		if err := decodeNumbers(pMessage.Tick, &tick); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal trade data: %w", err)
+		}
+		subscription := lookup(pMessage.Ch, "trade", subscriptions)
+
+		trades := make([]utils.TradeDataStruct, 0, len(tick.Data))
+		for _, trade := range tick.Data {
+			trades = append(trades, utils.TradeDataStruct{
+				TimeStamp: uint64(trade.TS),
+				Symbol:    subscription.Symbol,
+				Price:     trade.Price.String(),
+				Quantity:  trade.Amount.String(),
+				Bid_MM:    trade.Direction == "sell",
+				TradeID:   trade.TradeID.String(),
+			})
+		}
+		return []utils.ParsedMessage{{
+			DataType: subscription.DataType,
+			Symbol:   subscription.Symbol,
+			Data:     trades,
+		}}, nil
+
+	case strings.HasSuffix(pMessage.Ch, ".bbo"):
+		var tick BBOTick
+		if err := decodeNumbers(pMessage.Tick, &tick); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal bbo data: %w", err)
+		}
+		subscription := lookup(pMessage.Ch, "ticker", subscriptions)
+
+		return []utils.ParsedMessage{{
+			DataType: subscription.DataType,
+			Symbol:   subscription.Symbol,
+			Data: []utils.TickerDataStruct{{
+				TimeStamp: uint64(tick.QuoteTime),
+				Symbol:    subscription.Symbol,
+				BidPrice:  tick.Bid.String(),
+				BidSize:   tick.BidSize.String(),
+				AskPrice:  tick.Ask.String(),
+				AskSize:   tick.AskSize.String(),
+			}},
+		}}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown message type: %s", pMessage.Ch)
+	}
+}
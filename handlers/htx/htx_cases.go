@@ -0,0 +1,75 @@
+package htx
+
+import (
+	"github.com/Antkky/go_crypto_scraper/utils"
+	"github.com/Antkky/go_crypto_scraper/utils/codec"
+)
+
+// gzipped compresses a test payload the way HTX frames arrive on the wire
+func gzipped(payload string) []byte {
+	compressed, _ := codec.Gzip([]byte(payload))
+	return compressed
+}
+
+// Streams the test subscriptions are built from
+var testStreams = []utils.StreamConfig{
+	{Type: "trade", Symbol: "BTCUSDT", Market: "spot"},
+	{Type: "ticker", Symbol: "BTCUSDT", Market: "spot"},
+}
+
+// Test Cases for ProcessMessage
+var ProcessMessageCases = []struct {
+	name      string
+	message   []byte
+	want      []utils.ParsedMessage
+	wantError bool
+}{
+	{
+		name:    "ping is answered with pong",
+		message: gzipped(`{"ping":1492420473027}`),
+		want:    []utils.ParsedMessage{{Event: "ping", Reply: []byte(`{"pong":1492420473027}`)}},
+	},
+	{
+		name:    "trade detail",
+		message: gzipped(`{"ch":"market.btcusdt.trade.detail","ts":1630994963175,"tick":{"id":137005445109,"ts":1630994963173,"data":[{"id":137005445109359286410323766,"ts":1630994963173,"tradeId":102523573486,"amount":0.006754,"price":52648.62,"direction":"buy"},{"id":137005445109359286410323767,"ts":1630994963174,"tradeId":102523573487,"amount":0.1,"price":52648.6,"direction":"sell"}]}}`),
+		want: []utils.ParsedMessage{{
+			DataType: "trade",
+			Symbol:   "BTCUSDT",
+			Data: []utils.TradeDataStruct{
+				{TimeStamp: 1630994963173, Symbol: "BTCUSDT", Price: "52648.62", Quantity: "0.006754", Bid_MM: false, TradeID: "102523573486"},
+				{TimeStamp: 1630994963174, Symbol: "BTCUSDT", Price: "52648.6", Quantity: "0.1", Bid_MM: true, TradeID: "102523573487"},
+			},
+		}},
+	},
+	{
+		name:    "bbo",
+		message: gzipped(`{"ch":"market.btcusdt.bbo","ts":1630994555540,"tick":{"seqId":137005092306,"ask":52665.69,"askSize":1.502181,"bid":52665.68,"bidSize":0.035945,"quoteTime":1630994555539,"symbol":"btcusdt"}}`),
+		want: []utils.ParsedMessage{{
+			DataType: "ticker",
+			Symbol:   "BTCUSDT",
+			Data: []utils.TickerDataStruct{{
+				TimeStamp: 1630994555539,
+				Symbol:    "BTCUSDT",
+				BidPrice:  "52665.68",
+				BidSize:   "0.035945",
+				AskPrice:  "52665.69",
+				AskSize:   "1.502181",
+			}},
+		}},
+	},
+	{
+		name:    "sub reply",
+		message: gzipped(`{"id":"1","status":"ok","subbed":"market.btcusdt.bbo","ts":1489474081631}`),
+		want:    []utils.ParsedMessage{{Event: "subscribed"}},
+	},
+	{
+		name:      "sub error",
+		message:   gzipped(`{"id":"2","status":"error","err-code":"bad-request","err-msg":"invalid topic market.nope.bbo","ts":1494301904959}`),
+		wantError: true,
+	},
+	{
+		name:      "not gzipped",
+		message:   []byte(`{"ping":1}`),
+		wantError: true,
+	},
+}
@@ -0,0 +1,23 @@
+package htx
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessMessage(t *testing.T) {
+	subscriptions := Subscriptions(testStreams)
+
+	for _, tt := range ProcessMessageCases {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := ProcessMessage(tt.message, subscriptions)
+			if tt.wantError {
+				assert.Error(t, err, "Expected an error but got none")
+				return
+			}
+			assert.NoError(t, err, "Unexpected error occurred")
+			assert.Equal(t, tt.want, parsed)
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package htx
+
+import "encoding/json"
+
+// SubscribeMessage is the {"sub":"market.btcusdt.trade.detail","id":"1"} request
+type SubscribeMessage struct {
+	Sub string `json:"sub"`
+	ID  string `json:"id"`
+}
+
+// Global Message Struct, covers pings, sub replies and channel pushes
+type GlobalMessageStruct struct {
+	Ping   *json.Number    `json:"ping"`
+	Ch     string          `json:"ch"`
+	TS     int64           `json:"ts"`
+	Tick   json.RawMessage `json:"tick"`
+	ID     string          `json:"id"`
+	Status string          `json:"status"`
+	Subbed string          `json:"subbed"`
+	ErrMsg string          `json:"err-msg"`
+}
+
+type TradeTick struct {
+	ID   json.Number `json:"id"`
+	TS   int64       `json:"ts"`
+	Data []TradeData `json:"data"`
+}
+
+type TradeData struct {
+	ID        json.Number `json:"id"`
+	TS        int64       `json:"ts"`
+	TradeID   json.Number `json:"tradeId"`
+	Amount    json.Number `json:"amount"`
+	Price     json.Number `json:"price"`
+	Direction string      `json:"direction"`
+}
+
+type BBOTick struct {
+	SeqID     json.Number `json:"seqId"`
+	Ask       json.Number `json:"ask"`
+	AskSize   json.Number `json:"askSize"`
+	Bid       json.Number `json:"bid"`
+	BidSize   json.Number `json:"bidSize"`
+	QuoteTime int64       `json:"quoteTime"`
+	Symbol    string      `json:"symbol"`
+}
+
+// Subscription maps an HTX channel back to the configured stream
+type Subscription struct {
+	Symbol   string
+	DataType string
+}
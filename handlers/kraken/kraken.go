@@ -0,0 +1,288 @@
+package kraken
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Antkky/go_crypto_scraper/handlers/exchange"
+	"github.com/Antkky/go_crypto_scraper/utils"
+	"github.com/gorilla/websocket"
+)
+
+func init() {
+	exchange.Register("kraken", New)
+}
+
+// now is swapped out in tests for ticker frames that carry no timestamp
+var now = time.Now
+
+// channels maps our stream types onto Kraken v2 channels
+var channels = map[string]string{
+	"trade":  "trade",
+	"ticker": "ticker",
+}
+
+// Adapter implements exchange.Adapter for the Kraken v2 public websocket.
+type Adapter struct {
+	exchange.Base
+	state *State
+}
+
+// New builds a Kraken adapter for the given config.
+func New(config utils.ExchangeConfig, logger *log.Logger) exchange.Adapter {
+	return &Adapter{
+		Base:  exchange.Base{Config: config, Logger: logger},
+		state: NewState(config.Streams),
+	}
+}
+
+func (a *Adapter) Parse(message []byte) ([]utils.ParsedMessage, error) {
+	return ProcessMessage(message, a.state)
+}
+
+// Subscribe sends the configured message for each stream, or builds one from
+// the stream's type and symbol when the message is left out.
+func (a *Adapter) Subscribe(conn *websocket.Conn) error {
+	messages := make([]json.RawMessage, 0, len(a.Config.Streams))
+	for _, stream := range a.Config.Streams {
+		if len(stream.Message) > 0 {
+			messages = append(messages, stream.Message)
+			continue
+		}
+		message, err := SubscribeMessageFor(stream)
+		if err != nil {
+			return err
+		}
+		messages = append(messages, message)
+	}
+	return a.SendSubscriptions(conn, messages)
+}
+
+// Heartbeat sends {"method":"ping"} unless a custom ping is configured.
+func (a *Adapter) Heartbeat(conn *websocket.Conn) error {
+	if len(a.Config.Ping) > 0 {
+		return a.Base.Heartbeat(conn)
+	}
+	return conn.WriteMessage(websocket.TextMessage, []byte(`{"method":"ping"}`))
+}
+
+// ________Small Helper Functions________
+
+// Pair converts a symbol such as BTCUSD into Kraken's BTC/USD form
+func Pair(symbol string) string {
+	if strings.Contains(symbol, "/") {
+		return symbol
+	}
+	if base, quote, ok := utils.SplitSymbol(symbol); ok {
+		return base + "/" + quote
+	}
+	return symbol
+}
+
+// Symbol converts a Kraken pair such as BTC/USD into our BTCUSD convention
+func Symbol(pair string) string {
+	return strings.ReplaceAll(pair, "/", "")
+}
+
+// SubscribeMessageFor builds the subscribe request for a stream without an explicit message.
+// Tickers use event_trigger bbo so every best bid/offer change is pushed.
+func SubscribeMessageFor(stream utils.StreamConfig) (json.RawMessage, error) {
+	channel, exists := channels[stream.Type]
+	if !exists {
+		return nil, fmt.Errorf("kraken: unsupported stream type %q", stream.Type)
+	}
+	params := SubscribeParams{Channel: channel, Symbol: []string{Pair(stream.Symbol)}}
+	if channel == "ticker" {
+		params.EventTrigger = "bbo"
+	}
+	return json.Marshal(SubscribeMessage{Method: "subscribe", Params: params})
+}
+
+// NewState maps every configured channel/pair to the stream's symbol and type
+func NewState(streams []utils.StreamConfig) *State {
+	state := &State{
+		Subscriptions: make(map[SubscriptionKey]Subscription),
+		LastTradeID:   make(map[string]int64),
+	}
+	for _, stream := range streams {
+		subscription := Subscription{Symbol: stream.Symbol, DataType: stream.Type}
+
+		var sub SubscribeMessage
+		if len(stream.Message) > 0 && json.Unmarshal(stream.Message, &sub) == nil && len(sub.Params.Symbol) > 0 {
+			for _, pair := range sub.Params.Symbol {
+				state.Subscriptions[SubscriptionKey{Channel: sub.Params.Channel, Pair: pair}] = subscription
+			}
+			continue
+		}
+		if channel, exists := channels[stream.Type]; exists {
+			state.Subscriptions[SubscriptionKey{Channel: channel, Pair: Pair(stream.Symbol)}] = subscription
+		}
+	}
+	return state
+}
+
+// lookup finds the stream a pair belongs to, falling back to the pair with the slash removed
+func (s *State) lookup(channel string, pair string) Subscription {
+	if subscription, exists := s.Subscriptions[SubscriptionKey{Channel: channel, Pair: pair}]; exists {
+		return subscription
+	}
+	return Subscription{Symbol: Symbol(pair), DataType: channel}
+}
+
+// decodeNumbers unmarshals keeping numbers as json.Number so prices keep their exact text
+func decodeNumbers(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// parseTimestamp converts Kraken's RFC3339 timestamps into unix milliseconds
+func parseTimestamp(timestamp string) (uint64, error) {
+	if timestamp == "" {
+		return uint64(now().UnixMilli()), nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, timestamp)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timestamp %q: %w", timestamp, err)
+	}
+	return uint64(t.UnixMilli()), nil
+}
+
+func processMethod(pMessage GlobalMessageStruct) ([]utils.ParsedMessage, error) {
+	if pMessage.Success != nil && !*pMessage.Success {
+		return nil, fmt.Errorf("kraken %s failed: %s", pMessage.Method, pMessage.Error)
+	}
+	switch pMessage.Method {
+	case "subscribe":
+		return []utils.ParsedMessage{{Event: "subscribed"}}, nil
+	case "pong":
+		return []utils.ParsedMessage{{Event: "pong"}}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// processTrades groups trades per pair and drops any already written, which is
+// how the snapshot sent after a resubscribe is kept out of the files. Kraken
+// trade ids go up by one per pair, so skipped ids are reported as a gap.
+func processTrades(data json.RawMessage, state *State) ([]utils.ParsedMessage, error) {
+	var tradeMsg []TradeData
+	if err := decodeNumbers(data, &tradeMsg); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal trade data: %w", err)
+	}
+
+	var parsed []utils.ParsedMessage
+	index := make(map[string]int)
+	for _, trade := range tradeMsg {
+		subscription := state.lookup("trade", trade.Symbol)
+		if last, seen := state.LastTradeID[trade.Symbol]; seen {
+			if trade.TradeID <= last {
+				continue
+			}
+			if gap, missed := utils.TradeIDGap(subscription.Symbol, last, trade.TradeID); missed {
+				parsed = append(parsed, utils.ParsedMessage{DataType: "gap", Symbol: subscription.Symbol, Data: []utils.GapDataStruct{gap}})
+			}
+		}
+		state.LastTradeID[trade.Symbol] = trade.TradeID
+
+		timestamp, err := parseTimestamp(trade.Timestamp)
+		if err != nil {
+			return nil, err
+		}
+		record := utils.TradeDataStruct{
+			TimeStamp: timestamp,
+			Symbol:    subscription.Symbol,
+			Price:     trade.Price.String(),
+			Quantity:  trade.Qty.String(),
+			Bid_MM:    trade.Side == "sell",
+			TradeID:   strconv.FormatInt(trade.TradeID, 10),
+		}
+
+		i, exists := index[trade.Symbol]
+		if !exists {
+			i = len(parsed)
+			index[trade.Symbol] = i
+			parsed = append(parsed, utils.ParsedMessage{
+				DataType: subscription.DataType,
+				Symbol:   subscription.Symbol,
+				Data:     []utils.TradeDataStruct{},
+			})
+		}
+		parsed[i].Data = append(parsed[i].Data.([]utils.TradeDataStruct), record)
+	}
+	return parsed, nil
+}
+
+func processTickers(data json.RawMessage, state *State) ([]utils.ParsedMessage, error) {
+	var tickerMsg []TickerData
+	if err := decodeNumbers(data, &tickerMsg); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal ticker data: %w", err)
+	}
+
+	parsed := make([]utils.ParsedMessage, 0, len(tickerMsg))
+	for _, ticker := range tickerMsg {
+		timestamp, err := parseTimestamp(ticker.Timestamp)
+		if err != nil {
+			return nil, err
+		}
+		subscription := state.lookup("ticker", ticker.Symbol)
+		parsed = append(parsed, utils.ParsedMessage{
+			DataType: subscription.DataType,
+			Symbol:   subscription.Symbol,
+			Data: []utils.TickerDataStruct{{
+				TimeStamp: timestamp,
+				Symbol:    subscription.Symbol,
+				BidPrice:  ticker.Bid.String(),
+				BidSize:   ticker.BidQty.String(),
+				AskPrice:  ticker.Ask.String(),
+				AskSize:   ticker.AskQty.String(),
+			}},
+		})
+	}
+	return parsed, nil
+}
+
+// ________Main Functions________
+
+// ProcessMessage()
+//
+// Inputs:
+//
+//	message : []byte
+//	state   : *State
+//
+// Outputs:
+//
+//	[]utils.ParsedMessage
+//	error
+//
+// Description:
+//
+//	routes trade and ticker pushes into the shared structs. Snapshots and
+//	updates are handled the same way; trade ids already written are skipped.
+func ProcessMessage(message []byte, state *State) ([]utils.ParsedMessage, error) {
+	var pMessage GlobalMessageStruct
+	if err := json.Unmarshal(message, &pMessage); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal kraken message: %w", err)
+	}
+
+	if pMessage.Method != "" {
+		return processMethod(pMessage)
+	}
+
+	switch pMessage.Channel {
+	case "heartbeat", "status":
+		return nil, nil
+	case "trade":
+		return processTrades(pMessage.Data, state)
+	case "ticker":
+		return processTickers(pMessage.Data, state)
+	default:
+		return nil, fmt.Errorf("unknown message type: %s", pMessage.Channel)
+	}
+}
@@ -0,0 +1,146 @@
+package kraken
+
+import (
+	"github.com/Antkky/go_crypto_scraper/utils"
+)
+
+// Streams the test state is built from
+var testStreams = []utils.StreamConfig{
+	{Type: "trade", Symbol: "BTCUSD", Market: "spot"},
+	{Type: "ticker", Symbol: "BTCUSD", Market: "spot"},
+	{Type: "trade", Symbol: "ETHUSDT", Market: "spot", Message: []byte(`{"method":"subscribe","params":{"channel":"trade","symbol":["ETH/USDT"]}}`)},
+}
+
+const (
+	tradeSnapshot = `{"channel":"trade","type":"snapshot","data":[
+		{"symbol":"BTC/USD","side":"sell","price":26000.1,"qty":0.5,"ord_type":"market","trade_id":100,"timestamp":"2023-09-25T07:48:36.925533Z"},
+		{"symbol":"BTC/USD","side":"buy","price":26000.2,"qty":0.25,"ord_type":"limit","trade_id":101,"timestamp":"2023-09-25T07:48:37.000000Z"}]}`
+	tradeUpdate = `{"channel":"trade","type":"update","data":[
+		{"symbol":"BTC/USD","side":"buy","price":26001,"qty":1.0,"ord_type":"market","trade_id":102,"timestamp":"2023-09-25T07:49:00.5Z"}]}`
+	tradeSnapshotAfterResubscribe = `{"channel":"trade","type":"snapshot","data":[
+		{"symbol":"BTC/USD","side":"buy","price":26000.2,"qty":0.25,"ord_type":"limit","trade_id":101,"timestamp":"2023-09-25T07:48:37.000000Z"},
+		{"symbol":"BTC/USD","side":"buy","price":26001,"qty":1.0,"ord_type":"market","trade_id":102,"timestamp":"2023-09-25T07:49:00.5Z"},
+		{"symbol":"BTC/USD","side":"sell","price":25999.9,"qty":0.1,"ord_type":"market","trade_id":103,"timestamp":"2023-09-25T07:50:00Z"}]}`
+)
+
+// Test Cases for SubscribeMessageFor
+var SubscribeMessageForCases = []struct {
+	name   string
+	stream utils.StreamConfig
+	want   string
+}{
+	{
+		name:   "trade",
+		stream: utils.StreamConfig{Type: "trade", Symbol: "BTCUSD"},
+		want:   `{"method":"subscribe","params":{"channel":"trade","symbol":["BTC/USD"]}}`,
+	},
+	{
+		name:   "ticker uses bbo trigger",
+		stream: utils.StreamConfig{Type: "ticker", Symbol: "ETHUSDT"},
+		want:   `{"method":"subscribe","params":{"channel":"ticker","symbol":["ETH/USDT"],"event_trigger":"bbo"}}`,
+	},
+}
+
+// Test Cases for ProcessMessage. Messages are fed in order through the same
+// state and only the result of the last one is checked.
+var ProcessMessageCases = []struct {
+	name      string
+	messages  []string
+	want      []utils.ParsedMessage
+	wantError bool
+}{
+	{
+		name:     "trade snapshot",
+		messages: []string{tradeSnapshot},
+		want: []utils.ParsedMessage{{
+			DataType: "trade",
+			Symbol:   "BTCUSD",
+			Data: []utils.TradeDataStruct{
+				{TimeStamp: 1695628116925, Symbol: "BTCUSD", Price: "26000.1", Quantity: "0.5", Bid_MM: true, TradeID: "100"},
+				{TimeStamp: 1695628117000, Symbol: "BTCUSD", Price: "26000.2", Quantity: "0.25", Bid_MM: false, TradeID: "101"},
+			},
+		}},
+	},
+	{
+		name:     "snapshot after resubscribe only keeps new trades",
+		messages: []string{tradeSnapshot, tradeUpdate, tradeSnapshotAfterResubscribe},
+		want: []utils.ParsedMessage{{
+			DataType: "trade",
+			Symbol:   "BTCUSD",
+			Data: []utils.TradeDataStruct{
+				{TimeStamp: 1695628200000, Symbol: "BTCUSD", Price: "25999.9", Quantity: "0.1", Bid_MM: true, TradeID: "103"},
+			},
+		}},
+	},
+	{
+		name:     "repeated snapshot writes nothing",
+		messages: []string{tradeSnapshot, tradeSnapshot},
+		want:     nil,
+	},
+	{
+		name:     "pair from explicit subscribe message",
+		messages: []string{`{"channel":"trade","type":"update","data":[{"symbol":"ETH/USDT","side":"buy","price":1600.5,"qty":2,"ord_type":"market","trade_id":7,"timestamp":"2023-09-25T07:49:00Z"}]}`},
+		want: []utils.ParsedMessage{{
+			DataType: "trade",
+			Symbol:   "ETHUSDT",
+			Data: []utils.TradeDataStruct{
+				{TimeStamp: 1695628140000, Symbol: "ETHUSDT", Price: "1600.5", Quantity: "2", Bid_MM: false, TradeID: "7"},
+			},
+		}},
+	},
+	{
+		name:     "ticker bbo",
+		messages: []string{`{"channel":"ticker","type":"update","data":[{"symbol":"BTC/USD","bid":26000.1,"bid_qty":1.25,"ask":26000.2,"ask_qty":0.004,"last":26000.1,"volume":1200.5,"vwap":26010,"low":25900,"high":26100,"change":10,"change_pct":0.04,"timestamp":"2023-09-25T07:49:00.123Z"}]}`},
+		want: []utils.ParsedMessage{{
+			DataType: "ticker",
+			Symbol:   "BTCUSD",
+			Data: []utils.TickerDataStruct{{
+				TimeStamp: 1695628140123,
+				Symbol:    "BTCUSD",
+				BidPrice:  "26000.1",
+				BidSize:   "1.25",
+				AskPrice:  "26000.2",
+				AskSize:   "0.004",
+			}},
+		}},
+	},
+	{
+		name:     "heartbeat",
+		messages: []string{`{"channel":"heartbeat"}`},
+		want:     nil,
+	},
+	{
+		name:     "subscribe ack",
+		messages: []string{`{"method":"subscribe","result":{"channel":"ticker","symbol":"BTC/USD","event_trigger":"bbo"},"success":true,"time_in":"2023-09-25T09:04:31.742599Z","time_out":"2023-09-25T09:04:31.742648Z"}`},
+		want:     []utils.ParsedMessage{{Event: "subscribed"}},
+	},
+	{
+		name:      "failed subscription",
+		messages:  []string{`{"method":"subscribe","error":"Currency pair not supported NOPE/USD","success":false}`},
+		wantError: true,
+	},
+}
+
+// Test Cases for trade id gaps
+var TradeGapCases = []struct {
+	name     string
+	messages []string
+	want     []utils.GapDataStruct
+}{
+	{
+		name:     "consecutive ids",
+		messages: []string{tradeSnapshot, tradeUpdate},
+		want:     nil,
+	},
+	{
+		name: "skipped ids are one gap",
+		messages: []string{tradeSnapshot, `{"channel":"trade","type":"update","data":[
+			{"symbol":"BTC/USD","side":"buy","price":26001,"qty":1.0,"ord_type":"market","trade_id":105,"timestamp":"2023-09-25T07:49:00.5Z"}]}`},
+		want: []utils.GapDataStruct{{Symbol: "BTCUSD", FirstMissing: 102, LastMissing: 104}},
+	},
+	{
+		name:     "replayed snapshot is not a gap",
+		messages: []string{tradeSnapshot, tradeUpdate, tradeSnapshotAfterResubscribe},
+		want:     nil,
+	},
+}
@@ -0,0 +1,63 @@
+package kraken
+
+import (
+	"testing"
+
+	"github.com/Antkky/go_crypto_scraper/handlers/exchange/exchangetest"
+	"github.com/Antkky/go_crypto_scraper/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscribeMessageFor(t *testing.T) {
+	for _, tt := range SubscribeMessageForCases {
+		t.Run(tt.name, func(t *testing.T) {
+			message, err := SubscribeMessageFor(tt.stream)
+			assert.NoError(t, err)
+			assert.JSONEq(t, tt.want, string(message))
+		})
+	}
+}
+
+func TestProcessMessage(t *testing.T) {
+	for _, tt := range ProcessMessageCases {
+		t.Run(tt.name, func(t *testing.T) {
+			state := NewState(testStreams)
+
+			parsed, err := exchangetest.ProcessAll(t, tt.messages, func(message []byte) ([]utils.ParsedMessage, error) {
+				return ProcessMessage(message, state)
+			})
+
+			if tt.wantError {
+				assert.Error(t, err, "Expected an error but got none")
+				return
+			}
+			assert.NoError(t, err, "Unexpected error occurred")
+			assert.Equal(t, tt.want, parsed)
+		})
+	}
+}
+
+func TestTradeGaps(t *testing.T) {
+	for _, tt := range TradeGapCases {
+		t.Run(tt.name, func(t *testing.T) {
+			state := NewState(testStreams)
+
+			var gaps []utils.GapDataStruct
+			for _, message := range tt.messages {
+				parsed, err := ProcessMessage([]byte(message), state)
+				assert.NoError(t, err, "Unexpected error occurred")
+				for _, msg := range parsed {
+					if msg.DataType != "gap" {
+						continue
+					}
+					for _, gap := range msg.Data.([]utils.GapDataStruct) {
+						assert.NotZero(t, gap.WallTime)
+						gap.WallTime = 0
+						gaps = append(gaps, gap)
+					}
+				}
+			}
+			assert.Equal(t, tt.want, gaps)
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package kraken
+
+import "encoding/json"
+
+// SubscribeParams is the params object of a v2 subscribe request
+type SubscribeParams struct {
+	Channel      string   `json:"channel"`
+	Symbol       []string `json:"symbol"`
+	EventTrigger string   `json:"event_trigger,omitempty"`
+	Snapshot     *bool    `json:"snapshot,omitempty"`
+}
+
+// SubscribeMessage is the {"method":"subscribe","params":{...}} request
+type SubscribeMessage struct {
+	Method string          `json:"method"`
+	Params SubscribeParams `json:"params"`
+}
+
+// Global Message Struct, covers channel pushes as well as method replies
+type GlobalMessageStruct struct {
+	Channel string          `json:"channel"`
+	Type    string          `json:"type"`
+	Data    json.RawMessage `json:"data"`
+	Method  string          `json:"method"`
+	Success *bool           `json:"success"`
+	Error   string          `json:"error"`
+}
+
+type TradeData struct {
+	Symbol    string      `json:"symbol"`
+	Side      string      `json:"side"`
+	Price     json.Number `json:"price"`
+	Qty       json.Number `json:"qty"`
+	OrdType   string      `json:"ord_type"`
+	TradeID   int64       `json:"trade_id"`
+	Timestamp string      `json:"timestamp"`
+}
+
+type TickerData struct {
+	Symbol    string      `json:"symbol"`
+	Bid       json.Number `json:"bid"`
+	BidQty    json.Number `json:"bid_qty"`
+	Ask       json.Number `json:"ask"`
+	AskQty    json.Number `json:"ask_qty"`
+	Last      json.Number `json:"last"`
+	Volume    json.Number `json:"volume"`
+	Timestamp string      `json:"timestamp"`
+}
+
+// Subscription maps a Kraken channel and pair back to the configured stream
+type Subscription struct {
+	Symbol   string
+	DataType string
+}
+
+// SubscriptionKey identifies a channel for one Kraken pair such as BTC/USD
+type SubscriptionKey struct {
+	Channel string
+	Pair    string
+}
+
+// State is what the adapter remembers across messages on a connection
+type State struct {
+	Subscriptions map[SubscriptionKey]Subscription
+	// LastTradeID is the highest trade id written per pair, used to drop
+	// snapshot trades that were already recorded before a resubscribe.
+	LastTradeID map[string]int64
+}
@@ -0,0 +1,303 @@
+package kucoin
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Antkky/go_crypto_scraper/handlers/exchange"
+	"github.com/Antkky/go_crypto_scraper/utils"
+	"github.com/gorilla/websocket"
+)
+
+func init() {
+	exchange.Register("kucoin", New)
+}
+
+const defaultBootstrapURI = "https://api.kucoin.com/api/v1/bullet-public"
+
+// topics maps our stream types onto KuCoin public topics
+var topics = map[string]string{
+	"trade":  "/market/match",
+	"ticker": "/market/ticker",
+}
+
+// Adapter implements exchange.Adapter for the KuCoin spot websocket. KuCoin
+// hands out the websocket endpoint and a token over REST, so the adapter
+// implements exchange.Bootstrapper and dials whatever the bullet call returned.
+type Adapter struct {
+	exchange.Base
+	Client        *http.Client
+	subscriptions map[SubscriptionKey]Subscription
+
+	endpoint string
+	token    string
+}
+
+// New builds a KuCoin adapter for the given config.
+func New(config utils.ExchangeConfig, logger *log.Logger) exchange.Adapter {
+	return &Adapter{
+		Base:          exchange.Base{Config: config, Logger: logger},
+		Client:        &http.Client{Timeout: 10 * time.Second},
+		subscriptions: Subscriptions(config.Streams),
+	}
+}
+
+func (a *Adapter) Parse(message []byte) ([]utils.ParsedMessage, error) {
+	return ProcessMessage(message, a.subscriptions)
+}
+
+// Bootstrap()
+//
+// Inputs:
+//
+//	No Inputs
+//
+// Outputs:
+//
+//	error
+//
+// Description:
+//
+//	POSTs to the bullet-public endpoint (config.BootstrapURI) and keeps the token and
+//	first instance server for the next Dial. Tokens are single use, so this runs before every dial.
+func (a *Adapter) Bootstrap() error {
+	bootstrapURI := a.Config.BootstrapURI
+	if bootstrapURI == "" {
+		bootstrapURI = defaultBootstrapURI
+	}
+
+	resp, err := a.Client.Post(bootstrapURI, "application/json", nil)
+	if err != nil {
+		return fmt.Errorf("bullet request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("bullet request returned %s", resp.Status)
+	}
+
+	var bullet BulletResponse
+	if err := json.NewDecoder(resp.Body).Decode(&bullet); err != nil {
+		return fmt.Errorf("failed to decode bullet response: %w", err)
+	}
+	if bullet.Code != "200000" {
+		return fmt.Errorf("bullet request failed with code %s: %s", bullet.Code, bullet.Msg)
+	}
+	if bullet.Data.Token == "" || len(bullet.Data.InstanceServers) == 0 {
+		return fmt.Errorf("bullet response has no token or instance server")
+	}
+
+	a.token = bullet.Data.Token
+	a.endpoint = bullet.Data.InstanceServers[0].Endpoint
+	return nil
+}
+
+// Dial connects to the endpoint returned by Bootstrap with the token and a connect id.
+func (a *Adapter) Dial() (*websocket.Conn, error) {
+	if a.endpoint == "" {
+		return nil, fmt.Errorf("kucoin: Dial called before Bootstrap")
+	}
+
+	endpoint, err := url.Parse(a.endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid kucoin endpoint %q: %w", a.endpoint, err)
+	}
+	query := endpoint.Query()
+	query.Set("token", a.token)
+	query.Set("connectId", strconv.FormatInt(time.Now().UnixNano(), 10))
+	endpoint.RawQuery = query.Encode()
+
+	return a.DialURI(endpoint.String())
+}
+
+// Subscribe sends the configured message for each stream, or builds one from
+// the stream's type and symbol when the message is left out.
+func (a *Adapter) Subscribe(conn *websocket.Conn) error {
+	messages := make([]json.RawMessage, 0, len(a.Config.Streams))
+	for i, stream := range a.Config.Streams {
+		if len(stream.Message) > 0 {
+			messages = append(messages, stream.Message)
+			continue
+		}
+		message, err := SubscribeMessageFor(stream, i+1)
+		if err != nil {
+			return err
+		}
+		messages = append(messages, message)
+	}
+	return a.SendSubscriptions(conn, messages)
+}
+
+// Heartbeat sends KuCoin's {"type":"ping"} unless a custom ping is configured.
+func (a *Adapter) Heartbeat(conn *websocket.Conn) error {
+	if len(a.Config.Ping) > 0 {
+		return a.Base.Heartbeat(conn)
+	}
+	ping := fmt.Sprintf(`{"id":"%d","type":"ping"}`, time.Now().UnixMilli())
+	return conn.WriteMessage(websocket.TextMessage, []byte(ping))
+}
+
+// ________Small Helper Functions________
+
+// KucoinSymbol converts a symbol such as BTCUSDT into KuCoin's BTC-USDT form
+func KucoinSymbol(symbol string) string {
+	if strings.Contains(symbol, "-") {
+		return symbol
+	}
+	if base, quote, ok := utils.SplitSymbol(symbol); ok {
+		return base + "-" + quote
+	}
+	return symbol
+}
+
+// SubscribeMessageFor builds the subscribe request for a stream without an explicit message
+func SubscribeMessageFor(stream utils.StreamConfig, id int) (json.RawMessage, error) {
+	topic, exists := topics[stream.Type]
+	if !exists {
+		return nil, fmt.Errorf("kucoin: unsupported stream type %q", stream.Type)
+	}
+	return json.Marshal(SubscribeMessage{
+		ID:       strconv.Itoa(id),
+		Type:     "subscribe",
+		Topic:    topic + ":" + KucoinSymbol(stream.Symbol),
+		Response: true,
+	})
+}
+
+// splitTopic splits "/market/match:BTC-USDT,ETH-USDT" into its prefix and symbols
+func splitTopic(topic string) (string, []string) {
+	prefix, symbols, found := strings.Cut(topic, ":")
+	if !found {
+		return topic, nil
+	}
+	return prefix, strings.Split(symbols, ",")
+}
+
+// Subscriptions maps every configured topic/symbol to the stream's symbol and type
+func Subscriptions(streams []utils.StreamConfig) map[SubscriptionKey]Subscription {
+	subscriptions := make(map[SubscriptionKey]Subscription)
+	for _, stream := range streams {
+		subscription := Subscription{Symbol: stream.Symbol, DataType: stream.Type}
+
+		var sub SubscribeMessage
+		if len(stream.Message) > 0 && json.Unmarshal(stream.Message, &sub) == nil && sub.Topic != "" {
+			prefix, symbols := splitTopic(sub.Topic)
+			for _, symbol := range symbols {
+				subscriptions[SubscriptionKey{Topic: prefix, Symbol: symbol}] = subscription
+			}
+			continue
+		}
+		if topic, exists := topics[stream.Type]; exists {
+			subscriptions[SubscriptionKey{Topic: topic, Symbol: KucoinSymbol(stream.Symbol)}] = subscription
+		}
+	}
+	return subscriptions
+}
+
+// lookup finds the stream a topic belongs to, falling back to the symbol with the dash removed
+func lookup(topic string, symbol string, subscriptions map[SubscriptionKey]Subscription) Subscription {
+	if subscription, exists := subscriptions[SubscriptionKey{Topic: topic, Symbol: symbol}]; exists {
+		return subscription
+	}
+	dataType := "ticker"
+	if topic == "/market/match" {
+		dataType = "trade"
+	}
+	return Subscription{Symbol: strings.ReplaceAll(symbol, "-", ""), DataType: dataType}
+}
+
+// ________Main Functions________
+
+// ProcessMessage()
+//
+// Inputs:
+//
+//	message       : []byte
+//	subscriptions : map[SubscriptionKey]Subscription
+//
+// Outputs:
+//
+//	[]utils.ParsedMessage
+//	error
+//
+// Description:
+//
+//	routes /market/match and /market/ticker pushes into the shared structs and
+//	recognises the welcome, ack, pong and error control frames
+func ProcessMessage(message []byte, subscriptions map[SubscriptionKey]Subscription) ([]utils.ParsedMessage, error) {
+	var pMessage GlobalMessageStruct
+	if err := json.Unmarshal(message, &pMessage); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal kucoin message: %w", err)
+	}
+
+	switch pMessage.Type {
+	case "welcome":
+		return nil, nil
+	case "ack":
+		return []utils.ParsedMessage{{Event: "subscribed"}}, nil
+	case "pong":
+		return []utils.ParsedMessage{{Event: "pong"}}, nil
+	case "error":
+		return nil, fmt.Errorf("kucoin error %d: %s", pMessage.Code, pMessage.Data)
+	case "message":
+	default:
+		return nil, fmt.Errorf("unknown message type: %s", pMessage.Type)
+	}
+
+	prefix, symbols := splitTopic(pMessage.Topic)
+	if len(symbols) != 1 {
+		return nil, fmt.Errorf("unexpected topic: %s", pMessage.Topic)
+	}
+	subscription := lookup(prefix, symbols[0], subscriptions)
+
+	switch prefix {
+	case "/market/match":
+		var matchMsg MatchData
+		if err := json.Unmarshal(pMessage.Data, &matchMsg); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal match data: %w", err)
+		}
+		nanos, err := strconv.ParseInt(matchMsg.Time, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid match time %q: %w", matchMsg.Time, err)
+		}
+		return []utils.ParsedMessage{{
+			DataType: subscription.DataType,
+			Symbol:   subscription.Symbol,
+			Data: []utils.TradeDataStruct{{
+				TimeStamp: uint64(nanos / int64(time.Millisecond)),
+				Symbol:    subscription.Symbol,
+				Price:     matchMsg.Price,
+				Quantity:  matchMsg.Size,
+				Bid_MM:    matchMsg.Side == "sell",
+				TradeID:   matchMsg.TradeID,
+			}},
+		}}, nil
+
+	case "/market/ticker":
+		var tickerMsg TickerData
+		if err := json.Unmarshal(pMessage.Data, &tickerMsg); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal ticker data: %w", err)
+		}
+		return []utils.ParsedMessage{{
+			DataType: subscription.DataType,
+			Symbol:   subscription.Symbol,
+			Data: []utils.TickerDataStruct{{
+				TimeStamp: uint64(tickerMsg.Time),
+				Symbol:    subscription.Symbol,
+				BidPrice:  tickerMsg.BestBid,
+				BidSize:   tickerMsg.BestBidSize,
+				AskPrice:  tickerMsg.BestAsk,
+				AskSize:   tickerMsg.BestAskSize,
+			}},
+		}}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown message type: %s", pMessage.Topic)
+	}
+}
@@ -0,0 +1,108 @@
+package kucoin
+
+import (
+	"github.com/Antkky/go_crypto_scraper/utils"
+)
+
+// Streams the test subscriptions are built from
+var testStreams = []utils.StreamConfig{
+	{Type: "trade", Symbol: "BTCUSDT", Market: "spot"},
+	{Type: "ticker", Symbol: "BTCUSDT", Market: "spot"},
+}
+
+// Test Cases for Bootstrap, served by a local stand-in for bullet-public
+var BootstrapCases = []struct {
+	name         string
+	status       int
+	body         string
+	wantToken    string
+	wantEndpoint string
+	wantError    bool
+}{
+	{
+		name:         "token and endpoint",
+		status:       200,
+		body:         `{"code":"200000","data":{"token":"2neAiuYvAU61ZD","instanceServers":[{"endpoint":"wss://ws-api-spot.kucoin.com/","encrypt":true,"protocol":"websocket","pingInterval":18000,"pingTimeout":10000}]}}`,
+		wantToken:    "2neAiuYvAU61ZD",
+		wantEndpoint: "wss://ws-api-spot.kucoin.com/",
+	},
+	{
+		name:      "error code",
+		status:    200,
+		body:      `{"code":"429000","msg":"Too Many Requests"}`,
+		wantError: true,
+	},
+	{
+		name:      "no instance servers",
+		status:    200,
+		body:      `{"code":"200000","data":{"token":"abc","instanceServers":[]}}`,
+		wantError: true,
+	},
+	{
+		name:      "http error",
+		status:    503,
+		body:      `unavailable`,
+		wantError: true,
+	},
+}
+
+// Test Cases for ProcessMessage
+var ProcessMessageCases = []struct {
+	name      string
+	message   string
+	want      []utils.ParsedMessage
+	wantError bool
+}{
+	{
+		name:    "match",
+		message: `{"type":"message","topic":"/market/match:BTC-USDT","subject":"trade.l3match","data":{"sequence":"1545896669145","type":"match","symbol":"BTC-USDT","side":"sell","price":"42000.1","size":"0.0102","tradeId":"5c24c5da03aa673885cd67aa","takerOrderId":"5c24c5d903aa6772d55b371e","makerOrderId":"5c2187d003aa677bd09d5c93","time":"1545913818099033203"}}`,
+		want: []utils.ParsedMessage{{
+			DataType: "trade",
+			Symbol:   "BTCUSDT",
+			Data: []utils.TradeDataStruct{{
+				TimeStamp: 1545913818099,
+				Symbol:    "BTCUSDT",
+				Price:     "42000.1",
+				Quantity:  "0.0102",
+				Bid_MM:    true,
+				TradeID:   "5c24c5da03aa673885cd67aa",
+			}},
+		}},
+	},
+	{
+		name:    "ticker",
+		message: `{"type":"message","topic":"/market/ticker:BTC-USDT","subject":"trade.ticker","data":{"sequence":"1545896668986","price":"42000.1","size":"0.011","bestAsk":"42000.2","bestAskSize":"0.18","bestBid":"42000.1","bestBidSize":"0.036","Time":1704873323416}}`,
+		want: []utils.ParsedMessage{{
+			DataType: "ticker",
+			Symbol:   "BTCUSDT",
+			Data: []utils.TickerDataStruct{{
+				TimeStamp: 1704873323416,
+				Symbol:    "BTCUSDT",
+				BidPrice:  "42000.1",
+				BidSize:   "0.036",
+				AskPrice:  "42000.2",
+				AskSize:   "0.18",
+			}},
+		}},
+	},
+	{
+		name:    "welcome",
+		message: `{"id":"hQvf8jkno","type":"welcome"}`,
+		want:    nil,
+	},
+	{
+		name:    "ack",
+		message: `{"id":"1","type":"ack"}`,
+		want:    []utils.ParsedMessage{{Event: "subscribed"}},
+	},
+	{
+		name:    "pong",
+		message: `{"id":"1545910590801","type":"pong"}`,
+		want:    []utils.ParsedMessage{{Event: "pong"}},
+	},
+	{
+		name:      "error",
+		message:   `{"id":"1","type":"error","code":404,"data":"topic /market/match:NOPE-USDT is not found"}`,
+		wantError: true,
+	},
+}
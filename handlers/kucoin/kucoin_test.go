@@ -0,0 +1,114 @@
+package kucoin
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Antkky/go_crypto_scraper/handlers/exchange"
+	"github.com/Antkky/go_crypto_scraper/utils"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+var testLogger = log.New(os.Stdout, "[Test] ", log.LstdFlags)
+
+func TestBootstrap(t *testing.T) {
+	for _, tt := range BootstrapCases {
+		t.Run(tt.name, func(t *testing.T) {
+			bullet := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, http.MethodPost, r.Method)
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer bullet.Close()
+
+			adapter := New(utils.ExchangeConfig{Name: "KuCoin", BootstrapURI: bullet.URL}, testLogger).(*Adapter)
+			err := adapter.Bootstrap()
+			if tt.wantError {
+				assert.Error(t, err, "Expected an error but got none")
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantToken, adapter.token)
+			assert.Equal(t, tt.wantEndpoint, adapter.endpoint)
+		})
+	}
+}
+
+// TestSupervisorBootstrap runs the adapter under a supervisor against local
+// stand-ins for bullet-public and the websocket endpoint it hands out.
+func TestSupervisorBootstrap(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		tokens   []string
+		messages []string
+	)
+	upgrader := websocket.Upgrader{}
+	ws := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		tokens = append(tokens, r.URL.Query().Get("token"))
+		mu.Unlock()
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"id":"welcome","type":"welcome"}`))
+		for {
+			_, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			messages = append(messages, string(message))
+			mu.Unlock()
+		}
+	}))
+	defer ws.Close()
+
+	bullet := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		endpoint := "ws" + strings.TrimPrefix(ws.URL, "http")
+		fmt.Fprintf(w, `{"code":"200000","data":{"token":"local-token","instanceServers":[{"endpoint":"%s","pingInterval":18000,"pingTimeout":10000}]}}`, endpoint)
+	}))
+	defer bullet.Close()
+
+	config := utils.ExchangeConfig{Name: "KuCoin", Exchange: "kucoin", BootstrapURI: bullet.URL, Streams: testStreams[:1]}
+	supervisor := exchange.NewSupervisor(New(config, testLogger), config, testLogger)
+	go supervisor.Run()
+	defer supervisor.Stop()
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(messages) > 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"local-token"}, tokens)
+	assert.JSONEq(t, `{"id":"1","type":"subscribe","topic":"/market/match:BTC-USDT","privateChannel":false,"response":true}`, messages[0])
+}
+
+func TestProcessMessage(t *testing.T) {
+	subscriptions := Subscriptions(testStreams)
+
+	for _, tt := range ProcessMessageCases {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := ProcessMessage([]byte(tt.message), subscriptions)
+			if tt.wantError {
+				assert.Error(t, err, "Expected an error but got none")
+				return
+			}
+			assert.NoError(t, err, "Unexpected error occurred")
+			assert.Equal(t, tt.want, parsed)
+		})
+	}
+}
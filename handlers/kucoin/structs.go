@@ -0,0 +1,74 @@
+package kucoin
+
+import "encoding/json"
+
+// BulletResponse is the reply from /api/v1/bullet-public
+type BulletResponse struct {
+	Code string `json:"code"`
+	Msg  string `json:"msg"`
+	Data struct {
+		Token           string           `json:"token"`
+		InstanceServers []InstanceServer `json:"instanceServers"`
+	} `json:"data"`
+}
+
+type InstanceServer struct {
+	Endpoint     string `json:"endpoint"`
+	Encrypt      bool   `json:"encrypt"`
+	Protocol     string `json:"protocol"`
+	PingInterval int64  `json:"pingInterval"`
+	PingTimeout  int64  `json:"pingTimeout"`
+}
+
+// SubscribeMessage is the {"type":"subscribe","topic":...} request
+type SubscribeMessage struct {
+	ID             string `json:"id"`
+	Type           string `json:"type"`
+	Topic          string `json:"topic"`
+	PrivateChannel bool   `json:"privateChannel"`
+	Response       bool   `json:"response"`
+}
+
+// Global Message Struct, covers control frames as well as topic pushes
+type GlobalMessageStruct struct {
+	ID      string          `json:"id"`
+	Type    string          `json:"type"`
+	Topic   string          `json:"topic"`
+	Subject string          `json:"subject"`
+	Code    int             `json:"code"`
+	Data    json.RawMessage `json:"data"`
+}
+
+type MatchData struct {
+	Sequence string `json:"sequence"`
+	Type     string `json:"type"`
+	Symbol   string `json:"symbol"`
+	Side     string `json:"side"`
+	Price    string `json:"price"`
+	Size     string `json:"size"`
+	TradeID  string `json:"tradeId"`
+	Time     string `json:"time"`
+}
+
+type TickerData struct {
+	Sequence    string `json:"sequence"`
+	Price       string `json:"price"`
+	Size        string `json:"size"`
+	BestAsk     string `json:"bestAsk"`
+	BestAskSize string `json:"bestAskSize"`
+	BestBid     string `json:"bestBid"`
+	BestBidSize string `json:"bestBidSize"`
+	Time        int64  `json:"Time"`
+}
+
+// Subscription maps a KuCoin topic prefix and symbol back to the configured stream
+type Subscription struct {
+	Symbol   string
+	DataType string
+}
+
+// SubscriptionKey identifies a topic for one symbol, e.g. {"/market/match", "BTC-USDT"}
+type SubscriptionKey struct {
+	Topic  string
+	Symbol string
+}
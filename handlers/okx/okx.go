@@ -0,0 +1,250 @@
+package okx
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/Antkky/go_crypto_scraper/handlers/exchange"
+	"github.com/Antkky/go_crypto_scraper/utils"
+	"github.com/gorilla/websocket"
+)
+
+func init() {
+	exchange.Register("okx", New)
+}
+
+// channels maps our stream types onto OKX public channels
+var channels = map[string]string{
+	"trade":  "trades",
+	"ticker": "bbo-tbt",
+}
+
+// Adapter implements exchange.Adapter for the OKX v5 public websocket.
+type Adapter struct {
+	exchange.Base
+	subscriptions map[Arg]Subscription
+}
+
+// New builds an OKX adapter for the given config.
+func New(config utils.ExchangeConfig, logger *log.Logger) exchange.Adapter {
+	return &Adapter{
+		Base:          exchange.Base{Config: config, Logger: logger},
+		subscriptions: Subscriptions(config.Streams),
+	}
+}
+
+func (a *Adapter) Parse(message []byte) ([]utils.ParsedMessage, error) {
+	return ProcessMessage(message, a.subscriptions)
+}
+
+// Subscribe sends the configured message for each stream, or builds one from
+// the stream's type, symbol and market when the message is left out.
+func (a *Adapter) Subscribe(conn *websocket.Conn) error {
+	messages := make([]json.RawMessage, 0, len(a.Config.Streams))
+	for _, stream := range a.Config.Streams {
+		if len(stream.Message) > 0 {
+			messages = append(messages, stream.Message)
+			continue
+		}
+		message, err := SubscribeMessageFor(stream)
+		if err != nil {
+			return err
+		}
+		messages = append(messages, message)
+	}
+	return a.SendSubscriptions(conn, messages)
+}
+
+// Heartbeat sends config.Ping when one is configured, and otherwise the plain
+// text "ping" OKX expects; it closes connections idle for 30 seconds.
+func (a *Adapter) Heartbeat(conn *websocket.Conn) error {
+	if len(a.Config.Ping) > 0 {
+		return a.Base.Heartbeat(conn)
+	}
+	return conn.WriteMessage(websocket.TextMessage, []byte("ping"))
+}
+
+// ________Small Helper Functions________
+
+// InstID converts a symbol such as BTCUSDT into an OKX instrument id.
+// The "swap", "futures" and "perpetual" markets map to the -SWAP contract.
+func InstID(symbol string, market string) string {
+	instID := symbol
+	if !strings.Contains(symbol, "-") {
+		if base, quote, ok := utils.SplitSymbol(symbol); ok {
+			instID = base + "-" + quote
+		}
+	}
+
+	switch strings.ToLower(market) {
+	case "swap", "futures", "perpetual":
+		if !strings.HasSuffix(instID, "-SWAP") {
+			instID += "-SWAP"
+		}
+	}
+	return instID
+}
+
+// SubscribeMessageFor builds the subscribe request for a stream without an explicit message
+func SubscribeMessageFor(stream utils.StreamConfig) (json.RawMessage, error) {
+	channel, exists := channels[stream.Type]
+	if !exists {
+		return nil, fmt.Errorf("okx: unsupported stream type %q", stream.Type)
+	}
+	return json.Marshal(SubscribeMessage{
+		Op:   "subscribe",
+		Args: []Arg{{Channel: channel, InstID: InstID(stream.Symbol, stream.Market)}},
+	})
+}
+
+// Subscriptions maps every configured channel/instId pair to the stream's symbol and type
+func Subscriptions(streams []utils.StreamConfig) map[Arg]Subscription {
+	subscriptions := make(map[Arg]Subscription)
+	for _, stream := range streams {
+		subscription := Subscription{Symbol: stream.Symbol, DataType: stream.Type}
+
+		var sub SubscribeMessage
+		if len(stream.Message) > 0 && json.Unmarshal(stream.Message, &sub) == nil && len(sub.Args) > 0 {
+			for _, arg := range sub.Args {
+				subscriptions[arg] = subscription
+			}
+			continue
+		}
+		if channel, exists := channels[stream.Type]; exists {
+			subscriptions[Arg{Channel: channel, InstID: InstID(stream.Symbol, stream.Market)}] = subscription
+		}
+	}
+	return subscriptions
+}
+
+func parseTimestamp(ts string) (uint64, error) {
+	timestamp, err := strconv.ParseUint(ts, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timestamp %q: %w", ts, err)
+	}
+	return timestamp, nil
+}
+
+// lookup finds the stream an arg belongs to, falling back to the instId with dashes removed
+func lookup(arg Arg, subscriptions map[Arg]Subscription) Subscription {
+	if subscription, exists := subscriptions[arg]; exists {
+		return subscription
+	}
+	symbol := strings.ReplaceAll(strings.TrimSuffix(arg.InstID, "-SWAP"), "-", "")
+	switch arg.Channel {
+	case "trades":
+		return Subscription{Symbol: symbol, DataType: "trade"}
+	default:
+		return Subscription{Symbol: symbol, DataType: "ticker"}
+	}
+}
+
+// ________Main Functions________
+
+// ProcessMessage()
+//
+// Inputs:
+//
+//	message       : []byte
+//	subscriptions : map[Arg]Subscription
+//
+// Outputs:
+//
+//	[]utils.ParsedMessage
+//	error
+//
+// Description:
+//
+//	routes trades and bbo-tbt pushes into the shared structs, and recognises
+//	the "pong" text frame and subscribe/error events
+func ProcessMessage(message []byte, subscriptions map[Arg]Subscription) ([]utils.ParsedMessage, error) {
+	if bytes.Equal(bytes.TrimSpace(message), []byte("pong")) {
+		return []utils.ParsedMessage{{Event: "pong"}}, nil
+	}
+
+	var pMessage GlobalMessageStruct
+	if err := json.Unmarshal(message, &pMessage); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal okx message: %w", err)
+	}
+
+	switch pMessage.Event {
+	case "":
+	case "subscribe":
+		return []utils.ParsedMessage{{Event: "subscribed"}}, nil
+	case "error":
+		return nil, fmt.Errorf("okx error %s: %s", pMessage.Code, pMessage.Msg)
+	default:
+		return nil, nil
+	}
+
+	subscription := lookup(pMessage.Arg, subscriptions)
+
+	switch pMessage.Arg.Channel {
+	case "trades":
+		var tradeMsg []TradeData
+		if err := json.Unmarshal(pMessage.Data, &tradeMsg); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal trade data: %w", err)
+		}
+
+		trades := make([]utils.TradeDataStruct, 0, len(tradeMsg))
+		for _, trade := range tradeMsg {
+			timestamp, err := parseTimestamp(trade.TS)
+			if err != nil {
+				return nil, err
+			}
+			trades = append(trades, utils.TradeDataStruct{
+				TimeStamp: timestamp,
+				Symbol:    subscription.Symbol,
+				Price:     trade.Price,
+				Quantity:  trade.Size,
+				Bid_MM:    trade.Side == "sell",
+				TradeID:   trade.TradeID,
+			})
+		}
+		return []utils.ParsedMessage{{
+			DataType: subscription.DataType,
+			Symbol:   subscription.Symbol,
+			Data:     trades,
+		}}, nil
+
+	case "bbo-tbt":
+		var bboMsg []BBOData
+		if err := json.Unmarshal(pMessage.Data, &bboMsg); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal bbo data: %w", err)
+		}
+
+		tickers := make([]utils.TickerDataStruct, 0, len(bboMsg))
+		for _, bbo := range bboMsg {
+			if len(bbo.Bids) == 0 || len(bbo.Asks) == 0 || len(bbo.Bids[0]) < 2 || len(bbo.Asks[0]) < 2 {
+				continue
+			}
+			timestamp, err := parseTimestamp(bbo.TS)
+			if err != nil {
+				return nil, err
+			}
+			tickers = append(tickers, utils.TickerDataStruct{
+				TimeStamp: timestamp,
+				Symbol:    subscription.Symbol,
+				BidPrice:  bbo.Bids[0][0],
+				BidSize:   bbo.Bids[0][1],
+				AskPrice:  bbo.Asks[0][0],
+				AskSize:   bbo.Asks[0][1],
+			})
+		}
+		if len(tickers) == 0 {
+			return nil, nil
+		}
+		return []utils.ParsedMessage{{
+			DataType: subscription.DataType,
+			Symbol:   subscription.Symbol,
+			Data:     tickers,
+		}}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown message type: %s", pMessage.Arg.Channel)
+	}
+}
@@ -0,0 +1,108 @@
+package okx
+
+import (
+	"github.com/Antkky/go_crypto_scraper/utils"
+)
+
+// Streams the test subscriptions are built from, one spot and one swap instrument
+var testStreams = []utils.StreamConfig{
+	{Type: "trade", Symbol: "BTCUSDT", Market: "spot"},
+	{Type: "ticker", Symbol: "BTCUSDT", Market: "spot"},
+	{Type: "trade", Symbol: "ETHUSDT", Market: "swap"},
+	{Type: "ticker", Symbol: "ETHUSDT", Market: "swap", Message: []byte(`{"op":"subscribe","args":[{"channel":"bbo-tbt","instId":"ETH-USDT-SWAP"}]}`)},
+}
+
+// Test Cases for InstID
+var InstIDCases = []struct {
+	symbol string
+	market string
+	want   string
+}{
+	{symbol: "BTCUSDT", market: "spot", want: "BTC-USDT"},
+	{symbol: "BTCUSDT", market: "swap", want: "BTC-USDT-SWAP"},
+	{symbol: "ETHUSDC", market: "futures", want: "ETH-USDC-SWAP"},
+	{symbol: "ETHBTC", market: "spot", want: "ETH-BTC"},
+	{symbol: "SOL-USD", market: "spot", want: "SOL-USD"},
+}
+
+// Test Cases for ProcessMessage
+var ProcessMessageCases = []struct {
+	name      string
+	message   string
+	want      []utils.ParsedMessage
+	wantError bool
+}{
+	{
+		name:    "spot trades",
+		message: `{"arg":{"channel":"trades","instId":"BTC-USDT"},"data":[{"instId":"BTC-USDT","tradeId":"130639474","px":"42219.9","sz":"0.12060306","side":"buy","ts":"1630048897897","count":"3"}]}`,
+		want: []utils.ParsedMessage{{
+			DataType: "trade",
+			Symbol:   "BTCUSDT",
+			Data: []utils.TradeDataStruct{{
+				TimeStamp: 1630048897897,
+				Symbol:    "BTCUSDT",
+				Price:     "42219.9",
+				Quantity:  "0.12060306",
+				Bid_MM:    false,
+				TradeID:   "130639474",
+			}},
+		}},
+	},
+	{
+		name:    "swap trades",
+		message: `{"arg":{"channel":"trades","instId":"ETH-USDT-SWAP"},"data":[{"instId":"ETH-USDT-SWAP","tradeId":"1","px":"2300.1","sz":"12","side":"sell","ts":"1630048897900","count":"1"}]}`,
+		want: []utils.ParsedMessage{{
+			DataType: "trade",
+			Symbol:   "ETHUSDT",
+			Data: []utils.TradeDataStruct{{
+				TimeStamp: 1630048897900,
+				Symbol:    "ETHUSDT",
+				Price:     "2300.1",
+				Quantity:  "12",
+				Bid_MM:    true,
+				TradeID:   "1",
+			}},
+		}},
+	},
+	{
+		name:    "bbo-tbt",
+		message: `{"arg":{"channel":"bbo-tbt","instId":"ETH-USDT-SWAP"},"data":[{"asks":[["2300.2","415","0","13"]],"bids":[["2300.1","256","0","12"]],"ts":"1597026383085","seqId":123}]}`,
+		want: []utils.ParsedMessage{{
+			DataType: "ticker",
+			Symbol:   "ETHUSDT",
+			Data: []utils.TickerDataStruct{{
+				TimeStamp: 1597026383085,
+				Symbol:    "ETHUSDT",
+				BidPrice:  "2300.1",
+				BidSize:   "256",
+				AskPrice:  "2300.2",
+				AskSize:   "415",
+			}},
+		}},
+	},
+	{
+		name:    "pong",
+		message: `pong`,
+		want:    []utils.ParsedMessage{{Event: "pong"}},
+	},
+	{
+		name:    "subscribe event",
+		message: `{"event":"subscribe","arg":{"channel":"trades","instId":"BTC-USDT"},"connId":"a4d3ae55"}`,
+		want:    []utils.ParsedMessage{{Event: "subscribed"}},
+	},
+	{
+		name:      "error event",
+		message:   `{"event":"error","code":"60012","msg":"Invalid request","connId":"a4d3ae55"}`,
+		wantError: true,
+	},
+}
+
+// Test Cases for Heartbeat, want is the text frame the server reads
+var HeartbeatCases = []struct {
+	name string
+	ping map[string]interface{}
+	want string
+}{
+	{name: "plain text ping by default", want: "ping"},
+	{name: "configured ping", ping: map[string]interface{}{"op": "ping"}, want: `{"op":"ping"}`},
+}
@@ -0,0 +1,71 @@
+package okx
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Antkky/go_crypto_scraper/utils"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstID(t *testing.T) {
+	for _, tt := range InstIDCases {
+		t.Run(tt.symbol+"/"+tt.market, func(t *testing.T) {
+			assert.Equal(t, tt.want, InstID(tt.symbol, tt.market))
+		})
+	}
+}
+
+func TestProcessMessage(t *testing.T) {
+	subscriptions := Subscriptions(testStreams)
+
+	for _, tt := range ProcessMessageCases {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := ProcessMessage([]byte(tt.message), subscriptions)
+			if tt.wantError {
+				assert.Error(t, err, "Expected an error but got none")
+				return
+			}
+			assert.NoError(t, err, "Unexpected error occurred")
+			assert.Equal(t, tt.want, parsed)
+		})
+	}
+}
+
+func TestHeartbeat(t *testing.T) {
+	received := make(chan string, 1)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, message, err := conn.ReadMessage()
+		if err == nil {
+			received <- string(message)
+		}
+	}))
+	defer server.Close()
+
+	for _, tt := range HeartbeatCases {
+		t.Run(tt.name, func(t *testing.T) {
+			config := utils.ExchangeConfig{Name: "OKX Test", Ping: tt.ping}
+			adapter := New(config, log.New(io.Discard, "", 0))
+
+			conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer conn.Close()
+
+			assert.NoError(t, adapter.Heartbeat(conn))
+			assert.Equal(t, tt.want, <-received)
+		})
+	}
+}
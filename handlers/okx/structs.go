@@ -0,0 +1,49 @@
+package okx
+
+import "encoding/json"
+
+// Arg identifies a channel subscription, e.g. {"channel":"trades","instId":"BTC-USDT"}
+type Arg struct {
+	Channel string `json:"channel"`
+	InstID  string `json:"instId"`
+}
+
+// SubscribeMessage is the {"op":"subscribe","args":[...]} request
+type SubscribeMessage struct {
+	Op   string `json:"op"`
+	Args []Arg  `json:"args"`
+}
+
+// Global Message Struct, covers data pushes as well as event replies
+type GlobalMessageStruct struct {
+	Event  string          `json:"event"`
+	Arg    Arg             `json:"arg"`
+	Data   json.RawMessage `json:"data"`
+	Code   string          `json:"code"`
+	Msg    string          `json:"msg"`
+	ConnID string          `json:"connId"`
+}
+
+type TradeData struct {
+	InstID  string `json:"instId"`
+	TradeID string `json:"tradeId"`
+	Price   string `json:"px"`
+	Size    string `json:"sz"`
+	Side    string `json:"side"`
+	TS      string `json:"ts"`
+	Count   string `json:"count"`
+}
+
+// BBOData levels are [price, size, deprecated, order count]
+type BBOData struct {
+	Asks  [][]string `json:"asks"`
+	Bids  [][]string `json:"bids"`
+	TS    string     `json:"ts"`
+	SeqID int64      `json:"seqId"`
+}
+
+// Subscription maps an OKX channel and instId back to the configured stream
+type Subscription struct {
+	Symbol   string
+	DataType string
+}
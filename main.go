@@ -1,78 +1,90 @@
 package main
 
 import (
+	"flag"
 	"log"
 	"os"
 	"os/signal"
-	"strings"
 	"syscall"
+	"time"
 
-	"github.com/Antkky/go_crypto_scraper/handlers/binance"
-	"github.com/Antkky/go_crypto_scraper/handlers/coinex"
+	_ "github.com/Antkky/go_crypto_scraper/handlers"
+	"github.com/Antkky/go_crypto_scraper/handlers/exchange"
 	"github.com/Antkky/go_crypto_scraper/utils"
-	"github.com/gorilla/websocket"
+	_ "github.com/Antkky/go_crypto_scraper/utils/buffer/parquet"
 )
 
 var logger = log.New(os.Stdout, "[CryptoScraper] ", log.LstdFlags|log.Lshortfile)
 
-// establishConnections creates WebSocket connections to all the exchanges.
-func establishConnections(configs []utils.ExchangeConfig) ([]*websocket.Conn, error) {
-	var connections []*websocket.Conn
+// shutdownTimeout bounds how long GracefulShutdown waits for the buffers to be written
+var shutdownTimeout = flag.Duration("shutdown-timeout", 15*time.Second, "how long to wait for buffered data to be written on shutdown")
+
+// establishConnections starts a supervisor per exchange. Each supervisor dials,
+// subscribes and keeps redialing in the background if the connection drops.
+func establishConnections(configs []utils.ExchangeConfig) ([]*exchange.Supervisor, error) {
+	var supervisors []*exchange.Supervisor
 
 	for _, config := range configs {
-		conn, _, err := websocket.DefaultDialer.Dial(config.URI, nil)
-		if err != nil || conn == nil {
-			logger.Printf("❌ Error connecting to exchange %s: %s", config.Name, err)
+		adapter, err := exchange.New(config, logger)
+		if err != nil {
+			logger.Printf("⚠️ Unhandled exchange: %s", err)
 			continue
-		} else {
-			logger.Printf("✅ Connection established for %s", config.Name)
-			connections = append(connections, conn)
-			go handleExchangeConnection(config, conn)
 		}
-	}
 
-	return connections, nil
-}
-
-// handleExchangeConnection routes connection handling based on the exchange.
-func handleExchangeConnection(config utils.ExchangeConfig, conn *websocket.Conn) {
-	switch {
-	case strings.Contains(config.Name, "Binance"):
-		binance.HandleConnection(conn, config, logger)
-	case strings.Contains(config.Name, "Coinex"):
-		coinex.HandleConnection(conn, config, logger)
-	case strings.Contains(config.Name, "Bybit"):
-		//bybit.HandleConnection(conn, config)
-	case strings.Contains(config.Name, "Bitfinex"):
-		//bitfinex.HandleConnection(conn, config)
-	default:
-		logger.Printf("⚠️ Unhandled exchange: %s", config.Name)
+		supervisor := exchange.NewSupervisor(adapter, config, logger)
+		supervisors = append(supervisors, supervisor)
+		go supervisor.Run()
 	}
+
+	return supervisors, nil
 }
 
-// gracefulShutdown waits for a termination signal and closes all connections.
-func GracefulShutdown(connections []*websocket.Conn, configs []utils.ExchangeConfig, logger *log.Logger) {
+// GracefulShutdown()
+//
+// Inputs:
+//
+//	supervisors : []*exchange.Supervisor
+//	timeout     : time.Duration
+//	logger      : *log.Logger
+//
+// Outputs:
+//
+//	No Outputs
+//
+// Description:
+//
+//	Waits for a termination signal, then stops every supervisor so no more frames are read.
+//	Each one drains its queue and flushes and syncs its buffers; this waits for all of them
+//	up to timeout and names any exchange that did not finish in time. A second signal exits at once.
+func GracefulShutdown(supervisors []*exchange.Supervisor, timeout time.Duration, logger *log.Logger) {
 	// Wait for interrupt signal to gracefully shutdown the application.
 	signalChan := make(chan os.Signal, 1)
 	signal.Notify(signalChan, os.Interrupt, syscall.SIGTERM)
 
 	<-signalChan
-	logger.Println("⏳ Shutting down...")
-
-	for i, conn := range connections {
-		if conn != nil {
-			switch {
-			case strings.Contains(configs[i].Name, "Binance"):
-				binance.CloseConnection(conn, configs[i].Name, logger)
-			case strings.Contains(configs[i].Name, "Coinex"):
-				coinex.CloseConnection(conn, configs[i].Name, logger)
-			case strings.Contains(configs[i].Name, "Bybit"):
-				//bybit.HandleConnection(conn, config)
-			case strings.Contains(configs[i].Name, "Bitfinex"):
-				//bitfinex.HandleConnection(conn, config)
-			default:
-				logger.Printf("⚠️ Unhandled exchange: %s", configs[i].Name)
+	logger.Printf("⏳ Shutting down, flushing buffers (deadline %s)...", timeout)
+
+	for _, supervisor := range supervisors {
+		supervisor.Stop()
+	}
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for _, supervisor := range supervisors {
+		select {
+		case <-supervisor.Done():
+		case <-signalChan:
+			logger.Println("⚠️ Second signal received, exiting without waiting for buffers")
+			return
+		case <-deadline.C:
+			for _, pending := range supervisors {
+				select {
+				case <-pending.Done():
+				default:
+					logger.Printf("❌ %s did not finish writing its buffers before the deadline", pending.Config.Name)
+				}
 			}
+			return
 		}
 	}
 
@@ -80,6 +92,8 @@ func GracefulShutdown(connections []*websocket.Conn, configs []utils.ExchangeCon
 }
 
 func main() {
+	flag.Parse()
+
 	// Read and parse configuration
 	configs, err := utils.ReadConfig("config/streams2.json")
 	if err != nil {
@@ -87,11 +101,11 @@ func main() {
 	}
 
 	// Establish WebSocket connections
-	connections, err := establishConnections(configs)
+	supervisors, err := establishConnections(configs)
 	if err != nil {
 		logger.Fatalf("Error establishing connections: %s", err)
 	}
 
 	// Graceful shutdown handling
-	GracefulShutdown(connections, configs, logger)
+	GracefulShutdown(supervisors, *shutdownTimeout, logger)
 }
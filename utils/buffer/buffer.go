@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"time"
 
 	"github.com/Antkky/go_crypto_scraper/utils"
 )
@@ -35,158 +37,206 @@ func isFileEmpty(filepath string) (bool, error) {
 
 // getCSVHeader returns the correct CSV header based on the data type
 func getCSVHeader(dataType string) ([]string, error) {
-	switch dataType {
-	case "trade":
-		return []string{"TimeStamp", "Date", "Symbol", "Price", "Quantity", "Bid_MM"}, nil
-	case "ticker":
-		return []string{"TimeStamp", "Date", "Symbol", "BidPrice", "BidSize", "AskPrice", "AskSize"}, nil
-	default:
+	record, known := recordTypes[dataType]
+	if !known {
 		return nil, fmt.Errorf("unsupported data type for header: %s", dataType)
 	}
+	return record.header, nil
 }
 
-// writeDataToCSV writes a batch of data to the CSV file
+// writeDataToCSV writes a batch of data to the CSV file. A record that cannot be
+// formatted is skipped and the rest are still written; the returned SkippedError
+// says how many were skipped.
 func writeDataToCSV(writer *csv.Writer, buffer interface{}) error {
-	switch batch := buffer.(type) {
-	case []utils.TickerDataStruct:
-		for _, record := range batch {
-			fData, err := FormatData(record)
-			if err != nil {
-				return errors.New("error formatting data")
-			}
-			if err = writer.Write(fData); err != nil {
-				return fmt.Errorf("error writing ticker record: %w", err)
+	batch := reflect.ValueOf(buffer)
+	if batch.Kind() != reflect.Slice {
+		return fmt.Errorf("unsupported buffer type")
+	}
+	record, known := recordStructs[batch.Type().Elem()]
+	if !known {
+		return fmt.Errorf("unsupported buffer type")
+	}
+
+	skipped := &SkippedError{Total: batch.Len()}
+	for i := 0; i < batch.Len(); i++ {
+		fData, err := record.format(batch.Index(i).Interface())
+		if err != nil {
+			skipped.Skipped++
+			if skipped.Err == nil {
+				skipped.Err = fmt.Errorf("error formatting data: %s", err)
 			}
+			continue
 		}
-	case []utils.TradeDataStruct:
-		for _, record := range batch {
-			fData, err := FormatData(record)
-			if err != nil {
-				return fmt.Errorf("error formatting data: %s", err)
-			}
-			if err = writer.Write(fData); err != nil {
-				return fmt.Errorf("error writing trade record: %w", err)
-			}
+		if err = writer.Write(fData); err != nil {
+			return fmt.Errorf("error writing %s record: %w", record.dataType, err)
 		}
-	default:
-		return fmt.Errorf("unsupported buffer type")
+	}
+	if skipped.Skipped > 0 {
+		return skipped
 	}
 	return nil
 }
 
 // FormatData formats the data for writing to CSV
 func FormatData(record interface{}) ([]string, error) {
-	switch v := record.(type) {
-	case utils.TickerDataStruct:
-		if v.BidPrice == "" || v.AskPrice == "" || v.BidSize == "" || v.AskSize == "" {
-			return nil, fmt.Errorf("missing required field(s) in TickerDataStruct")
-		}
-
-		return []string{
-			fmt.Sprintf("%d", v.TimeStamp),
-			fmt.Sprintf("%d", v.Date),
-			v.Symbol,
-			v.BidPrice,
-			v.BidSize,
-			v.AskPrice,
-			v.AskSize,
-		}, nil
-
-	case utils.TradeDataStruct:
-		// Check for empty fields that should contain data
-		if v.Price == "" || v.Quantity == "" {
-			return nil, nil
-		}
-
-		// Convert timestamp and date to string
-		return []string{
-			fmt.Sprintf("%d", v.TimeStamp), // TimeStamp as integer
-			fmt.Sprintf("%d", v.Date),      // Date as integer
-			v.Symbol,                       // Symbol
-			v.Price,                        // Price as float with 2 decimals
-			v.Quantity,                     // Quantity as integer
-			fmt.Sprintf("%t", v.Bid_MM),    // Bid_MM as string ("true" or "false")
-		}, nil
-
-	default:
+	recordType, known := recordStructs[reflect.TypeOf(record)]
+	if !known {
 		return nil, fmt.Errorf("unsupported record type: %T", record)
 	}
+	return recordType.format(record)
 }
 
-// Methods to add data to the buffer
+// Methods to add data to the buffer. The first record added to an empty
+// buffer starts its age for FlushExpired.
 func (c *DataBuffer) AddData(records interface{}) error {
-	switch data := records.(type) {
-	case []utils.TickerDataStruct:
-		c.TickerBuffer = append(c.TickerBuffer, data...)
-		if len(c.TickerBuffer) >= c.MaxSize {
-			if err := c.FlushData(); err != nil {
-				return fmt.Errorf("failed to flush ticker data: %w", err)
+	wasEmpty := c.Len() == 0
+	err := c.addData(records)
+	if wasEmpty && c.Len() > 0 {
+		c.oldest = time.Now()
+	}
+	return err
+}
+
+// addData appends a record, or a slice of records, of the buffer's data type
+func (c *DataBuffer) addData(records interface{}) error {
+	record, known := recordTypes[c.DataType]
+	if !known {
+		return fmt.Errorf("unsupported data type: %T", records)
+	}
+	data := reflect.ValueOf(records)
+	switch reflect.TypeOf(records) {
+	case record.batchType:
+	case record.batchType.Elem():
+		data = reflect.Append(reflect.MakeSlice(record.batchType, 0, 1), data)
+	default:
+		return fmt.Errorf("unsupported data type: %T", records)
+	}
+
+	if klines, ok := data.Interface().([]utils.KlineDataStruct); ok && !c.IncludePartial {
+		closed := make([]utils.KlineDataStruct, 0, len(klines))
+		for _, kline := range klines {
+			if kline.Closed {
+				closed = append(closed, kline)
 			}
 		}
-	case []utils.TradeDataStruct:
-		c.TradeBuffer = append(c.TradeBuffer, data...)
-		if len(c.TradeBuffer) >= c.MaxSize {
-			if err := c.FlushData(); err != nil {
-				return fmt.Errorf("failed to flush trade data: %w", err)
-			}
+		data = reflect.ValueOf(closed)
+	}
+
+	c.records = reflect.AppendSlice(reflect.ValueOf(c.records), data).Interface()
+	if c.Len() >= c.MaxSize {
+		if err := c.FlushData(); err != nil {
+			return fmt.Errorf("failed to flush %s data: %w", c.DataType, err)
 		}
-	default:
-		return fmt.Errorf("unsupported data type: %T", records)
 	}
 	return nil
 }
 
+// FlushData()
+//
+// Inputs:
+//
+//	No Inputs
+//
+// Outputs:
+//
+//	error
+//
+// Description:
+//
+//	Writes the buffered records through the buffer's sink and empties the buffer.
+//	The sink is opened on the first flush, so streams that never produce data leave no file.
+//	Records the sink skips, e.g. ones missing a price, are counted and the rest of the
+//	batch is still written and flushed; the error reports how many were skipped. When the
+//	sink fails to write the batch at all, the buffer is still emptied so the failure cannot
+//	block every later flush, and the error says how many records were dropped.
 func (c *DataBuffer) FlushData() error {
-	filepath := fmt.Sprintf("%s/%s", c.FilePath, c.FileName)
-	if err := validateFilePath(filepath); err != nil {
-		return fmt.Errorf("invalid file path: %w", err)
+	if !c.opened {
+		if err := c.Sink.Open(); err != nil {
+			return fmt.Errorf("error opening sink: %w", err)
+		}
+		c.opened = true
 	}
-	file, err := os.OpenFile(filepath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		return fmt.Errorf("error opening file: %w", err)
+
+	batch := c.records
+	if batch == nil {
+		return fmt.Errorf("unsupported data type: %s", c.DataType)
 	}
-	defer file.Close()
-	isEmpty, err := isFileEmpty(filepath)
-	if err != nil {
-		return fmt.Errorf("error checking file empty status: %w", err)
+
+	pending := c.Len()
+	writeErr := c.Sink.WriteBatch(batch)
+	// A failed batch is not kept for the next flush: the sink may have written
+	// part of it, and a bad record would fail every retry while the buffer grows.
+	c.clear()
+	var skipped *SkippedError
+	if writeErr != nil && !errors.As(writeErr, &skipped) {
+		return fmt.Errorf("dropped %d %s records: %w", pending, c.DataType, writeErr)
 	}
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
-	if isEmpty {
-		header, err := getCSVHeader(c.DataType)
-		if err != nil {
-			return fmt.Errorf("error getting CSV header: %w", err)
-		}
-		if err := writer.Write(header); err != nil {
-			return fmt.Errorf("error writing CSV header: %w", err)
-		}
+	if err := c.Sink.Flush(); err != nil {
+		return fmt.Errorf("error flushing sink: %w", err)
 	}
-	if c.DataType == "ticker" {
-		if err := writeDataToCSV(writer, c.TickerBuffer); err != nil {
-			return fmt.Errorf("error writing ticker records to CSV: %w", err)
-		}
-		c.TickerBuffer = nil
-	} else if c.DataType == "trade" {
-		if err := writeDataToCSV(writer, c.TradeBuffer); err != nil {
-			return fmt.Errorf("error writing trade records to CSV: %w", err)
-		}
-		c.TradeBuffer = nil
-	} else {
-		return fmt.Errorf("unsupported data type: %s", c.DataType)
+	if skipped != nil {
+		return fmt.Errorf("error writing %s records: %w", c.DataType, writeErr)
 	}
 	return nil
 }
 
-// Create a new buffer
+// clear empties the buffer, keeping the type of its batch
+func (c *DataBuffer) clear() {
+	if c.records != nil {
+		c.records = reflect.Zero(reflect.TypeOf(c.records)).Interface()
+	}
+	c.oldest = time.Time{}
+}
+
+// FlushExpired flushes the buffer when MaxAge is set and its oldest record has
+// been waiting at least that long at now, so quiet streams still reach disk.
+func (c *DataBuffer) FlushExpired(now time.Time) error {
+	if c.MaxAge <= 0 || c.Len() == 0 || now.Sub(c.oldest) < c.MaxAge {
+		return nil
+	}
+	return c.FlushData()
+}
+
+// Len returns the number of records waiting to be flushed
+func (c *DataBuffer) Len() int {
+	if c.records == nil {
+		return 0
+	}
+	return reflect.ValueOf(c.records).Len()
+}
+
+// Close flushes whatever is still buffered and closes the sink. The sink is
+// closed even when the last flush fails; the flush error is returned first.
+func (c *DataBuffer) Close() error {
+	var flushErr error
+	if c.Len() > 0 {
+		flushErr = c.FlushData()
+	}
+	if !c.opened {
+		return flushErr
+	}
+	c.opened = false
+	if err := c.Sink.Close(); err != nil && flushErr == nil {
+		return fmt.Errorf("error closing sink: %w", err)
+	}
+	return flushErr
+}
+
+// Create a new buffer. It writes CSV to filePath/fileName; replace Sink
+// before the first flush to store the records elsewhere.
 func NewDataBuffer(dataType string, market string, id string, maxSize int, fileName string, filePath string) *DataBuffer {
+	var records interface{}
+	if record, known := recordTypes[dataType]; known {
+		records = reflect.MakeSlice(record.batchType, 0, 0).Interface()
+	}
 	return &DataBuffer{
-		TickerBuffer: make([]utils.TickerDataStruct, 0),
-		TradeBuffer:  make([]utils.TradeDataStruct, 0),
-		DataType:     dataType,
-		Market:       market,
-		ID:           id,
-		MaxSize:      maxSize,
-		FileName:     fileName,
-		FilePath:     filePath,
+		records:  records,
+		DataType: dataType,
+		Market:   market,
+		ID:       id,
+		MaxSize:  maxSize,
+		FileName: fileName,
+		FilePath: filePath,
+		Sink:     NewCSVSink(dataType, fmt.Sprintf("%s/%s", filePath, fileName)),
 	}
 }
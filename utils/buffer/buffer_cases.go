@@ -21,6 +21,7 @@ var AddDataTestCases = []struct {
 			Price:     "97,242.02",
 			Quantity:  "12",
 			Bid_MM:    false,
+			TradeID:   "28457",
 		}},
 		errorValue: "",
 		wantError:  false,
@@ -111,7 +112,7 @@ var FlushDataTestCases = []struct {
 			Price:  "97,242.02",
 			Bid_MM: false,
 		},
-		errorValue: "",
+		errorValue: "error writing trade records: skipped 1 of 1 records: error formatting data: missing required field(s) in TradeDataStruct",
 		wantError:  true,
 	},
 	{
@@ -125,7 +126,240 @@ var FlushDataTestCases = []struct {
 			Quantity:  "",
 			Bid_MM:    false,
 		},
-		errorValue: "",
+		errorValue: "error writing trade records: skipped 1 of 1 records: error formatting data: missing required field(s) in TradeDataStruct",
 		wantError:  true,
 	},
+	{
+		name:     "Valid Depth Data 1",
+		dataType: "depth",
+		data: utils.DepthDataStruct{
+			TimeStamp: 1231231,
+			Date:      0,
+			Symbol:    "BTCUSD",
+			Kind:      "update",
+			UpdateID:  160,
+			Side:      "bid",
+			Price:     "0.0024",
+			Size:      "10",
+		},
+		errorValue: "",
+		wantError:  false,
+	},
+	{
+		name:     "Valid Kline Data 1",
+		dataType: "kline",
+		data: utils.KlineDataStruct{
+			TimeStamp: 1231231,
+			Symbol:    "BTCUSD",
+			Interval:  "1m",
+			OpenTime:  1231200,
+			CloseTime: 1291199,
+			Open:      "97,242.02",
+			High:      "97,250.00",
+			Low:       "97,240.00",
+			Close:     "97,245.10",
+			Volume:    "12",
+			Closed:    true,
+		},
+		errorValue: "",
+		wantError:  false,
+	},
+	{
+		name:     "Valid Funding Data 1",
+		dataType: "funding",
+		data: utils.FundingDataStruct{
+			TimeStamp:       1562305380000,
+			Symbol:          "BTCUSDT",
+			MarkPrice:       "11794.15000000",
+			IndexPrice:      "11784.62659091",
+			FundingRate:     "0.00038167",
+			NextFundingTime: 1562306400000,
+		},
+		errorValue: "",
+		wantError:  false,
+	},
+	{
+		name:     "Valid Liquidation Data 1",
+		dataType: "liquidation",
+		data: utils.LiquidationDataStruct{
+			TimeStamp: 1568014460893,
+			Symbol:    "BTCUSDT",
+			Side:      "SELL",
+			Price:     "9910",
+			AvgPrice:  "9910",
+			Quantity:  "0.014",
+			Filled:    "0.014",
+			Status:    "FILLED",
+		},
+		errorValue: "",
+		wantError:  false,
+	},
+	{
+		name:     "Valid Stats24h Data 1",
+		dataType: "stats24h",
+		data: utils.Stats24hDataStruct{
+			TimeStamp:   1672515782136,
+			Symbol:      "BNBBTC",
+			OpenTime:    0,
+			CloseTime:   86400000,
+			OpenPrice:   "0.0010",
+			HighPrice:   "0.0025",
+			LowPrice:    "0.0010",
+			ClosePrice:  "0.0025",
+			BaseVolume:  "10000",
+			QuoteVolume: "18",
+		},
+		errorValue: "",
+		wantError:  false,
+	},
+	{
+		name:     "Valid AggTrade Data 1",
+		dataType: "aggTrade",
+		data: utils.AggTradeDataStruct{
+			TimeStamp:    123456789,
+			Symbol:       "BNBBTC",
+			AggTradeID:   12345,
+			Price:        "0.001",
+			Quantity:     "100",
+			FirstTradeID: 100,
+			LastTradeID:  105,
+			TradeTime:    123456785,
+			IsBuyerMaker: true,
+		},
+		errorValue: "",
+		wantError:  false,
+	},
+	{
+		name:     "Valid Gap Data 1",
+		dataType: "gap",
+		data: utils.GapDataStruct{
+			Symbol:       "BTCUSDT",
+			FirstMissing: 101,
+			LastMissing:  104,
+			WallTime:     123456789,
+		},
+		errorValue: "",
+		wantError:  false,
+	},
+}
+
+// 2024-03-05 13:59:59.999 and 14:00:00.000 UTC in ms
+const (
+	lastMsOf13h  = 1709647199999
+	firstMsOf14h = 1709647200000
+)
+
+// Test Cases for RotatingSink, each batch is written and flushed in order
+var RotationCases = []struct {
+	name        string
+	policy      string
+	maxFileSize int64
+	batches     [][]utils.TradeDataStruct
+	// wantFiles maps each file under the sink's directory to its number of records
+	wantFiles map[string]int
+	wantError bool
+}{
+	{
+		name:   "hourly by event time",
+		policy: "hourly",
+		batches: [][]utils.TradeDataStruct{
+			{{TimeStamp: lastMsOf13h, Price: "1", Quantity: "1"}, {TimeStamp: firstMsOf14h, Price: "2", Quantity: "1"}},
+		},
+		wantFiles: map[string]int{
+			"date=2024-03-05/hour=13/Test_trade.csv": 1,
+			"date=2024-03-05/hour=14/Test_trade.csv": 1,
+		},
+	},
+	{
+		name:   "late record lands in its own hour",
+		policy: "hourly",
+		batches: [][]utils.TradeDataStruct{
+			{{TimeStamp: firstMsOf14h, Price: "2", Quantity: "1"}},
+			{{TimeStamp: lastMsOf13h, Price: "1", Quantity: "1"}},
+			{{TimeStamp: firstMsOf14h + 1, Price: "3", Quantity: "1"}},
+		},
+		wantFiles: map[string]int{
+			"date=2024-03-05/hour=13/Test_trade.csv": 1,
+			"date=2024-03-05/hour=14/Test_trade.csv": 2,
+		},
+	},
+	{
+		name:   "daily",
+		policy: "DAILY",
+		batches: [][]utils.TradeDataStruct{
+			{{TimeStamp: lastMsOf13h, Price: "1", Quantity: "1"}, {TimeStamp: firstMsOf14h, Price: "2", Quantity: "1"}},
+		},
+		wantFiles: map[string]int{
+			"date=2024-03-05/Test_trade.csv": 2,
+		},
+	},
+	{
+		name:        "size starts a new part",
+		policy:      "size",
+		maxFileSize: 60,
+		batches: [][]utils.TradeDataStruct{
+			{{TimeStamp: lastMsOf13h, Price: "1", Quantity: "1"}},
+			{{TimeStamp: lastMsOf13h, Price: "2", Quantity: "1"}},
+		},
+		wantFiles: map[string]int{
+			"Test_trade.csv":   1,
+			"Test_trade.1.csv": 1,
+		},
+	},
+	{
+		name:      "unknown policy",
+		policy:    "weekly",
+		wantError: true,
+	},
+}
+
+// Test Cases for flushing batches with records the CSV writer cannot format
+var SkippedRecordsCases = []struct {
+	name string
+	// policy rotates the buffer's sink when set
+	policy string
+	batch  []utils.TradeDataStruct
+	// wantSkipped is the number of records the flush reports as skipped
+	wantSkipped int
+	// wantRows is the number of records written across every file
+	wantRows int
+}{
+	{
+		name: "every record valid",
+		batch: []utils.TradeDataStruct{
+			{TimeStamp: lastMsOf13h, Symbol: "BTCUSD", Price: "1", Quantity: "1"},
+			{TimeStamp: lastMsOf13h, Symbol: "BTCUSD", Price: "2", Quantity: "1"},
+		},
+		wantRows: 2,
+	},
+	{
+		name: "bad record between good ones",
+		batch: []utils.TradeDataStruct{
+			{TimeStamp: lastMsOf13h, Symbol: "BTCUSD", Price: "1", Quantity: "1"},
+			{TimeStamp: lastMsOf13h, Symbol: "BTCUSD", Price: "", Quantity: "1"},
+			{TimeStamp: lastMsOf13h, Symbol: "BTCUSD", Price: "3", Quantity: "1"},
+		},
+		wantSkipped: 1,
+		wantRows:    2,
+	},
+	{
+		name: "every record bad",
+		batch: []utils.TradeDataStruct{
+			{TimeStamp: lastMsOf13h, Symbol: "BTCUSD", Price: "1"},
+			{TimeStamp: lastMsOf13h, Symbol: "BTCUSD", Quantity: "1"},
+		},
+		wantSkipped: 2,
+	},
+	{
+		name:   "bad records in two partitions are added up",
+		policy: "hourly",
+		batch: []utils.TradeDataStruct{
+			{TimeStamp: lastMsOf13h, Symbol: "BTCUSD", Price: "1", Quantity: "1"},
+			{TimeStamp: lastMsOf13h, Symbol: "BTCUSD", Price: "", Quantity: "1"},
+			{TimeStamp: firstMsOf14h, Symbol: "BTCUSD", Price: "3", Quantity: "1"},
+			{TimeStamp: firstMsOf14h, Symbol: "BTCUSD", Price: "4", Quantity: ""},
+		},
+		wantSkipped: 2,
+		wantRows:    2,
+	},
 }
@@ -1,8 +1,15 @@
 package buffer
 
 import (
+	"encoding/csv"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/Antkky/go_crypto_scraper/utils"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -19,11 +26,7 @@ func TestBuffer(t *testing.T) {
 		}
 
 		// check the buffer for the added data
-		if tt.dataType == "trade" {
-			assert.Contains(t, buffer.TradeBuffer, tt.data)
-		} else {
-			assert.Contains(t, buffer.TickerBuffer, tt.data)
-		}
+		assert.Contains(t, buffer.records, tt.data)
 
 		err = buffer.FlushData() // flush the data
 
@@ -36,3 +39,292 @@ func TestBuffer(t *testing.T) {
 		}
 	}
 }
+
+func TestKlinePartial(t *testing.T) {
+	partial := utils.KlineDataStruct{Symbol: "BTCUSD", Interval: "1m", Open: "1", High: "2", Low: "1", Close: "2", Volume: "3"}
+	closed := partial
+	closed.Closed = true
+
+	buffer := NewDataBuffer("kline", "spot", "TestKlinePartial", 10, "Test.csv", "../../Data/Tests")
+	assert.NoError(t, buffer.AddData([]utils.KlineDataStruct{partial, closed}))
+	assert.Equal(t, []utils.KlineDataStruct{closed}, buffer.records, "only closed candles by default")
+
+	buffer = NewDataBuffer("kline", "spot", "TestKlinePartial", 10, "Test.csv", "../../Data/Tests")
+	buffer.IncludePartial = true
+	assert.NoError(t, buffer.AddData([]utils.KlineDataStruct{partial, closed}))
+	assert.Equal(t, []utils.KlineDataStruct{partial, closed}, buffer.records)
+}
+
+// TestAddDataType
+//
+// Description:
+// a buffer only takes records, or slices of records, of its own data type
+func TestAddDataType(t *testing.T) {
+	buffer := NewDataBuffer("trade", "spot", "TestAddDataType", 10, "Test.csv", "../../Data/Tests")
+	assert.Error(t, buffer.AddData(utils.TickerDataStruct{BidPrice: "1", BidSize: "1", AskPrice: "2", AskSize: "1"}))
+	assert.Error(t, buffer.AddData(nil))
+	assert.NoError(t, buffer.AddData([]utils.TradeDataStruct{{Price: "1", Quantity: "2"}}))
+	assert.NoError(t, buffer.AddData(utils.TradeDataStruct{Price: "3", Quantity: "4"}))
+	assert.Equal(t, 2, buffer.Len())
+
+	unknown := NewDataBuffer("orderbook", "spot", "TestAddDataType", 10, "Test.csv", "../../Data/Tests")
+	assert.Error(t, unknown.AddData(utils.TradeDataStruct{Price: "1", Quantity: "2"}))
+	assert.Equal(t, 0, unknown.Len())
+}
+
+func TestCSVSink(t *testing.T) {
+	path := "../../Data/Tests/TestCSVSink.csv"
+	os.Remove(path)
+	record := utils.TradeDataStruct{TimeStamp: 1, Symbol: "BTCUSD", Price: "2", Quantity: "3", TradeID: "4"}
+
+	// two open/close cycles on the same file keep a single header
+	for i := 0; i < 2; i++ {
+		buffer := NewDataBuffer("trade", "spot", "TestCSVSink", 10, "TestCSVSink.csv", "../../Data/Tests")
+		assert.NoError(t, buffer.AddData(record))
+		assert.NoError(t, buffer.Close())
+		assert.Equal(t, 0, buffer.Len())
+	}
+
+	contents, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "TimeStamp,Date,Symbol,Price,Quantity,Bid_MM,TradeID\n1,0,BTCUSD,2,3,false,4\n1,0,BTCUSD,2,3,false,4\n", string(contents))
+}
+
+func TestCSVSinkHeaderChange(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "Test_trade.csv")
+	// a trade file written before the TradeID column was added
+	old := "TimeStamp,Date,Symbol,Price,Quantity,Bid_MM\n1,0,BTCUSD,2,3,false\n"
+	assert.NoError(t, os.WriteFile(path, []byte(old), 0644))
+	record := utils.TradeDataStruct{TimeStamp: 5, Symbol: "BTCUSD", Price: "6", Quantity: "7", TradeID: "8"}
+
+	// the second cycle finds the versioned file with the current header and appends to it
+	for i := 0; i < 2; i++ {
+		sink := NewCSVSink("trade", path)
+		assert.NoError(t, sink.Open())
+		assert.Equal(t, filepath.Join(dir, "Test_trade.v2.csv"), sink.(*CSVSink).File())
+		assert.NoError(t, sink.WriteBatch([]utils.TradeDataStruct{record}))
+		assert.NoError(t, sink.Close())
+	}
+
+	contents, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, old, string(contents), "the old file must not be touched")
+
+	contents, err = os.ReadFile(filepath.Join(dir, "Test_trade.v2.csv"))
+	assert.NoError(t, err)
+	assert.Equal(t, "TimeStamp,Date,Symbol,Price,Quantity,Bid_MM,TradeID\n5,0,BTCUSD,6,7,false,8\n5,0,BTCUSD,6,7,false,8\n", string(contents))
+}
+
+func TestCloseWithoutData(t *testing.T) {
+	path := "../../Data/Tests/TestCloseWithoutData.csv"
+	os.Remove(path)
+
+	buffer := NewDataBuffer("trade", "spot", "TestCloseWithoutData", 10, "TestCloseWithoutData.csv", "../../Data/Tests")
+	assert.NoError(t, buffer.Close())
+	_, err := os.Stat(path)
+	assert.True(t, os.IsNotExist(err), "an empty buffer should not create its file")
+}
+
+// countRows returns the number of records in the CSV file at path, not counting the header
+func countRows(t *testing.T, path string) int {
+	file, err := os.Open(path)
+	if !assert.NoError(t, err) {
+		return 0
+	}
+	defer file.Close()
+	rows, err := csv.NewReader(file).ReadAll()
+	assert.NoError(t, err)
+	return len(rows) - 1
+}
+
+func TestRotation(t *testing.T) {
+	for _, tt := range RotationCases {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			sink, err := NewRotatingSink(dir, "Test_trade.csv", tt.policy, tt.maxFileSize, func(path string) (Sink, error) {
+				return NewCSVSink("trade", path), nil
+			})
+			if tt.wantError {
+				assert.Error(t, err, "Expected an error but got none")
+				return
+			}
+			assert.NoError(t, err)
+
+			for _, batch := range tt.batches {
+				assert.NoError(t, sink.WriteBatch(batch))
+				assert.NoError(t, sink.Flush())
+			}
+			assert.NoError(t, sink.Close())
+
+			files := make(map[string]int)
+			filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+				if err == nil && !entry.IsDir() {
+					relative, _ := filepath.Rel(dir, path)
+					files[filepath.ToSlash(relative)] = countRows(t, path)
+				}
+				return err
+			})
+			assert.Equal(t, tt.wantFiles, files)
+		})
+	}
+}
+
+// TestRotationVersionedCSV
+//
+// Description:
+// a part whose CSV moved to a versioned file is size checked on that file, and
+// a full one is skipped like any other full part
+func TestRotationVersionedCSV(t *testing.T) {
+	dir := t.TempDir()
+	// an old layout file with room to spare, and a current one that is full
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "Test_trade.csv"), []byte("TimeStamp,Date,Symbol,Price,Quantity,Bid_MM\n"), 0644))
+	full := "TimeStamp,Date,Symbol,Price,Quantity,Bid_MM,TradeID\n" + strings.Repeat("1,0,BTCUSD,2,3,false,4\n", 10)
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "Test_trade.v2.csv"), []byte(full), 0644))
+
+	sink, err := NewRotatingSink(dir, "Test_trade.csv", RotateSize, int64(len(full)), func(path string) (Sink, error) {
+		return NewCSVSink("trade", path), nil
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, sink.WriteBatch([]utils.TradeDataStruct{{TimeStamp: 5, Symbol: "BTCUSD", Price: "6", Quantity: "7", TradeID: "8"}}))
+	assert.NoError(t, sink.Close())
+
+	assert.Equal(t, 1, countRows(t, filepath.Join(dir, "Test_trade.1.csv")))
+	assert.Equal(t, 10, countRows(t, filepath.Join(dir, "Test_trade.v2.csv")))
+}
+
+// failingFlushSink wraps a sink whose Flush fails when fail is set, counting every call
+type failingFlushSink struct {
+	Sink
+	fail    bool
+	flushes *int
+}
+
+func (f *failingFlushSink) Flush() error {
+	*f.flushes++
+	if f.fail {
+		return os.ErrClosed
+	}
+	return f.Sink.Flush()
+}
+
+// TestRotationFlushError
+//
+// Description:
+// a partition that fails to flush does not keep the others from being flushed,
+// and the error is still returned
+func TestRotationFlushError(t *testing.T) {
+	dir := t.TempDir()
+	flushes := 0
+	sink, err := NewRotatingSink(dir, "Test_trade.csv", RotateHourly, 0, func(path string) (Sink, error) {
+		return &failingFlushSink{Sink: NewCSVSink("trade", path), fail: strings.Contains(path, "hour=13"), flushes: &flushes}, nil
+	})
+	assert.NoError(t, err)
+
+	assert.NoError(t, sink.WriteBatch([]utils.TradeDataStruct{
+		{TimeStamp: lastMsOf13h - 1, Price: "1", Quantity: "1"},
+		{TimeStamp: lastMsOf13h, Price: "2", Quantity: "1"},
+		{TimeStamp: firstMsOf14h, Price: "3", Quantity: "1"},
+	}))
+	assert.ErrorIs(t, sink.Flush(), os.ErrClosed)
+	assert.Equal(t, 2, flushes, "every partition is flushed")
+	assert.NoError(t, sink.Close())
+}
+
+func TestSkippedRecords(t *testing.T) {
+	for _, tt := range SkippedRecordsCases {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			buffer := NewDataBuffer("trade", "spot", "TestSkippedRecords", 10, "Test_trade.csv", dir)
+			if tt.policy != "" {
+				sink, err := NewRotatingSink(dir, "Test_trade.csv", tt.policy, 0, func(path string) (Sink, error) {
+					return NewCSVSink("trade", path), nil
+				})
+				assert.NoError(t, err)
+				buffer.Sink = sink
+			}
+
+			assert.NoError(t, buffer.AddData(tt.batch))
+			err := buffer.FlushData()
+			if tt.wantSkipped == 0 {
+				assert.NoError(t, err)
+			} else {
+				var skipped *SkippedError
+				if assert.ErrorAs(t, err, &skipped) {
+					assert.Equal(t, tt.wantSkipped, skipped.Skipped)
+					assert.Equal(t, len(tt.batch), skipped.Total)
+				}
+			}
+			assert.Equal(t, 0, buffer.Len())
+			assert.NoError(t, buffer.Close())
+
+			rows := 0
+			filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+				if err == nil && !entry.IsDir() {
+					rows += countRows(t, path)
+				}
+				return err
+			})
+			assert.Equal(t, tt.wantRows, rows, "the good records are written")
+		})
+	}
+}
+
+// failingWriteSink wraps a sink whose WriteBatch always fails
+type failingWriteSink struct {
+	Sink
+}
+
+func (f *failingWriteSink) WriteBatch(batch interface{}) error {
+	return os.ErrClosed
+}
+
+// TestDroppedBatch
+//
+// Description:
+// a batch the sink fails to write is reported with the number of records dropped,
+// and the buffer is emptied and closed anyway
+func TestDroppedBatch(t *testing.T) {
+	dir := t.TempDir()
+	buffer := NewDataBuffer("trade", "spot", "TestDroppedBatch", 10, "Test_trade.csv", dir)
+	buffer.Sink = &failingWriteSink{Sink: NewCSVSink("trade", filepath.Join(dir, "Test_trade.csv"))}
+
+	assert.NoError(t, buffer.AddData([]utils.TradeDataStruct{
+		{TimeStamp: 1, Symbol: "BTCUSD", Price: "1", Quantity: "1"},
+		{TimeStamp: 2, Symbol: "BTCUSD", Price: "2", Quantity: "1"},
+	}))
+	err := buffer.FlushData()
+	assert.ErrorIs(t, err, os.ErrClosed)
+	assert.ErrorContains(t, err, "dropped 2 trade records")
+	assert.Equal(t, 0, buffer.Len())
+	assert.NoError(t, buffer.Close())
+}
+
+func TestFlushExpired(t *testing.T) {
+	path := "../../Data/Tests/TestFlushExpired.csv"
+	os.Remove(path)
+	record := utils.TradeDataStruct{TimeStamp: 1, Symbol: "BTCUSD", Price: "2", Quantity: "3"}
+
+	buffer := NewDataBuffer("trade", "spot", "TestFlushExpired", 10, "TestFlushExpired.csv", "../../Data/Tests")
+	buffer.MaxAge = time.Minute
+	assert.NoError(t, buffer.FlushExpired(time.Now().Add(time.Hour)), "an empty buffer never expires")
+
+	assert.NoError(t, buffer.AddData(record))
+	assert.NoError(t, buffer.FlushExpired(time.Now()))
+	assert.Equal(t, 1, buffer.Len(), "a fresh record is kept")
+
+	// a second record does not reset the age of the first
+	assert.NoError(t, buffer.AddData(record))
+	assert.NoError(t, buffer.FlushExpired(time.Now().Add(time.Minute)))
+	assert.Equal(t, 0, buffer.Len(), "an expired buffer is flushed")
+	assert.NoError(t, buffer.Close())
+
+	contents, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, strings.Count(string(contents), "\n"))
+
+	buffer = NewDataBuffer("trade", "spot", "TestFlushExpired", 10, "TestFlushExpired.csv", "../../Data/Tests")
+	assert.NoError(t, buffer.AddData(record))
+	assert.NoError(t, buffer.FlushExpired(time.Now().Add(time.Hour)))
+	assert.Equal(t, 1, buffer.Len(), "MaxAge zero disables age flushes")
+}
@@ -0,0 +1,318 @@
+// Package parquet stores ticker and trade buffers as Parquet files with typed
+// columns. It registers the "parquet" sink with the buffer package from its
+// init function, so a binary only needs to import it.
+package parquet
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Antkky/go_crypto_scraper/utils"
+	"github.com/Antkky/go_crypto_scraper/utils/buffer"
+	pq "github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+const (
+	// DefaultRowGroupSize keeps at most this many bytes per stream in memory before a row group is written
+	DefaultRowGroupSize = 8 * 1024 * 1024
+	DefaultCompression  = "snappy"
+)
+
+func init() {
+	dataTypes := make([]string, 0, len(rowTypes))
+	for dataType := range rowTypes {
+		dataTypes = append(dataTypes, dataType)
+	}
+	buffer.RegisterSink("parquet", ".parquet", dataTypes, New)
+}
+
+// codecs maps the compression names accepted in SinkOptions to parquet codecs
+var codecs = map[string]pq.CompressionCodec{
+	"none":   pq.CompressionCodec_UNCOMPRESSED,
+	"snappy": pq.CompressionCodec_SNAPPY,
+	"gzip":   pq.CompressionCodec_GZIP,
+	"zstd":   pq.CompressionCodec_ZSTD,
+	"lz4":    pq.CompressionCodec_LZ4,
+}
+
+// TickerRow is the Parquet layout of utils.TickerDataStruct
+type TickerRow struct {
+	TimeStamp int64   `parquet:"name=TimeStamp, type=INT64, convertedtype=TIMESTAMP_MILLIS"`
+	Date      int64   `parquet:"name=Date, type=INT64"`
+	Symbol    string  `parquet:"name=Symbol, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	BidPrice  float64 `parquet:"name=BidPrice, type=DOUBLE"`
+	BidSize   float64 `parquet:"name=BidSize, type=DOUBLE"`
+	AskPrice  float64 `parquet:"name=AskPrice, type=DOUBLE"`
+	AskSize   float64 `parquet:"name=AskSize, type=DOUBLE"`
+	UpdateID  int64   `parquet:"name=UpdateID, type=INT64"`
+}
+
+// TradeRow is the Parquet layout of utils.TradeDataStruct
+type TradeRow struct {
+	TimeStamp int64   `parquet:"name=TimeStamp, type=INT64, convertedtype=TIMESTAMP_MILLIS"`
+	Date      int64   `parquet:"name=Date, type=INT64"`
+	Symbol    string  `parquet:"name=Symbol, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Price     float64 `parquet:"name=Price, type=DOUBLE"`
+	Quantity  float64 `parquet:"name=Quantity, type=DOUBLE"`
+	Bid_MM    bool    `parquet:"name=Bid_MM, type=BOOLEAN"`
+	TradeID   string  `parquet:"name=TradeID, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+// rowTypes are the data types this sink can store, with an empty row of each
+var rowTypes = map[string]interface{}{
+	"ticker": new(TickerRow),
+	"trade":  new(TradeRow),
+}
+
+// Sink writes one Parquet file. Rows collect in the open row group until it
+// reaches RowGroupSize; Close writes the last row group and the footer, so a
+// file is only readable once its sink has been closed, and a crash leaves the
+// open file unreadable. Streams on this sink are rotated hourly unless their
+// options pick a rotation, which bounds how much one unreadable file can hold.
+// The file only grows as row groups are written, so a max_file_size is reached
+// in steps of about RowGroupSize.
+type Sink struct {
+	DataType     string
+	Path         string
+	RowGroupSize int64
+	Compression  pq.CompressionCodec
+
+	file   *os.File
+	writer *writer.ParquetWriter
+	opened string
+}
+
+// New builds a Parquet sink for dataType records at path. Only ticker and
+// trade are supported; RowGroupSize and Compression default when unset.
+func New(dataType string, path string, options utils.SinkOptions) (buffer.Sink, error) {
+	if _, supported := rowTypes[dataType]; !supported {
+		return nil, fmt.Errorf("parquet sink does not support %s data", dataType)
+	}
+
+	rowGroupSize := options.RowGroupSize
+	if rowGroupSize <= 0 {
+		rowGroupSize = DefaultRowGroupSize
+	}
+	compression := options.Compression
+	if compression == "" {
+		compression = DefaultCompression
+	}
+	codec, known := codecs[strings.ToLower(compression)]
+	if !known {
+		return nil, fmt.Errorf("unknown parquet compression %q", compression)
+	}
+
+	return &Sink{DataType: dataType, Path: path, RowGroupSize: rowGroupSize, Compression: codec}, nil
+}
+
+// ________Small Helper Functions________
+
+// freePath returns path, or path with a counter before the extension when a
+// file is already there. A Parquet file cannot be appended to once its footer
+// is written, so a restart starts a new file next to the old one.
+func freePath(path string) (string, error) {
+	extension := filepath.Ext(path)
+	base := strings.TrimSuffix(path, extension)
+	candidate := path
+	for i := 1; ; i++ {
+		if _, err := os.Stat(candidate); os.IsNotExist(err) {
+			return candidate, nil
+		} else if err != nil {
+			return "", fmt.Errorf("error checking %s: %w", candidate, err)
+		}
+		candidate = fmt.Sprintf("%s.%d%s", base, i, extension)
+	}
+}
+
+func parseFloat(field string, value string) (float64, error) {
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", field, value, err)
+	}
+	return parsed, nil
+}
+
+func tickerRow(record utils.TickerDataStruct) (TickerRow, error) {
+	row := TickerRow{
+		TimeStamp: int64(record.TimeStamp),
+		Date:      int64(record.Date),
+		Symbol:    record.Symbol,
+		UpdateID:  int64(record.UpdateID),
+	}
+	var err error
+	if row.BidPrice, err = parseFloat("BidPrice", record.BidPrice); err != nil {
+		return TickerRow{}, err
+	}
+	if row.BidSize, err = parseFloat("BidSize", record.BidSize); err != nil {
+		return TickerRow{}, err
+	}
+	if row.AskPrice, err = parseFloat("AskPrice", record.AskPrice); err != nil {
+		return TickerRow{}, err
+	}
+	if row.AskSize, err = parseFloat("AskSize", record.AskSize); err != nil {
+		return TickerRow{}, err
+	}
+	return row, nil
+}
+
+func tradeRow(record utils.TradeDataStruct) (TradeRow, error) {
+	row := TradeRow{
+		TimeStamp: int64(record.TimeStamp),
+		Date:      int64(record.Date),
+		Symbol:    record.Symbol,
+		Bid_MM:    record.Bid_MM,
+		TradeID:   record.TradeID,
+	}
+	var err error
+	if row.Price, err = parseFloat("Price", record.Price); err != nil {
+		return TradeRow{}, err
+	}
+	if row.Quantity, err = parseFloat("Quantity", record.Quantity); err != nil {
+		return TradeRow{}, err
+	}
+	return row, nil
+}
+
+// ________Main Functions________
+
+// Open()
+//
+// Inputs:
+//
+//	No Inputs
+//
+// Outputs:
+//
+//	error
+//
+// Description:
+//
+//	Creates the directory and a new file at Path, or next to it if Path is taken,
+//	and starts a Parquet writer with the sink's row group size and compression.
+func (s *Sink) Open() error {
+	if err := os.MkdirAll(filepath.Dir(s.Path), os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	path, err := freePath(s.Path)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("error opening file: %w", err)
+	}
+
+	parquetWriter, err := writer.NewParquetWriterFromWriter(file, rowTypes[s.DataType], 1)
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("error starting parquet writer: %w", err)
+	}
+	parquetWriter.RowGroupSize = s.RowGroupSize
+	parquetWriter.CompressionType = s.Compression
+
+	s.file = file
+	s.writer = parquetWriter
+	s.opened = path
+	return nil
+}
+
+// File returns the path Open chose, Path unless a file was already there
+func (s *Sink) File() string {
+	return s.opened
+}
+
+// Appends is false, a finished Parquet file cannot be added to
+func (s *Sink) Appends() bool {
+	return false
+}
+
+// WriteBatch()
+//
+// Inputs:
+//
+//	batch : interface{}
+//
+// Outputs:
+//
+//	error
+//
+// Description:
+//
+//	Converts each record to its typed row and adds it to the open row group.
+//	A record whose price or size is not a number is skipped; the rest of the batch
+//	is still written and the error reports how many were skipped.
+func (s *Sink) WriteBatch(batch interface{}) error {
+	if s.writer == nil {
+		return fmt.Errorf("parquet sink %s is not open", s.Path)
+	}
+
+	var (
+		rows    []interface{}
+		skipped int
+		first   error
+	)
+	keep := func(row interface{}, err error) {
+		if err != nil {
+			skipped++
+			if first == nil {
+				first = err
+			}
+			return
+		}
+		rows = append(rows, row)
+	}
+	switch records := batch.(type) {
+	case []utils.TickerDataStruct:
+		for _, record := range records {
+			keep(tickerRow(record))
+		}
+	case []utils.TradeDataStruct:
+		for _, record := range records {
+			keep(tradeRow(record))
+		}
+	default:
+		return fmt.Errorf("parquet sink does not support %T", batch)
+	}
+
+	for _, row := range rows {
+		if err := s.writer.Write(row); err != nil {
+			return fmt.Errorf("error writing parquet row: %w", err)
+		}
+	}
+	if skipped > 0 {
+		return &buffer.SkippedError{Skipped: skipped, Total: skipped + len(rows), Err: first}
+	}
+	return nil
+}
+
+// Flush leaves rows in the open row group; writing one per buffer flush would
+// make tiny row groups. They are written when the group fills and on Close.
+func (s *Sink) Flush() error {
+	return nil
+}
+
+// Close writes the last row group and the footer, syncs and closes the file.
+// Closing a sink that is not open does nothing.
+func (s *Sink) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	stopErr := s.writer.WriteStop()
+	syncErr := s.file.Sync()
+	closeErr := s.file.Close()
+	s.file = nil
+	s.writer = nil
+
+	switch {
+	case stopErr != nil:
+		return fmt.Errorf("error writing parquet footer: %w", stopErr)
+	case syncErr != nil:
+		return fmt.Errorf("error syncing parquet file: %w", syncErr)
+	default:
+		return closeErr
+	}
+}
@@ -0,0 +1,106 @@
+package parquet
+
+import (
+	"github.com/Antkky/go_crypto_scraper/utils"
+)
+
+// Test Cases for New
+var NewCases = []struct {
+	name      string
+	dataType  string
+	options   utils.SinkOptions
+	wantError bool
+}{
+	{
+		name:     "trade with defaults",
+		dataType: "trade",
+	},
+	{
+		name:     "ticker with zstd",
+		dataType: "ticker",
+		options:  utils.SinkOptions{RowGroupSize: 1024, Compression: "ZSTD"},
+	},
+	{
+		name:      "unsupported type",
+		dataType:  "depth",
+		wantError: true,
+	},
+	{
+		name:      "unknown compression",
+		dataType:  "trade",
+		options:   utils.SinkOptions{Compression: "rar"},
+		wantError: true,
+	},
+}
+
+// Test Cases for writing and reading back a file
+var RoundTripCases = []struct {
+	name      string
+	dataType  string
+	batch     interface{}
+	want      interface{}
+	wantError bool
+}{
+	{
+		name:     "trades",
+		dataType: "trade",
+		batch: []utils.TradeDataStruct{
+			{TimeStamp: 1672515782136, Symbol: "BTCUSDT", Price: "16500.10", Quantity: "0.002", Bid_MM: true, TradeID: "12345"},
+			{TimeStamp: 1672515782137, Symbol: "BTCUSDT", Price: "16500.2", Quantity: "1", Bid_MM: false, TradeID: "12346"},
+		},
+		want: []TradeRow{
+			{TimeStamp: 1672515782136, Symbol: "BTCUSDT", Price: 16500.10, Quantity: 0.002, Bid_MM: true, TradeID: "12345"},
+			{TimeStamp: 1672515782137, Symbol: "BTCUSDT", Price: 16500.2, Quantity: 1, Bid_MM: false, TradeID: "12346"},
+		},
+	},
+	{
+		name:     "tickers",
+		dataType: "ticker",
+		batch: []utils.TickerDataStruct{
+			{TimeStamp: 1672515782136, Symbol: "BTCUSDT", BidPrice: "16500.1", BidSize: "2", AskPrice: "16500.2", AskSize: "0.5", UpdateID: 400900217},
+		},
+		want: []TickerRow{
+			{TimeStamp: 1672515782136, Symbol: "BTCUSDT", BidPrice: 16500.1, BidSize: 2, AskPrice: 16500.2, AskSize: 0.5, UpdateID: 400900217},
+		},
+	},
+	{
+		name:     "price that is not a number is skipped",
+		dataType: "trade",
+		batch: []utils.TradeDataStruct{
+			{TimeStamp: 1, Symbol: "BTCUSD", Price: "97,242.02", Quantity: "12", TradeID: "1"},
+			{TimeStamp: 2, Symbol: "BTCUSD", Price: "97242.02", Quantity: "12", TradeID: "2"},
+		},
+		want: []TradeRow{
+			{TimeStamp: 2, Symbol: "BTCUSD", Price: 97242.02, Quantity: 12, TradeID: "2"},
+		},
+		wantError: true,
+	},
+	{
+		name:      "batch of another type",
+		dataType:  "trade",
+		batch:     []utils.KlineDataStruct{{Symbol: "BTCUSD"}},
+		wantError: true,
+	},
+}
+
+// Test Cases for where row groups are cut
+var FlushCases = []struct {
+	name         string
+	rowGroupSize int64
+	flushes      int
+	rowsPerFlush int
+	wantOneGroup bool
+}{
+	{
+		name:         "small flushes share one row group",
+		flushes:      3,
+		rowsPerFlush: 1,
+		wantOneGroup: true,
+	},
+	{
+		name:         "row group size cuts within a flush",
+		rowGroupSize: 1024,
+		flushes:      1,
+		rowsPerFlush: 5000,
+	},
+}
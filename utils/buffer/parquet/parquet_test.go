@@ -0,0 +1,266 @@
+package parquet
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Antkky/go_crypto_scraper/utils"
+	"github.com/Antkky/go_crypto_scraper/utils/buffer"
+	"github.com/stretchr/testify/assert"
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+func TestNew(t *testing.T) {
+	for _, tt := range NewCases {
+		t.Run(tt.name, func(t *testing.T) {
+			sink, err := New(tt.dataType, "unused.parquet", tt.options)
+			if tt.wantError {
+				assert.Error(t, err, "Expected an error but got none")
+				return
+			}
+			assert.NoError(t, err)
+			assert.IsType(t, &Sink{}, sink)
+		})
+	}
+}
+
+func TestRegistered(t *testing.T) {
+	extension, err := buffer.SinkExtension("parquet")
+	assert.NoError(t, err)
+	assert.Equal(t, ".parquet", extension)
+}
+
+// readRows reads every row of the file at path into rows, a pointer to a slice
+func readRows(t *testing.T, path string, row interface{}, rows interface{}) {
+	file, err := local.NewLocalFileReader(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer file.Close()
+
+	parquetReader, err := reader.NewParquetReader(file, row, 1)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer parquetReader.ReadStop()
+	assert.NoError(t, parquetReader.Read(rows))
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, tt := range RoundTripCases {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "Test.parquet")
+			sink, err := New(tt.dataType, path, utils.SinkOptions{})
+			assert.NoError(t, err)
+			assert.NoError(t, sink.Open())
+
+			err = sink.WriteBatch(tt.batch)
+			assert.NoError(t, sink.Close())
+			if tt.wantError {
+				assert.Error(t, err, "Expected an error but got none")
+			} else {
+				assert.NoError(t, err)
+			}
+
+			switch want := tt.want.(type) {
+			case []TradeRow:
+				rows := make([]TradeRow, len(want))
+				readRows(t, path, new(TradeRow), &rows)
+				assert.Equal(t, want, rows)
+			case []TickerRow:
+				rows := make([]TickerRow, len(want))
+				readRows(t, path, new(TickerRow), &rows)
+				assert.Equal(t, want, rows)
+			}
+		})
+	}
+}
+
+// TestReopen
+//
+// Description:
+// a finished file is never appended to, a second sink starts the next file
+func TestReopen(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "Test.parquet")
+	batch := []utils.TradeDataStruct{{TimeStamp: 1, Symbol: "BTCUSDT", Price: "1", Quantity: "2", TradeID: "3"}}
+
+	for i := 0; i < 2; i++ {
+		sink, err := New("trade", path, utils.SinkOptions{})
+		assert.NoError(t, err)
+		assert.NoError(t, sink.Open())
+		assert.NoError(t, sink.WriteBatch(batch))
+		assert.NoError(t, sink.Close())
+		assert.NoError(t, sink.Close(), "closing twice is a no-op")
+	}
+
+	for _, name := range []string{"Test.parquet", "Test.1.parquet"} {
+		_, err := os.Stat(filepath.Join(dir, name))
+		assert.NoError(t, err)
+		rows := make([]TradeRow, 1)
+		readRows(t, filepath.Join(dir, name), new(TradeRow), &rows)
+		assert.Equal(t, "3", rows[0].TradeID)
+	}
+}
+
+// TestBadRecordFlush
+//
+// Description:
+// a batch with one record that cannot be converted still writes the others,
+// and the buffer is emptied so the next batch is not held up by it
+func TestBadRecordFlush(t *testing.T) {
+	dir := t.TempDir()
+	dataBuffer := buffer.NewDataBuffer("trade", "spot", "TestBadRecordFlush", 10, "Test.parquet", dir)
+	sink, err := New("trade", filepath.Join(dir, "Test.parquet"), utils.SinkOptions{})
+	assert.NoError(t, err)
+	dataBuffer.Sink = sink
+
+	assert.NoError(t, dataBuffer.AddData([]utils.TradeDataStruct{
+		{TimeStamp: 1, Symbol: "BTCUSDT", Price: "not a price", Quantity: "1", TradeID: "1"},
+		{TimeStamp: 2, Symbol: "BTCUSDT", Price: "2", Quantity: "1", TradeID: "2"},
+	}))
+	assert.Error(t, dataBuffer.FlushData(), "the bad record should be reported")
+	assert.Equal(t, 0, dataBuffer.Len(), "a failed batch must not stay in the buffer")
+
+	assert.NoError(t, dataBuffer.AddData(utils.TradeDataStruct{TimeStamp: 3, Symbol: "BTCUSDT", Price: "3", Quantity: "1", TradeID: "3"}))
+	assert.NoError(t, dataBuffer.FlushData())
+	assert.NoError(t, dataBuffer.Close())
+
+	rows := make([]TradeRow, 2)
+	readRows(t, filepath.Join(dir, "Test.parquet"), new(TradeRow), &rows)
+	assert.Equal(t, []string{"2", "3"}, []string{rows[0].TradeID, rows[1].TradeID})
+}
+
+// rowGroups counts the row groups in the footer of the file at path
+func rowGroups(t *testing.T, path string) int {
+	file, err := local.NewLocalFileReader(path)
+	if !assert.NoError(t, err) {
+		return 0
+	}
+	defer file.Close()
+
+	parquetReader, err := reader.NewParquetReader(file, nil, 1)
+	if !assert.NoError(t, err) {
+		return 0
+	}
+	defer parquetReader.ReadStop()
+	return len(parquetReader.Footer.RowGroups)
+}
+
+// TestFlush
+//
+// Description:
+// buffer flushes leave rows in the open row group, so small flushes still make
+// one row group; only RowGroupSize cuts them
+func TestFlush(t *testing.T) {
+	for _, tt := range FlushCases {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "Test.parquet")
+			sink, err := New("trade", path, utils.SinkOptions{RowGroupSize: tt.rowGroupSize})
+			assert.NoError(t, err)
+			assert.NoError(t, sink.Open())
+
+			id := 0
+			for i := 0; i < tt.flushes; i++ {
+				batch := make([]utils.TradeDataStruct, 0, tt.rowsPerFlush)
+				for j := 0; j < tt.rowsPerFlush; j++ {
+					id++
+					batch = append(batch, utils.TradeDataStruct{TimeStamp: uint64(id), Symbol: "BTCUSDT", Price: "1", Quantity: "2", TradeID: strconv.Itoa(id)})
+				}
+				assert.NoError(t, sink.WriteBatch(batch))
+				assert.NoError(t, sink.Flush())
+			}
+			assert.NoError(t, sink.Close())
+
+			groups := rowGroups(t, path)
+			if tt.wantOneGroup {
+				assert.Equal(t, 1, groups)
+			} else {
+				assert.Greater(t, groups, 1)
+			}
+			rows := make([]TradeRow, id)
+			readRows(t, path, new(TradeRow), &rows)
+			assert.Equal(t, strconv.Itoa(id), rows[id-1].TradeID)
+		})
+	}
+}
+
+// TestRotation
+//
+// Description:
+// after a restart the first part is already on disk and cannot be appended to,
+// so the rotating sink starts the next part itself and applies the size limit
+// to the part actually being written; small row groups make rows reach the file
+func TestRotation(t *testing.T) {
+	dir := t.TempDir()
+	newRotating := func(maxFileSize int64) *buffer.RotatingSink {
+		rotating, err := buffer.NewRotatingSink(dir, "Test.parquet", buffer.RotateSize, maxFileSize, func(path string) (buffer.Sink, error) {
+			return New("trade", path, utils.SinkOptions{RowGroupSize: 1024})
+		})
+		assert.NoError(t, err)
+		return rotating
+	}
+	// trades returns count trades with ids from first on
+	trades := func(first int, count int) []utils.TradeDataStruct {
+		batch := make([]utils.TradeDataStruct, 0, count)
+		for id := first; id < first+count; id++ {
+			batch = append(batch, utils.TradeDataStruct{TimeStamp: uint64(id), Symbol: "BTCUSDT", Price: "1", Quantity: "2", TradeID: strconv.Itoa(id)})
+		}
+		return batch
+	}
+
+	// the previous run left a small finished file behind
+	previous := newRotating(0)
+	assert.NoError(t, previous.WriteBatch(trades(1, 1)))
+	assert.NoError(t, previous.Close())
+	first, err := os.Stat(filepath.Join(dir, "Test.parquet"))
+	assert.NoError(t, err)
+
+	// a part as big as that file counts as full, which the next batch is well past
+	rotating := newRotating(first.Size() + 1)
+	assert.NoError(t, rotating.WriteBatch(trades(2, 5000)))
+	assert.NoError(t, rotating.Flush())
+
+	// the new part was closed as full before Close, so it is readable already
+	rows := make([]TradeRow, 5000)
+	readRows(t, filepath.Join(dir, "Test.1.parquet"), new(TradeRow), &rows)
+	assert.Equal(t, "2", rows[0].TradeID)
+
+	assert.NoError(t, rotating.WriteBatch(trades(5002, 1)))
+	assert.NoError(t, rotating.Close())
+	rows = make([]TradeRow, 1)
+	readRows(t, filepath.Join(dir, "Test.2.parquet"), new(TradeRow), &rows)
+	assert.Equal(t, "5002", rows[0].TradeID)
+
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 3)
+}
+
+// TestRotationBadRecord
+//
+// Description:
+// a record that cannot be converted in one partition does not keep the other
+// partitions of the same batch from being written
+func TestRotationBadRecord(t *testing.T) {
+	dir := t.TempDir()
+	rotating, err := buffer.NewRotatingSink(dir, "Test.parquet", buffer.RotateDaily, 0, func(path string) (buffer.Sink, error) {
+		return New("trade", path, utils.SinkOptions{})
+	})
+	assert.NoError(t, err)
+
+	day := uint64(24 * time.Hour / time.Millisecond)
+	assert.Error(t, rotating.WriteBatch([]utils.TradeDataStruct{
+		{TimeStamp: day, Symbol: "BTCUSDT", Price: "not a price", Quantity: "1", TradeID: "1"},
+		{TimeStamp: 2 * day, Symbol: "BTCUSDT", Price: "2", Quantity: "1", TradeID: "2"},
+	}))
+	assert.NoError(t, rotating.Close())
+
+	rows := make([]TradeRow, 1)
+	readRows(t, filepath.Join(dir, "date=1970-01-03", "Test.parquet"), new(TradeRow), &rows)
+	assert.Equal(t, "2", rows[0].TradeID)
+}
@@ -0,0 +1,213 @@
+package buffer
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/Antkky/go_crypto_scraper/utils"
+)
+
+// recordType is everything the buffer needs to know about one data type: the
+// record struct its batches hold, its CSV header and how a record becomes a row.
+// A new stream type only has to be registered in init below.
+type recordType struct {
+	dataType string
+	header   []string
+	// batchType is the slice type batches of this data type are held in, e.g. []utils.TradeDataStruct
+	batchType reflect.Type
+	format    func(record interface{}) ([]string, error)
+}
+
+var (
+	// recordTypes maps a data type name, e.g. "trade", to its record type
+	recordTypes = make(map[string]*recordType)
+	// recordStructs maps a record struct, e.g. utils.TradeDataStruct, to its record type
+	recordStructs = make(map[reflect.Type]*recordType)
+)
+
+// registerRecord makes dataType available to buffers, holding its records as T
+func registerRecord[T any](dataType string, header []string, format func(record T) ([]string, error)) {
+	record := &recordType{
+		dataType:  dataType,
+		header:    header,
+		batchType: reflect.TypeOf([]T(nil)),
+		format: func(record interface{}) ([]string, error) {
+			return format(record.(T))
+		},
+	}
+	recordTypes[dataType] = record
+	recordStructs[record.batchType.Elem()] = record
+}
+
+func init() {
+	registerRecord("ticker", []string{"TimeStamp", "Date", "Symbol", "BidPrice", "BidSize", "AskPrice", "AskSize", "UpdateID"}, formatTicker)
+	registerRecord("trade", []string{"TimeStamp", "Date", "Symbol", "Price", "Quantity", "Bid_MM", "TradeID"}, formatTrade)
+	registerRecord("depth", []string{"TimeStamp", "Date", "Symbol", "Kind", "UpdateID", "Side", "Price", "Size"}, formatDepth)
+	registerRecord("kline", []string{"TimeStamp", "Date", "Symbol", "Interval", "OpenTime", "CloseTime", "Open", "High", "Low", "Close", "Volume", "Closed"}, formatKline)
+	registerRecord("funding", []string{"TimeStamp", "Date", "Symbol", "MarkPrice", "IndexPrice", "FundingRate", "NextFundingTime"}, formatFunding)
+	registerRecord("liquidation", []string{"TimeStamp", "Date", "Symbol", "Side", "Price", "AvgPrice", "Quantity", "Filled", "Status"}, formatLiquidation)
+	registerRecord("stats24h", []string{"TimeStamp", "Date", "Symbol", "OpenTime", "CloseTime", "OpenPrice", "HighPrice", "LowPrice", "ClosePrice", "BaseVolume", "QuoteVolume"}, formatStats24h)
+	registerRecord("aggTrade", []string{"TimeStamp", "Date", "Symbol", "AggTradeID", "Price", "Quantity", "FirstTradeID", "LastTradeID", "TradeTime", "IsBuyerMaker"}, formatAggTrade)
+	registerRecord("gap", []string{"Symbol", "FirstMissing", "LastMissing", "WallTime"}, formatGap)
+}
+
+// ________Formatters________
+
+func formatTicker(v utils.TickerDataStruct) ([]string, error) {
+	if v.BidPrice == "" || v.AskPrice == "" || v.BidSize == "" || v.AskSize == "" {
+		return nil, fmt.Errorf("missing required field(s) in TickerDataStruct")
+	}
+
+	return []string{
+		fmt.Sprintf("%d", v.TimeStamp),
+		fmt.Sprintf("%d", v.Date),
+		v.Symbol,
+		v.BidPrice,
+		v.BidSize,
+		v.AskPrice,
+		v.AskSize,
+		fmt.Sprintf("%d", v.UpdateID),
+	}, nil
+}
+
+func formatTrade(v utils.TradeDataStruct) ([]string, error) {
+	// Check for empty fields that should contain data
+	if v.Price == "" || v.Quantity == "" {
+		return nil, fmt.Errorf("missing required field(s) in TradeDataStruct")
+	}
+
+	// Convert timestamp and date to string
+	return []string{
+		fmt.Sprintf("%d", v.TimeStamp), // TimeStamp as integer
+		fmt.Sprintf("%d", v.Date),      // Date as integer
+		v.Symbol,                       // Symbol
+		v.Price,                        // Price as float with 2 decimals
+		v.Quantity,                     // Quantity as integer
+		fmt.Sprintf("%t", v.Bid_MM),    // Bid_MM as string ("true" or "false")
+		v.TradeID,                      // TradeID as sent by the venue
+	}, nil
+}
+
+func formatDepth(v utils.DepthDataStruct) ([]string, error) {
+	if v.Price == "" || v.Size == "" {
+		return nil, fmt.Errorf("missing required field(s) in DepthDataStruct")
+	}
+
+	return []string{
+		fmt.Sprintf("%d", v.TimeStamp),
+		fmt.Sprintf("%d", v.Date),
+		v.Symbol,
+		v.Kind,
+		fmt.Sprintf("%d", v.UpdateID),
+		v.Side,
+		v.Price,
+		v.Size,
+	}, nil
+}
+
+func formatKline(v utils.KlineDataStruct) ([]string, error) {
+	if v.Open == "" || v.High == "" || v.Low == "" || v.Close == "" {
+		return nil, fmt.Errorf("missing required field(s) in KlineDataStruct")
+	}
+
+	return []string{
+		fmt.Sprintf("%d", v.TimeStamp),
+		fmt.Sprintf("%d", v.Date),
+		v.Symbol,
+		v.Interval,
+		fmt.Sprintf("%d", v.OpenTime),
+		fmt.Sprintf("%d", v.CloseTime),
+		v.Open,
+		v.High,
+		v.Low,
+		v.Close,
+		v.Volume,
+		fmt.Sprintf("%t", v.Closed),
+	}, nil
+}
+
+func formatFunding(v utils.FundingDataStruct) ([]string, error) {
+	if v.MarkPrice == "" {
+		return nil, fmt.Errorf("missing required field(s) in FundingDataStruct")
+	}
+
+	return []string{
+		fmt.Sprintf("%d", v.TimeStamp),
+		fmt.Sprintf("%d", v.Date),
+		v.Symbol,
+		v.MarkPrice,
+		v.IndexPrice,
+		v.FundingRate,
+		fmt.Sprintf("%d", v.NextFundingTime),
+	}, nil
+}
+
+func formatLiquidation(v utils.LiquidationDataStruct) ([]string, error) {
+	if v.Side == "" || v.Price == "" || v.Quantity == "" {
+		return nil, fmt.Errorf("missing required field(s) in LiquidationDataStruct")
+	}
+
+	return []string{
+		fmt.Sprintf("%d", v.TimeStamp),
+		fmt.Sprintf("%d", v.Date),
+		v.Symbol,
+		v.Side,
+		v.Price,
+		v.AvgPrice,
+		v.Quantity,
+		v.Filled,
+		v.Status,
+	}, nil
+}
+
+func formatStats24h(v utils.Stats24hDataStruct) ([]string, error) {
+	if v.OpenPrice == "" || v.ClosePrice == "" {
+		return nil, fmt.Errorf("missing required field(s) in Stats24hDataStruct")
+	}
+
+	return []string{
+		fmt.Sprintf("%d", v.TimeStamp),
+		fmt.Sprintf("%d", v.Date),
+		v.Symbol,
+		fmt.Sprintf("%d", v.OpenTime),
+		fmt.Sprintf("%d", v.CloseTime),
+		v.OpenPrice,
+		v.HighPrice,
+		v.LowPrice,
+		v.ClosePrice,
+		v.BaseVolume,
+		v.QuoteVolume,
+	}, nil
+}
+
+func formatAggTrade(v utils.AggTradeDataStruct) ([]string, error) {
+	if v.Price == "" || v.Quantity == "" {
+		return nil, fmt.Errorf("missing required field(s) in AggTradeDataStruct")
+	}
+
+	return []string{
+		fmt.Sprintf("%d", v.TimeStamp),
+		fmt.Sprintf("%d", v.Date),
+		v.Symbol,
+		fmt.Sprintf("%d", v.AggTradeID),
+		v.Price,
+		v.Quantity,
+		fmt.Sprintf("%d", v.FirstTradeID),
+		fmt.Sprintf("%d", v.LastTradeID),
+		fmt.Sprintf("%d", v.TradeTime),
+		fmt.Sprintf("%t", v.IsBuyerMaker),
+	}, nil
+}
+
+func formatGap(v utils.GapDataStruct) ([]string, error) {
+	if v.LastMissing < v.FirstMissing {
+		return nil, fmt.Errorf("invalid range in GapDataStruct")
+	}
+
+	return []string{
+		v.Symbol,
+		fmt.Sprintf("%d", v.FirstMissing),
+		fmt.Sprintf("%d", v.LastMissing),
+		fmt.Sprintf("%d", v.WallTime),
+	}, nil
+}
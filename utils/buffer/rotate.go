@@ -0,0 +1,316 @@
+package buffer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Rotation policies for SinkOptions.Rotate
+const (
+	RotateHourly = "hourly"
+	RotateDaily  = "daily"
+	RotateSize   = "size"
+)
+
+// defaultMaxFileSize is used by the size policy when no max_file_size is set
+const defaultMaxFileSize = 100 * 1024 * 1024
+
+// partition is one open file of a RotatingSink
+type partition struct {
+	start time.Time
+	path  string
+	sink  Sink
+}
+
+// RotatingSink splits a stream over many files. Hourly and daily policies put
+// each record under Dir/date=YYYY-MM-DD[/hour=HH]/FileName by its event time,
+// so a late message still lands in the partition it belongs to. With a
+// MaxFileSize, a file that grows past it is closed and the next part,
+// name.1.ext, name.2.ext, ..., is started in the same partition. For sinks that
+// cannot append, such as Parquet, a reopened partition skips every existing part.
+type RotatingSink struct {
+	Dir         string
+	FileName    string
+	Policy      string
+	MaxFileSize int64
+	// New builds the sink that writes one file
+	New func(path string) (Sink, error)
+
+	// now stamps records that carry no event time
+	now        func() time.Time
+	partitions map[string]*partition
+	latest     time.Time
+}
+
+// NewRotatingSink()
+//
+// Inputs:
+//
+//	dir         : string
+//	fileName    : string
+//	policy      : string
+//	maxFileSize : int64
+//	newSink     : func(path string) (Sink, error)
+//
+// Outputs:
+//
+//	*RotatingSink
+//	error
+//
+// Description:
+//
+//	Builds a sink that rotates by policy, "hourly", "daily" or "size". A maxFileSize
+//	of zero means no size limit, except for the size policy which then uses 100MB.
+func NewRotatingSink(dir string, fileName string, policy string, maxFileSize int64, newSink func(path string) (Sink, error)) (*RotatingSink, error) {
+	policy = strings.ToLower(policy)
+	switch policy {
+	case RotateHourly, RotateDaily:
+	case RotateSize:
+		if maxFileSize <= 0 {
+			maxFileSize = defaultMaxFileSize
+		}
+	default:
+		return nil, fmt.Errorf("unknown rotation policy %q", policy)
+	}
+	return &RotatingSink{
+		Dir:         dir,
+		FileName:    fileName,
+		Policy:      policy,
+		MaxFileSize: maxFileSize,
+		New:         newSink,
+		now:         time.Now,
+		partitions:  make(map[string]*partition),
+	}, nil
+}
+
+// ________Small Helper Functions________
+
+// period is how much event time one partition covers, zero for size only
+func (s *RotatingSink) period() time.Duration {
+	switch s.Policy {
+	case RotateHourly:
+		return time.Hour
+	case RotateDaily:
+		return 24 * time.Hour
+	default:
+		return 0
+	}
+}
+
+// partitionOf returns the directory under Dir and the start time of the partition holding eventTime
+func (s *RotatingSink) partitionOf(eventTime time.Time) (string, time.Time) {
+	switch s.Policy {
+	case RotateHourly:
+		start := eventTime.Truncate(time.Hour)
+		return filepath.Join("date="+start.Format("2006-01-02"), "hour="+start.Format("15")), start
+	case RotateDaily:
+		start := eventTime.Truncate(24 * time.Hour)
+		return "date=" + start.Format("2006-01-02"), start
+	default:
+		return "", time.Time{}
+	}
+}
+
+// eventTime reads a record's TimeStamp (ms), falling back to now for records without one
+func (s *RotatingSink) eventTime(record reflect.Value) time.Time {
+	if field := record.FieldByName("TimeStamp"); field.IsValid() && field.CanUint() && field.Uint() != 0 {
+		return time.UnixMilli(int64(field.Uint())).UTC()
+	}
+	return s.now().UTC()
+}
+
+// partPath returns the file of the given part in dir: FileName for part 0, name.N.ext after that
+func (s *RotatingSink) partPath(dir string, part int) string {
+	if part == 0 {
+		return filepath.Join(s.Dir, dir, s.FileName)
+	}
+	extension := filepath.Ext(s.FileName)
+	return filepath.Join(s.Dir, dir, fmt.Sprintf("%s.%d%s", strings.TrimSuffix(s.FileName, extension), part, extension))
+}
+
+// full reports whether the file at path has reached MaxFileSize
+func (s *RotatingSink) full(path string) bool {
+	if s.MaxFileSize <= 0 {
+		return false
+	}
+	info, err := os.Stat(path)
+	return err == nil && info.Size() >= s.MaxFileSize
+}
+
+// taken reports whether the part at path cannot be written by sink: it is full,
+// or a file is there and sink would start a new file rather than append to it
+func (s *RotatingSink) taken(path string, sink Sink) bool {
+	if s.full(path) {
+		return true
+	}
+	if file, ok := sink.(FileSink); ok && !file.Appends() {
+		_, err := os.Stat(path)
+		return err == nil
+	}
+	return false
+}
+
+// open starts the first part of dir that its sink can write. The partition keeps
+// the path the sink actually opened, so size checks look at the right file.
+func (s *RotatingSink) open(dir string, start time.Time) (*partition, error) {
+	for part := 0; ; part++ {
+		path := s.partPath(dir, part)
+		sink, err := s.New(path)
+		if err != nil {
+			return nil, err
+		}
+		if s.taken(path, sink) {
+			continue
+		}
+		if err := sink.Open(); err != nil {
+			return nil, fmt.Errorf("error opening %s: %w", path, err)
+		}
+		if file, ok := sink.(FileSink); ok && file.File() != "" && file.File() != path {
+			// the sink moved to another file, e.g. a CSV with a newer header, which may be full too
+			path = file.File()
+			if s.full(path) {
+				if err := sink.Close(); err != nil {
+					return nil, fmt.Errorf("error closing %s: %w", path, err)
+				}
+				continue
+			}
+		}
+		opened := &partition{start: start, path: path, sink: sink}
+		s.partitions[dir] = opened
+		return opened, nil
+	}
+}
+
+// ________Main Functions________
+
+// Open does nothing; each partition's file is opened when its first record arrives.
+func (s *RotatingSink) Open() error {
+	return nil
+}
+
+// WriteBatch()
+//
+// Inputs:
+//
+//	batch : interface{}
+//
+// Outputs:
+//
+//	error
+//
+// Description:
+//
+//	Splits batch, a slice of records, by the partition of each record's event time
+//	and writes every piece to that partition's file, opening it when needed. A piece
+//	that fails does not stop the others; the first error is returned. Records the
+//	partitions skip are added up into one SkippedError for the whole batch.
+func (s *RotatingSink) WriteBatch(batch interface{}) error {
+	records := reflect.ValueOf(batch)
+	if records.Kind() != reflect.Slice {
+		return fmt.Errorf("rotating sink cannot split %T", batch)
+	}
+
+	pieces := make(map[string]reflect.Value)
+	starts := make(map[string]time.Time)
+	for i := 0; i < records.Len(); i++ {
+		eventTime := s.eventTime(records.Index(i))
+		dir, start := s.partitionOf(eventTime)
+		piece, exists := pieces[dir]
+		if !exists {
+			piece = reflect.MakeSlice(records.Type(), 0, records.Len())
+			starts[dir] = start
+		}
+		pieces[dir] = reflect.Append(piece, records.Index(i))
+		if start.After(s.latest) {
+			s.latest = start
+		}
+	}
+
+	dirs := make([]string, 0, len(pieces))
+	for dir := range pieces {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	var first error
+	skipped := &SkippedError{Total: records.Len()}
+	for _, dir := range dirs {
+		current, exists := s.partitions[dir]
+		if !exists {
+			var err error
+			if current, err = s.open(dir, starts[dir]); err != nil {
+				if first == nil {
+					first = err
+				}
+				continue
+			}
+		}
+		err := current.sink.WriteBatch(pieces[dir].Interface())
+		var partial *SkippedError
+		if errors.As(err, &partial) {
+			skipped.Skipped += partial.Skipped
+			if skipped.Err == nil {
+				skipped.Err = partial.Err
+			}
+		} else if err != nil && first == nil {
+			first = err
+		}
+	}
+	if first == nil && skipped.Skipped > 0 {
+		return skipped
+	}
+	return first
+}
+
+// Flush()
+//
+// Inputs:
+//
+//	No Inputs
+//
+// Outputs:
+//
+//	error
+//
+// Description:
+//
+//	Flushes every open file. A file that has reached MaxFileSize is closed, so the next
+//	write to its partition starts the next part. Partitions more than one period behind
+//	the newest event time are closed too; a late record reopens them. A file that fails
+//	does not stop the others; the first error is returned once all were handled.
+func (s *RotatingSink) Flush() error {
+	var first error
+	period := s.period()
+	for dir, current := range s.partitions {
+		if err := current.sink.Flush(); err != nil && first == nil {
+			first = fmt.Errorf("error flushing %s: %w", current.path, err)
+		}
+
+		stale := period > 0 && current.start.Add(period).Before(s.latest)
+		if !stale && !s.full(current.path) {
+			continue
+		}
+		delete(s.partitions, dir)
+		if err := current.sink.Close(); err != nil && first == nil {
+			first = fmt.Errorf("error closing %s: %w", current.path, err)
+		}
+	}
+	return first
+}
+
+// Close closes every open file, returning the first error.
+func (s *RotatingSink) Close() error {
+	var first error
+	for dir, current := range s.partitions {
+		delete(s.partitions, dir)
+		if err := current.sink.Close(); err != nil && first == nil {
+			first = fmt.Errorf("error closing %s: %w", current.path, err)
+		}
+	}
+	return first
+}
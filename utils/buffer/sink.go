@@ -0,0 +1,288 @@
+package buffer
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Antkky/go_crypto_scraper/utils"
+)
+
+// DefaultSink is used when neither the stream nor the exchange picks one
+const DefaultSink = "csv"
+
+// Sink is where a DataBuffer's batches are stored. A buffer opens its sink on
+// the first flush, writes each batch, flushes, and closes it on shutdown.
+type Sink interface {
+	Open() error
+	// WriteBatch stores a slice of records of the buffer's data type, e.g. []utils.TradeDataStruct
+	WriteBatch(batch interface{}) error
+	Flush() error
+	Close() error
+}
+
+// FileSink is implemented by sinks that write a single file. File returns the
+// path Open chose, which may differ from the one the sink was built with, and
+// Appends reports whether an existing file at that path is added to; a sink that
+// does not append starts a new file instead. RotatingSink uses both to number
+// its parts and to check the size of the file actually being written.
+type FileSink interface {
+	Sink
+	File() string
+	Appends() bool
+}
+
+// SkippedError is returned by WriteBatch when some records of a batch could not
+// be stored. The rest of the batch was written; Err is the first record's error.
+type SkippedError struct {
+	Skipped int
+	Total   int
+	Err     error
+}
+
+func (e *SkippedError) Error() string {
+	return fmt.Sprintf("skipped %d of %d records: %v", e.Skipped, e.Total, e.Err)
+}
+
+func (e *SkippedError) Unwrap() error {
+	return e.Err
+}
+
+// SinkFactory builds a sink writing dataType records to path. It returns an
+// error when the backend cannot store dataType or the options are invalid.
+type SinkFactory func(dataType string, path string, options utils.SinkOptions) (Sink, error)
+
+type sinkEntry struct {
+	extension string
+	// dataTypes is the set of types the sink can store, nil for any
+	dataTypes map[string]bool
+	factory   SinkFactory
+}
+
+var (
+	sinksMu sync.RWMutex
+	sinks   = map[string]sinkEntry{
+		"csv": {extension: ".csv", factory: func(dataType string, path string, _ utils.SinkOptions) (Sink, error) {
+			return NewCSVSink(dataType, path), nil
+		}},
+	}
+)
+
+// RegisterSink makes a storage backend available by name. extension, e.g.
+// ".csv", is appended to the file names of the buffers that use it. dataTypes
+// lists the types it can store; nil means every type.
+func RegisterSink(name string, extension string, dataTypes []string, factory SinkFactory) {
+	entry := sinkEntry{extension: extension, factory: factory}
+	if dataTypes != nil {
+		entry.dataTypes = make(map[string]bool, len(dataTypes))
+		for _, dataType := range dataTypes {
+			entry.dataTypes[dataType] = true
+		}
+	}
+
+	sinksMu.Lock()
+	defer sinksMu.Unlock()
+	sinks[strings.ToLower(name)] = entry
+}
+
+func lookupSink(name string) (sinkEntry, error) {
+	sinksMu.RLock()
+	defer sinksMu.RUnlock()
+	entry, exists := sinks[strings.ToLower(name)]
+	if !exists {
+		registered := make([]string, 0, len(sinks))
+		for sinkName := range sinks {
+			registered = append(registered, sinkName)
+		}
+		sort.Strings(registered)
+		return sinkEntry{}, fmt.Errorf("unknown sink %q (registered: %s)", name, strings.Join(registered, ", "))
+	}
+	return entry, nil
+}
+
+// SinkExtension returns the file extension of the named sink
+func SinkExtension(name string) (string, error) {
+	entry, err := lookupSink(name)
+	if err != nil {
+		return "", err
+	}
+	return entry.extension, nil
+}
+
+// SinkSupports reports whether the named sink is registered and can store dataType records
+func SinkSupports(name string, dataType string) bool {
+	entry, err := lookupSink(name)
+	if err != nil {
+		return false
+	}
+	return entry.dataTypes == nil || entry.dataTypes[dataType]
+}
+
+// NewSink builds the named sink for dataType records at path
+func NewSink(name string, dataType string, path string, options utils.SinkOptions) (Sink, error) {
+	entry, err := lookupSink(name)
+	if err != nil {
+		return nil, err
+	}
+	return entry.factory(dataType, path, options)
+}
+
+// CSVSink appends records to a CSV file, writing the header when the file is new.
+// When the file at Path was written with a different header, e.g. before a column
+// was added, the records go to the first of name.v2.csv, name.v3.csv, ... that is
+// new or has the current header, so one file never mixes row widths.
+type CSVSink struct {
+	DataType string
+	Path     string
+
+	file   *os.File
+	writer *csv.Writer
+	opened string
+}
+
+// NewCSVSink builds a CSV sink for dataType records at path
+func NewCSVSink(dataType string, path string) Sink {
+	return &CSVSink{DataType: dataType, Path: path}
+}
+
+// readCSVHeader returns the first row of the CSV file at path, nil when it does not exist or is empty
+func readCSVHeader(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %w", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading CSV header of %s: %w", path, err)
+	}
+	return header, nil
+}
+
+// headerPath returns path, or the first versioned path next to it, whose file is new or starts with header
+func headerPath(path string, header []string) (string, error) {
+	extension := filepath.Ext(path)
+	base := strings.TrimSuffix(path, extension)
+	candidate := path
+	for version := 2; ; version++ {
+		existing, err := readCSVHeader(candidate)
+		if err != nil {
+			return "", err
+		}
+		if existing == nil || slices.Equal(existing, header) {
+			return candidate, nil
+		}
+		candidate = fmt.Sprintf("%s.v%d%s", base, version, extension)
+	}
+}
+
+// Open()
+//
+// Inputs:
+//
+//	No Inputs
+//
+// Outputs:
+//
+//	error
+//
+// Description:
+//
+//	Creates the directory and opens the file for appending. A new or empty file
+//	gets the data type's header first, so reopening an existing file keeps one header.
+//	A file with another header is left alone and a versioned file is used instead.
+func (s *CSVSink) Open() error {
+	if err := validateFilePath(s.Path); err != nil {
+		return fmt.Errorf("invalid file path: %w", err)
+	}
+	header, err := getCSVHeader(s.DataType)
+	if err != nil {
+		return fmt.Errorf("error getting CSV header: %w", err)
+	}
+	path, err := headerPath(s.Path, header)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("error opening file: %w", err)
+	}
+	isEmpty, err := isFileEmpty(path)
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("error checking file empty status: %w", err)
+	}
+
+	writer := csv.NewWriter(file)
+	if isEmpty {
+		if err := writer.Write(header); err != nil {
+			file.Close()
+			return fmt.Errorf("error writing CSV header: %w", err)
+		}
+	}
+	s.file = file
+	s.writer = writer
+	s.opened = path
+	return nil
+}
+
+// File returns the path Open chose, Path unless its header was out of date
+func (s *CSVSink) File() string {
+	return s.opened
+}
+
+// Appends is true, records are added to an existing file with the same header
+func (s *CSVSink) Appends() bool {
+	return true
+}
+
+func (s *CSVSink) WriteBatch(batch interface{}) error {
+	if s.writer == nil {
+		return fmt.Errorf("csv sink %s is not open", s.Path)
+	}
+	return writeDataToCSV(s.writer, batch)
+}
+
+func (s *CSVSink) Flush() error {
+	if s.writer == nil {
+		return nil
+	}
+	s.writer.Flush()
+	return s.writer.Error()
+}
+
+// Close flushes, syncs and closes the file; closing a sink that is not open does nothing.
+func (s *CSVSink) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	flushErr := s.Flush()
+	syncErr := s.file.Sync()
+	closeErr := s.file.Close()
+	s.file = nil
+	s.writer = nil
+	switch {
+	case flushErr != nil:
+		return flushErr
+	case syncErr != nil:
+		return fmt.Errorf("error syncing %s: %w", s.Path, syncErr)
+	default:
+		return closeErr
+	}
+}
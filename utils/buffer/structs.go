@@ -1,16 +1,30 @@
 package buffer
 
-import "github.com/Antkky/go_crypto_scraper/utils"
+import (
+	"time"
+)
 
 // Buffer Structs
 type DataBuffer struct {
-	TickerBuffer []utils.TickerDataStruct
-	TradeBuffer  []utils.TradeDataStruct
-	MaxSize      int
-	DataType     string
-	Symbol       string
-	Market       string
-	ID           string
-	FilePath     string
-	FileName     string
+	// records is the batch waiting to be flushed, a slice of the data type's
+	// record struct such as []utils.TradeDataStruct; nil for an unknown data type
+	records interface{}
+	// IncludePartial keeps klines that are not closed yet
+	IncludePartial bool
+	// MaxSize flushes the buffer once it holds this many records
+	MaxSize int
+	// MaxAge flushes the buffer once its oldest record is this old, see FlushExpired; zero disables it
+	MaxAge   time.Duration
+	DataType string
+	Symbol   string
+	Market   string
+	ID       string
+	FilePath string
+	FileName string
+	// Sink stores flushed batches, CSV at FilePath/FileName by default
+	Sink Sink
+	// opened is set once Sink has been opened
+	opened bool
+	// oldest is when the first record still in the buffer was added
+	oldest time.Time
 }
@@ -0,0 +1,46 @@
+package codec
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+// IsGzip reports whether data starts with the gzip magic numbers (0x1f 0x8b)
+func IsGzip(data []byte) bool {
+	return len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b
+}
+
+// Gunzip decompresses a gzip encoded frame, as sent by Coinex and HTX
+func Gunzip(data []byte) ([]byte, error) {
+	if !IsGzip(data) {
+		return nil, fmt.Errorf("invalid gzip header")
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gzip reader: %w", err)
+	}
+	defer reader.Close()
+
+	var decompressed bytes.Buffer
+	if _, err := io.Copy(&decompressed, reader); err != nil {
+		return nil, fmt.Errorf("failed to decompress data: %w", err)
+	}
+
+	return decompressed.Bytes(), nil
+}
+
+// Gzip compresses data; the inverse of Gunzip, mostly useful for building test frames
+func Gzip(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(data); err != nil {
+		return nil, fmt.Errorf("failed to compress data: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress data: %w", err)
+	}
+	return buf.Bytes(), nil
+}
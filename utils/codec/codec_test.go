@@ -0,0 +1,25 @@
+package codec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGunzip(t *testing.T) {
+	payload := []byte(`{"method":"bbo.update"}`)
+
+	compressed, err := Gzip(payload)
+	assert.NoError(t, err)
+	assert.True(t, IsGzip(compressed))
+
+	decompressed, err := Gunzip(compressed)
+	assert.NoError(t, err)
+	assert.Equal(t, payload, decompressed)
+
+	_, err = Gunzip(payload)
+	assert.EqualError(t, err, "invalid gzip header")
+
+	_, err = Gunzip([]byte{0x1f, 0x8b, 0x00})
+	assert.Error(t, err)
+}
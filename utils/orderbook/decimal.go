@@ -0,0 +1,105 @@
+package orderbook
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// fracDigits is how many decimal places a Decimal holds exactly
+const fracDigits = 18
+
+var pow10 = func() [fracDigits + 1]uint64 {
+	var p [fracDigits + 1]uint64
+	p[0] = 1
+	for i := 1; i <= fracDigits; i++ {
+		p[i] = p[i-1] * 10
+	}
+	return p
+}()
+
+// Decimal is an exact, non negative decimal price or size. "30000", "30000.0"
+// and "30000.00" all parse to the same Decimal, so they key the same level.
+type Decimal struct {
+	Int  uint64
+	Frac uint64 // fractional part scaled by 10^18
+}
+
+// ParseDecimal()
+//
+// Inputs:
+//
+//	s : string
+//
+// Outputs:
+//
+//	Decimal
+//	error
+//
+// Description:
+//
+//	Parses plain decimal text such as "0.00015729" without going through float64.
+//	Negative numbers, exponents and more than 18 significant decimal places are rejected.
+func ParseDecimal(s string) (Decimal, error) {
+	intPart, fracPart, _ := strings.Cut(s, ".")
+	if intPart == "" && fracPart == "" {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+
+	var d Decimal
+	if intPart != "" {
+		value, err := strconv.ParseUint(intPart, 10, 64)
+		if err != nil {
+			return Decimal{}, fmt.Errorf("invalid decimal %q: %w", s, err)
+		}
+		d.Int = value
+	}
+
+	fracPart = strings.TrimRight(fracPart, "0")
+	if fracPart == "" {
+		return d, nil
+	}
+	if len(fracPart) > fracDigits {
+		return Decimal{}, fmt.Errorf("invalid decimal %q: more than %d decimal places", s, fracDigits)
+	}
+	value, err := strconv.ParseUint(fracPart, 10, 64)
+	if err != nil {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+	d.Frac = value * pow10[fracDigits-len(fracPart)]
+	return d, nil
+}
+
+// Cmp returns -1, 0 or +1 as d is less than, equal to or greater than other.
+func (d Decimal) Cmp(other Decimal) int {
+	switch {
+	case d.Int < other.Int:
+		return -1
+	case d.Int > other.Int:
+		return 1
+	case d.Frac < other.Frac:
+		return -1
+	case d.Frac > other.Frac:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (d Decimal) IsZero() bool {
+	return d.Int == 0 && d.Frac == 0
+}
+
+// Float64 converts d for arithmetic such as mid and microprice.
+func (d Decimal) Float64() float64 {
+	return float64(d.Int) + float64(d.Frac)/float64(pow10[fracDigits])
+}
+
+// String returns the shortest exact text for d, e.g. "30000" or "0.5".
+func (d Decimal) String() string {
+	if d.Frac == 0 {
+		return strconv.FormatUint(d.Int, 10)
+	}
+	frac := fmt.Sprintf("%0*d", fracDigits, d.Frac)
+	return strconv.FormatUint(d.Int, 10) + "." + strings.TrimRight(frac, "0")
+}
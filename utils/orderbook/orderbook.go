@@ -0,0 +1,230 @@
+package orderbook
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Side selects the bid or ask half of a book
+type Side int
+
+const (
+	Bid Side = iota
+	Ask
+)
+
+func (s Side) String() string {
+	if s == Bid {
+		return "bid"
+	}
+	return "ask"
+}
+
+// Level is one price level as the exchange sent it. The text is kept verbatim
+// because some venues checksum the exact strings they sent.
+type Level struct {
+	Price string
+	Size  string
+}
+
+type entry struct {
+	key    Decimal
+	price  string
+	size   string
+	amount Decimal
+}
+
+// side keeps its levels sorted best price first
+type side struct {
+	entries    []entry
+	descending bool
+}
+
+// search returns the index key is at, or would be inserted at
+func (s *side) search(key Decimal) int {
+	return sort.Search(len(s.entries), func(i int) bool {
+		if s.descending {
+			return s.entries[i].key.Cmp(key) <= 0
+		}
+		return s.entries[i].key.Cmp(key) >= 0
+	})
+}
+
+func (s *side) set(key Decimal, amount Decimal, price string, size string) {
+	i := s.search(key)
+	if i < len(s.entries) && s.entries[i].key == key {
+		if amount.IsZero() {
+			s.entries = append(s.entries[:i], s.entries[i+1:]...)
+			return
+		}
+		s.entries[i] = entry{key: key, price: price, size: size, amount: amount}
+		return
+	}
+	if amount.IsZero() {
+		return
+	}
+	s.entries = append(s.entries, entry{})
+	copy(s.entries[i+1:], s.entries[i:])
+	s.entries[i] = entry{key: key, price: price, size: size, amount: amount}
+}
+
+func (s *side) top(n int) []Level {
+	if n <= 0 || n > len(s.entries) {
+		n = len(s.entries)
+	}
+	levels := make([]Level, n)
+	for i := range levels {
+		levels[i] = Level{Price: s.entries[i].price, Size: s.entries[i].size}
+	}
+	return levels
+}
+
+// Book is a sorted price level book for one symbol, keyed by exact decimal prices.
+// It is safe for concurrent use.
+type Book struct {
+	Symbol string
+
+	mu   sync.RWMutex
+	bids side
+	asks side
+}
+
+// Snapshot is a copy of a book, best levels first.
+type Snapshot struct {
+	Symbol string
+	Bids   []Level
+	Asks   []Level
+}
+
+// New builds an empty book for symbol
+func New(symbol string) *Book {
+	return &Book{
+		Symbol: symbol,
+		bids:   side{descending: true},
+		asks:   side{},
+	}
+}
+
+func (b *Book) side(s Side) *side {
+	if s == Bid {
+		return &b.bids
+	}
+	return &b.asks
+}
+
+// Apply()
+//
+// Inputs:
+//
+//	s     : Side
+//	price : string
+//	size  : string
+//
+// Outputs:
+//
+//	error
+//
+// Description:
+//
+//	Sets the level at price to size, or deletes it when size is zero. Prices are
+//	compared exactly, so "30000" and "30000.00" update the same level.
+func (b *Book) Apply(s Side, price string, size string) error {
+	key, err := ParseDecimal(price)
+	if err != nil {
+		return fmt.Errorf("bad %s price: %w", s, err)
+	}
+	amount, err := ParseDecimal(size)
+	if err != nil {
+		return fmt.Errorf("bad %s size at %s: %w", s, price, err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.side(s).set(key, amount, price, size)
+	return nil
+}
+
+// ApplyLevels applies every level to one side, stopping at the first bad one.
+func (b *Book) ApplyLevels(s Side, levels []Level) error {
+	for _, level := range levels {
+		if err := b.Apply(s, level.Price, level.Size); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Clear removes every level, e.g. before loading a fresh snapshot.
+func (b *Book) Clear() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bids.entries = nil
+	b.asks.entries = nil
+}
+
+// Truncate keeps only the best n levels on each side.
+func (b *Book) Truncate(n int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, s := range []*side{&b.bids, &b.asks} {
+		if len(s.entries) > n {
+			s.entries = s.entries[:n]
+		}
+	}
+}
+
+// Len returns the number of levels on a side.
+func (b *Book) Len(s Side) int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.side(s).entries)
+}
+
+// Top returns the best n levels of a side, best first. n <= 0 returns the whole side.
+func (b *Book) Top(s Side, n int) []Level {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.side(s).top(n)
+}
+
+// Best returns the best level of a side.
+func (b *Book) Best(s Side) (Level, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	entries := b.side(s).entries
+	if len(entries) == 0 {
+		return Level{}, false
+	}
+	return Level{Price: entries[0].price, Size: entries[0].size}, true
+}
+
+// Mid returns the average of the best bid and ask, false if either side is empty.
+func (b *Book) Mid() (float64, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if len(b.bids.entries) == 0 || len(b.asks.entries) == 0 {
+		return 0, false
+	}
+	return (b.bids.entries[0].key.Float64() + b.asks.entries[0].key.Float64()) / 2, true
+}
+
+// Microprice returns the top of book price weighted by the opposite side's size,
+// (bid * askSize + ask * bidSize) / (bidSize + askSize), false if either side is empty.
+func (b *Book) Microprice() (float64, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if len(b.bids.entries) == 0 || len(b.asks.entries) == 0 {
+		return 0, false
+	}
+	bid, ask := b.bids.entries[0], b.asks.entries[0]
+	bidSize, askSize := bid.amount.Float64(), ask.amount.Float64()
+	return (bid.key.Float64()*askSize + ask.key.Float64()*bidSize) / (bidSize + askSize), true
+}
+
+// Snapshot copies the whole book.
+func (b *Book) Snapshot() Snapshot {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return Snapshot{Symbol: b.Symbol, Bids: b.bids.top(0), Asks: b.asks.top(0)}
+}
@@ -0,0 +1,70 @@
+package orderbook
+
+// Test Cases for ParseDecimal
+var ParseDecimalCases = []struct {
+	name      string
+	input     string
+	want      Decimal
+	wantText  string
+	wantError bool
+}{
+	{name: "integer", input: "30000", want: Decimal{Int: 30000}, wantText: "30000"},
+	{name: "trailing zeros", input: "30000.00", want: Decimal{Int: 30000}, wantText: "30000"},
+	{name: "fraction", input: "0.00015729", want: Decimal{Frac: 157290000000000}, wantText: "0.00015729"},
+	{name: "no integer part", input: ".5", want: Decimal{Frac: 500000000000000000}, wantText: "0.5"},
+	{name: "eighteen places", input: "1.000000000000000001", want: Decimal{Int: 1, Frac: 1}, wantText: "1.000000000000000001"},
+	{name: "too many places", input: "1.0000000000000000001", wantError: true},
+	{name: "negative", input: "-1", wantError: true},
+	{name: "exponent", input: "1e-8", wantError: true},
+	{name: "empty", input: "", wantError: true},
+	{name: "dot only", input: ".", wantError: true},
+}
+
+type bookUpdate struct {
+	side  Side
+	price string
+	size  string
+}
+
+// Test Cases for Book, each applies its updates to an empty book
+var BookCases = []struct {
+	name     string
+	updates  []bookUpdate
+	wantBids []Level
+	wantAsks []Level
+}{
+	{
+		name: "levels sort best first",
+		updates: []bookUpdate{
+			{Bid, "99", "1"}, {Bid, "100", "2"}, {Bid, "98.5", "3"},
+			{Ask, "102", "1"}, {Ask, "101", "2"}, {Ask, "101.5", "3"},
+		},
+		wantBids: []Level{{"100", "2"}, {"99", "1"}, {"98.5", "3"}},
+		wantAsks: []Level{{"101", "2"}, {"101.5", "3"}, {"102", "1"}},
+	},
+	{
+		name: "equal decimals update the same level",
+		updates: []bookUpdate{
+			{Bid, "100", "1"}, {Bid, "100.00", "5"},
+		},
+		wantBids: []Level{{"100.00", "5"}},
+		wantAsks: []Level{},
+	},
+	{
+		name: "zero size deletes",
+		updates: []bookUpdate{
+			{Bid, "100", "1"}, {Bid, "99", "1"}, {Bid, "100.0", "0.000"},
+			{Ask, "101", "0"},
+		},
+		wantBids: []Level{{"99", "1"}},
+		wantAsks: []Level{},
+	},
+	{
+		name: "prices closer than float64 can tell apart",
+		updates: []bookUpdate{
+			{Ask, "1.000000000000000002", "1"}, {Ask, "1.000000000000000001", "1"},
+		},
+		wantBids: []Level{},
+		wantAsks: []Level{{"1.000000000000000001", "1"}, {"1.000000000000000002", "1"}},
+	},
+}
@@ -0,0 +1,146 @@
+package orderbook
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDecimal(t *testing.T) {
+	for _, tt := range ParseDecimalCases {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDecimal(tt.input)
+			if tt.wantError {
+				assert.Error(t, err, "Expected an error but got none")
+				return
+			}
+			assert.NoError(t, err, "Unexpected error occurred")
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantText, got.String())
+		})
+	}
+}
+
+func TestBook(t *testing.T) {
+	for _, tt := range BookCases {
+		t.Run(tt.name, func(t *testing.T) {
+			book := New("BTCUSDT")
+			for _, update := range tt.updates {
+				assert.NoError(t, book.Apply(update.side, update.price, update.size))
+			}
+			snapshot := book.Snapshot()
+			assert.Equal(t, tt.wantBids, snapshot.Bids)
+			assert.Equal(t, tt.wantAsks, snapshot.Asks)
+		})
+	}
+}
+
+func TestTopAndTruncate(t *testing.T) {
+	book := New("BTCUSDT")
+	for i := 0; i < 10; i++ {
+		book.Apply(Bid, fmt.Sprint(100-i), "1")
+		book.Apply(Ask, fmt.Sprint(101+i), "1")
+	}
+
+	assert.Equal(t, []Level{{"100", "1"}, {"99", "1"}}, book.Top(Bid, 2))
+	assert.Equal(t, []Level{{"101", "1"}, {"102", "1"}}, book.Top(Ask, 2))
+	assert.Len(t, book.Top(Bid, 0), 10)
+
+	book.Truncate(3)
+	assert.Equal(t, 3, book.Len(Bid))
+	assert.Equal(t, []Level{{"103", "1"}}, book.Top(Ask, 3)[2:])
+
+	best, ok := book.Best(Ask)
+	assert.True(t, ok)
+	assert.Equal(t, Level{"101", "1"}, best)
+
+	book.Clear()
+	_, ok = book.Best(Bid)
+	assert.False(t, ok)
+}
+
+func TestMidAndMicroprice(t *testing.T) {
+	book := New("BTCUSDT")
+	_, ok := book.Mid()
+	assert.False(t, ok, "empty book has no mid")
+
+	book.Apply(Bid, "100", "3")
+	book.Apply(Ask, "102", "1")
+
+	mid, ok := book.Mid()
+	assert.True(t, ok)
+	assert.Equal(t, 101.0, mid)
+
+	// the heavier bid pulls the microprice towards the ask
+	micro, ok := book.Microprice()
+	assert.True(t, ok)
+	assert.InDelta(t, 101.5, micro, 1e-9)
+}
+
+func TestApplyRejectsBadInput(t *testing.T) {
+	book := New("BTCUSDT")
+	assert.Error(t, book.Apply(Bid, "abc", "1"))
+	assert.Error(t, book.Apply(Bid, "100", "-1"))
+	assert.Equal(t, 0, book.Len(Bid))
+}
+
+// benchmarkLevels is a deep book's worth of updates clustered around the top, like a live feed
+func benchmarkLevels(n int) []bookUpdate {
+	rng := rand.New(rand.NewSource(1))
+	updates := make([]bookUpdate, n)
+	for i := range updates {
+		side := Bid
+		price := 30000 - rng.ExpFloat64()*20
+		if rng.Intn(2) == 1 {
+			side = Ask
+			price = 30001 + rng.ExpFloat64()*20
+		}
+		size := "0"
+		if rng.Intn(4) != 0 {
+			size = fmt.Sprintf("%.5f", rng.Float64())
+		}
+		updates[i] = bookUpdate{side: side, price: fmt.Sprintf("%.2f", price), size: size}
+	}
+	return updates
+}
+
+func BenchmarkParseDecimal(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		ParseDecimal("30718.42000001")
+	}
+}
+
+func BenchmarkApply(b *testing.B) {
+	updates := benchmarkLevels(1 << 16)
+	book := New("BTCUSDT")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		update := updates[i&(len(updates)-1)]
+		book.Apply(update.side, update.price, update.size)
+	}
+}
+
+func BenchmarkTop10(b *testing.B) {
+	book := New("BTCUSDT")
+	for _, update := range benchmarkLevels(1 << 14) {
+		book.Apply(update.side, update.price, update.size)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		book.Top(Bid, 10)
+		book.Top(Ask, 10)
+	}
+}
+
+func BenchmarkMicroprice(b *testing.B) {
+	book := New("BTCUSDT")
+	for _, update := range benchmarkLevels(1 << 14) {
+		book.Apply(update.side, update.price, update.size)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		book.Microprice()
+	}
+}
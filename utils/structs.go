@@ -2,19 +2,125 @@ package utils
 
 import (
 	"encoding/json"
+	"fmt"
+	"time"
 )
 
 type ExchangeConfig struct {
-	Name    string `json:"name"`
-	URI     string `json:"uri"`
-	Market  string `json:"market"`
-	Streams []struct {
-		Type    string          `json:"type"`
-		Symbol  string          `json:"symbol"`
-		Market  string          `json:"market"`
-		Message json.RawMessage `json:"message"`
-	} `json:"streams"`
-	Ping map[string]interface{} `json:"ping,omitempty"`
+	Name     string `json:"name"`
+	Exchange string `json:"exchange"`
+	URI      string `json:"uri"`
+	// BootstrapURI is a REST endpoint some venues require before dialing, e.g. KuCoin's bullet-public
+	BootstrapURI string `json:"bootstrap_uri,omitempty"`
+	// RestURI is the REST endpoint the adapter pulls from, e.g. Binance's /api/v3/depth for
+	// order book snapshots or Coinex's v2 API root for candles
+	RestURI string                 `json:"rest_uri,omitempty"`
+	Market  string                 `json:"market"`
+	Streams []StreamConfig         `json:"streams"`
+	Ping    map[string]interface{} `json:"ping,omitempty"`
+	// Sink is the storage backend for every stream that does not pick its own, "csv" by default
+	Sink        string      `json:"sink,omitempty"`
+	SinkOptions SinkOptions `json:"sink_options,omitempty"`
+	// MaxSize and FlushInterval are the buffer limits of every stream that does not set its own
+	MaxSize       int      `json:"max_size,omitempty"`
+	FlushInterval Duration `json:"flush_interval,omitempty"`
+	// PingInterval is how often Ping (or a websocket ping frame) is sent.
+	PingInterval Duration `json:"ping_interval,omitempty"`
+	// ReadTimeout drops the connection when nothing, not even a pong, arrives in time.
+	ReadTimeout Duration `json:"read_timeout,omitempty"`
+}
+
+type StreamConfig struct {
+	Type    string          `json:"type"`
+	Symbol  string          `json:"symbol"`
+	Market  string          `json:"market"`
+	Message json.RawMessage `json:"message"`
+	// Interval is the candle length of a kline stream in Binance notation, e.g. "1m" or "1h",
+	// for venues whose candles are polled rather than named in the subscribe message
+	Interval string `json:"interval,omitempty"`
+	// IncludePartial also records in-progress kline updates, not just closed candles
+	IncludePartial bool `json:"include_partial,omitempty"`
+	// Source picks the venue event a stream is built from when there is more than
+	// one, e.g. "bookTicker" instead of the default 24hrTicker for Binance tickers
+	Source string `json:"source,omitempty"`
+	// Sink overrides the exchange's storage backend for this stream
+	Sink string `json:"sink,omitempty"`
+	// SinkOptions override the exchange's sink options field by field
+	SinkOptions SinkOptions `json:"sink_options,omitempty"`
+	// MaxSize is how many records are buffered before they are flushed
+	MaxSize int `json:"max_size,omitempty"`
+	// FlushInterval also flushes a buffer whose oldest record has waited this long
+	FlushInterval Duration `json:"flush_interval,omitempty"`
+}
+
+// SinkOptions tune a storage backend; a backend ignores the options it has no use for.
+type SinkOptions struct {
+	// RowGroupSize is the target row group size in bytes (parquet)
+	RowGroupSize int64 `json:"row_group_size,omitempty"`
+	// Compression is the codec name, e.g. "snappy", "zstd", "gzip" or "none" (parquet)
+	Compression string `json:"compression,omitempty"`
+	// Rotate splits files by event time into date=YYYY-MM-DD/hour=HH ("hourly") or
+	// date=YYYY-MM-DD ("daily") partitions, or by MaxFileSize alone ("size"). Sinks that
+	// cannot append, such as parquet, rotate hourly when neither Rotate nor MaxFileSize is set
+	Rotate string `json:"rotate,omitempty"`
+	// MaxFileSize starts a new part once a file reaches this many bytes
+	MaxFileSize int64 `json:"max_file_size,omitempty"`
+}
+
+// Merge returns o with every field override sets replaced by override's value
+func (o SinkOptions) Merge(override SinkOptions) SinkOptions {
+	if override.RowGroupSize != 0 {
+		o.RowGroupSize = override.RowGroupSize
+	}
+	if override.Compression != "" {
+		o.Compression = override.Compression
+	}
+	if override.Rotate != "" {
+		o.Rotate = override.Rotate
+	}
+	if override.MaxFileSize != 0 {
+		o.MaxFileSize = override.MaxFileSize
+	}
+	return o
+}
+
+// Duration is a time.Duration that unmarshals from strings like "20s" or a number of seconds.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	switch value := v.(type) {
+	case float64:
+		*d = Duration(time.Duration(value * float64(time.Second)))
+	case string:
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q: %w", value, err)
+		}
+		*d = Duration(parsed)
+	default:
+		return fmt.Errorf("invalid duration: %s", b)
+	}
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// ParsedMessage is a single decoded exchange message, routed to the
+// buffer for Symbol and DataType. Control frames (subscribe acks, pongs)
+// leave DataType empty and set Event instead. Reply, when set, is written
+// back on the connection, e.g. a pong for a server initiated ping.
+type ParsedMessage struct {
+	DataType string
+	Symbol   string
+	Data     interface{}
+	Event    string
+	Reply    []byte
 }
 
 // add some functionallity
@@ -26,6 +132,8 @@ type TickerDataStruct struct {
 	BidSize   string
 	AskPrice  string
 	AskSize   string
+	// UpdateID is the venue's book update id, when the source carries one (Binance bookTicker)
+	UpdateID uint64
 }
 
 type TradeDataStruct struct {
@@ -35,4 +143,103 @@ type TradeDataStruct struct {
 	Price     string
 	Quantity  string
 	Bid_MM    bool
+	// TradeID is the venue's trade id as sent, empty when the venue has none
+	TradeID string
+}
+
+// GapDataStruct is a run of trade ids a monotonic venue skipped, FirstMissing
+// through LastMissing inclusive. WallTime is when the gap was noticed, in ms.
+type GapDataStruct struct {
+	Symbol       string
+	FirstMissing uint64
+	LastMissing  uint64
+	WallTime     uint64
+}
+
+// DepthDataStruct is one price level of an order book snapshot or diff.
+// Kind is "snapshot" or "update"; a Size of zero removes the level.
+type DepthDataStruct struct {
+	TimeStamp uint64
+	Date      uint64
+	Symbol    string
+	Kind      string
+	UpdateID  uint64
+	Side      string
+	Price     string
+	Size      string
+}
+
+// KlineDataStruct is one candle. Closed is false for in-progress updates,
+// which are only recorded when the stream sets include_partial.
+type KlineDataStruct struct {
+	TimeStamp uint64
+	Date      uint64
+	Symbol    string
+	Interval  string
+	OpenTime  uint64
+	CloseTime uint64
+	Open      string
+	High      string
+	Low       string
+	Close     string
+	Volume    string
+	Closed    bool
+}
+
+// FundingDataStruct is a perpetual's mark price, index price and funding rate.
+// NextFundingTime is in ms, like TimeStamp.
+type FundingDataStruct struct {
+	TimeStamp       uint64
+	Date            uint64
+	Symbol          string
+	MarkPrice       string
+	IndexPrice      string
+	FundingRate     string
+	NextFundingTime uint64
+}
+
+// LiquidationDataStruct is a forced order. Side is the side of the liquidation
+// order itself, so "SELL" means a long position was liquidated.
+type LiquidationDataStruct struct {
+	TimeStamp uint64
+	Date      uint64
+	Symbol    string
+	Side      string
+	Price     string
+	AvgPrice  string
+	Quantity  string
+	Filled    string
+	Status    string
+}
+
+// Stats24hDataStruct is a rolling 24 hour window summary. OpenTime and
+// CloseTime bound the window in ms.
+type Stats24hDataStruct struct {
+	TimeStamp   uint64
+	Date        uint64
+	Symbol      string
+	OpenTime    uint64
+	CloseTime   uint64
+	OpenPrice   string
+	HighPrice   string
+	LowPrice    string
+	ClosePrice  string
+	BaseVolume  string
+	QuoteVolume string
+}
+
+// AggTradeDataStruct is a Binance aggregate trade, laid out like the aggTrades
+// dumps: every fill at one price from one taker order, FirstTradeID through
+// LastTradeID. TradeTime is in ms.
+type AggTradeDataStruct struct {
+	TimeStamp    uint64
+	Date         uint64
+	Symbol       string
+	AggTradeID   uint64
+	Price        string
+	Quantity     string
+	FirstTradeID uint64
+	LastTradeID  uint64
+	TradeTime    uint64
+	IsBuyerMaker bool
 }
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 )
 
 // readConfig reads and unmarshals the configuration file.
@@ -20,3 +22,46 @@ func ReadConfig(filePath string) ([]ExchangeConfig, error) {
 
 	return configs, nil
 }
+
+// quoteCurrencies are tried in order when splitting a symbol, so USDT wins over USD
+var quoteCurrencies = []string{"USDT", "USDC", "USD", "BTC", "ETH", "EUR", "GBP"}
+
+// SplitSymbol splits a symbol in our convention, e.g. BTCUSDT, into base and quote.
+// ok is false when no known quote currency matches.
+func SplitSymbol(symbol string) (base string, quote string, ok bool) {
+	for _, q := range quoteCurrencies {
+		if strings.HasSuffix(symbol, q) && len(symbol) > len(q) {
+			return symbol[:len(symbol)-len(q)], q, true
+		}
+	}
+	return symbol, "", false
+}
+
+// TradeIDGap()
+//
+// Inputs:
+//
+//	symbol : string
+//	last   : int64
+//	id     : int64
+//
+// Outputs:
+//
+//	GapDataStruct
+//	bool
+//
+// Description:
+//
+//	For venues whose trade ids go up by exactly one per trade, returns the ids missing
+//	between the last recorded trade and id. ok is false when id follows last directly.
+func TradeIDGap(symbol string, last int64, id int64) (GapDataStruct, bool) {
+	if id <= last+1 {
+		return GapDataStruct{}, false
+	}
+	return GapDataStruct{
+		Symbol:       symbol,
+		FirstMissing: uint64(last + 1),
+		LastMissing:  uint64(id - 1),
+		WallTime:     uint64(time.Now().UnixMilli()),
+	}, true
+}